@@ -0,0 +1,837 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opsee/compost/composter"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/tracing"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	modeLocal     = "local"
+	tracingStdout = "stdout"
+)
+
+// Config is compost's configuration. It is built from defaults, then an
+// optional YAML config file, then COMPOST_* environment variables, each
+// overriding the last.
+type Config struct {
+	// Mode is empty to run against the opsee services, or "local" to run
+	// against in-memory backends seeded from Fixtures.
+	Mode             string                 `yaml:"mode"`
+	Fixtures         string                 `yaml:"fixtures"`
+	ListenAddr       string                 `yaml:"listen_addr"`
+	ShutdownTimeout  time.Duration          `yaml:"shutdown_timeout"`
+	StaticDir        string                 `yaml:"static_dir"`
+	CORSOrigins      []string               `yaml:"cors_origins"`
+	VapeKeyfile      string                 `yaml:"vape_keyfile"`
+	TLS              TLSConfig              `yaml:"tls"`
+	ServerTLS        ServerTLSConfig        `yaml:"server_tls"`
+	Cache            CacheConfig            `yaml:"cache"`
+	FanOut           FanOutConfig           `yaml:"fan_out"`
+	Subscriptions    SubscriptionsConfig    `yaml:"subscriptions"`
+	PersistedQueries PersistedQueriesConfig `yaml:"persisted_queries"`
+	QueryLimits      QueryLimitsConfig      `yaml:"query_limits"`
+	RateLimits       RateLimitsConfig       `yaml:"rate_limits"`
+	Audit            AuditConfig            `yaml:"audit"`
+	Tracing          TracingConfig          `yaml:"tracing"`
+	Backends         BackendsConfig         `yaml:"backends"`
+}
+
+// TLSConfig controls how compost verifies the gRPC backends.
+type TLSConfig struct {
+	SkipVerify bool   `yaml:"skip_verify"`
+	CAFile     string `yaml:"ca_file"`
+}
+
+// ServerTLSConfig makes compost serve TLS itself, with a certificate reloaded
+// as it changes, and optionally accept client certificates verified by
+// ClientCAFile in place of bearer tokens on /admin/graphql.
+type ServerTLSConfig struct {
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	ClientCAFile   string        `yaml:"client_ca_file"`
+	ClientNames    []string      `yaml:"client_names"`
+}
+
+// CacheConfig bounds the cache of AWS describe calls made through bezos. TTL
+// is keyed by kind: instances, groups or task_definitions. A kind with no TTL
+// is not cached.
+type CacheConfig struct {
+	MaxEntries int                      `yaml:"max_entries"`
+	TTL        map[string]time.Duration `yaml:"ttl"`
+}
+
+// FanOutConfig bounds the backend calls made in parallel to resolve a single
+// field, such as groups without a type or ECS service discovery. Each call is
+// given Timeout.
+type FanOutConfig struct {
+	Concurrency int           `yaml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// SubscriptionsConfig controls the event source behind subscriptions, which
+// polls cats for changes to each subscribed check every PollInterval.
+type SubscriptionsConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// PersistedQueriesConfig controls the queries /graphql requests may send by
+// hash. Mode is register, lookup or allowlist, and Dir is a directory of
+// .graphql files registered at startup. MaxEntries bounds the queries
+// registered by clients, and not those loaded from Dir.
+type PersistedQueriesConfig struct {
+	Mode       string `yaml:"mode"`
+	Dir        string `yaml:"dir,omitempty"`
+	MaxEntries int    `yaml:"max_entries"`
+}
+
+// QueryLimitsConfig bounds the depth and cost of /graphql queries. Weights
+// are keyed by type and field, like EC2Metrics.CPUUtilization, or by type
+// alone for every field of the type, like EC2Metrics.*. A zero limit is not
+// enforced.
+type QueryLimitsConfig struct {
+	MaxDepth int            `yaml:"max_depth"`
+	MaxCost  int            `yaml:"max_cost"`
+	ListSize int            `yaml:"list_size"`
+	Weights  map[string]int `yaml:"weights"`
+}
+
+// RateLimitsConfig bounds the AWS calls made for each customer: reads are
+// bezos describes and metrics, and mutations are instance actions, region
+// scans and stack launches.
+type RateLimitsConfig struct {
+	Reads     RateLimitConfig `yaml:"reads"`
+	Mutations RateLimitConfig `yaml:"mutations"`
+}
+
+// RateLimitConfig allows Rate calls per second, in bursts of up to Burst,
+// with at most Concurrency at once. A zero rate or concurrency is unlimited.
+type RateLimitConfig struct {
+	Rate        float64 `yaml:"rate"`
+	Burst       int     `yaml:"burst"`
+	Concurrency int     `yaml:"concurrency"`
+}
+
+func (r *RateLimitsConfig) kinds() map[string]*RateLimitConfig {
+	return map[string]*RateLimitConfig{
+		resolver.RateLimitReads:     &r.Reads,
+		resolver.RateLimitMutations: &r.Mutations,
+	}
+}
+
+// AuditConfig controls the audit log of mutations, which keeps the last
+// Recent events in memory for the admin auditEvents query and writes every
+// event to each of Sinks.
+type AuditConfig struct {
+	Recent int               `yaml:"recent"`
+	Sinks  []AuditSinkConfig `yaml:"sinks"`
+}
+
+// AuditSinkConfig is a destination for audit events, written as JSON lines:
+// type stdout, or type file with the Path of a file to append to.
+type AuditSinkConfig struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path,omitempty"`
+}
+
+// TracingConfig controls tracing. Exporter is empty to turn tracing off, or
+// stdout to write each span to stdout as a line of JSON.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter,omitempty"`
+}
+
+// BackendConfig locates one backend. HTTP backends are given by URL and gRPC
+// backends by host:port address. A zero timeout leaves calls to the backend
+// bounded only by the request.
+type BackendConfig struct {
+	URL     string        `yaml:"url,omitempty"`
+	Addr    string        `yaml:"addr,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+type BackendsConfig struct {
+	Bartnet    BackendConfig `yaml:"bartnet"`
+	Beavis     BackendConfig `yaml:"beavis"`
+	Hugs       BackendConfig `yaml:"hugs"`
+	Etcd       BackendConfig `yaml:"etcd"`
+	Spanx      BackendConfig `yaml:"spanx"`
+	Cats       BackendConfig `yaml:"cats"`
+	Keelhaul   BackendConfig `yaml:"keelhaul"`
+	Bezos      BackendConfig `yaml:"bezos"`
+	Marktricks BackendConfig `yaml:"marktricks"`
+}
+
+type namedBackend struct {
+	name   string
+	isHTTP bool
+	config *BackendConfig
+}
+
+func (b *BackendsConfig) all() []namedBackend {
+	return []namedBackend{
+		{resolver.BackendBartnet, true, &b.Bartnet},
+		{resolver.BackendBeavis, true, &b.Beavis},
+		{resolver.BackendHugs, true, &b.Hugs},
+		{resolver.BackendEtcd, true, &b.Etcd},
+		{resolver.BackendSpanx, false, &b.Spanx},
+		{resolver.BackendCats, false, &b.Cats},
+		{resolver.BackendKeelhaul, false, &b.Keelhaul},
+		{resolver.BackendBezos, false, &b.Bezos},
+		{resolver.BackendMarktricks, false, &b.Marktricks},
+	}
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Fixtures:        "fixtures",
+		ListenAddr:      ":9096",
+		ShutdownTimeout: 30 * time.Second,
+		StaticDir:       composter.DefaultStaticDir,
+		CORSOrigins:     composter.DefaultCORSOrigins,
+		ServerTLS: ServerTLSConfig{
+			ReloadInterval: composter.DefaultCertReloadInterval,
+		},
+		Cache: CacheConfig{
+			MaxEntries: resolver.DefaultCacheMaxEntries,
+			TTL: map[string]time.Duration{
+				resolver.CacheKindInstances:       time.Minute,
+				resolver.CacheKindGroups:          time.Minute,
+				resolver.CacheKindTaskDefinitions: 10 * time.Minute,
+			},
+		},
+		FanOut: FanOutConfig{
+			Concurrency: resolver.DefaultFanOutConcurrency,
+			Timeout:     30 * time.Second,
+		},
+		Subscriptions: SubscriptionsConfig{
+			PollInterval: resolver.DefaultPollInterval,
+		},
+		PersistedQueries: PersistedQueriesConfig{
+			Mode:       composter.PersistedQueriesRegister,
+			MaxEntries: composter.DefaultPersistedQueryMaxEntries,
+		},
+		QueryLimits: QueryLimitsConfig{
+			MaxDepth: 12,
+			MaxCost:  5000,
+			ListSize: composter.DefaultListSize,
+			Weights:  defaultQueryWeights(),
+		},
+		RateLimits: RateLimitsConfig{
+			Reads:     RateLimitConfig(resolver.DefaultRateLimits.Reads),
+			Mutations: RateLimitConfig(resolver.DefaultRateLimits.Mutations),
+		},
+		Audit: AuditConfig{
+			Recent: composter.DefaultAuditRecent,
+		},
+		Backends: BackendsConfig{
+			Bartnet:    BackendConfig{URL: "https://bartnet.in.opsee.com"},
+			Beavis:     BackendConfig{URL: "https://beavis.in.opsee.com"},
+			Hugs:       BackendConfig{URL: "https://hugs.in.opsee.com"},
+			Etcd:       BackendConfig{URL: "http://etcd.in.opsee.com:2479"},
+			Spanx:      BackendConfig{Addr: "spanx.in.opsee.com:8443"},
+			Cats:       BackendConfig{Addr: "cats.in.opsee.com:443"},
+			Keelhaul:   BackendConfig{Addr: "keelhaul.in.opsee.com:443"},
+			Bezos:      BackendConfig{Addr: "bezosphere.in.opsee.com:8443"},
+			Marktricks: BackendConfig{Addr: "marktricks.in.opsee.com:443"},
+		},
+	}
+}
+
+// defaultQueryWeights weighs every CloudWatch metric and check metrics query
+// at 10, since each is a call to AWS.
+func defaultQueryWeights() map[string]int {
+	weights := map[string]int{"schemaCheck.metrics": 10}
+	for _, name := range composter.MetricTypeNames() {
+		weights[name+".*"] = 10
+	}
+	return weights
+}
+
+// loadConfig returns the default config overridden by the YAML file at path,
+// if path is not empty, and then by the environment.
+func loadConfig(path string) (*Config, error) {
+	config := defaultConfig()
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// strict decoding rejects keys already in a map, so decode the cache
+		// ttls and query weights into empty maps and fill in the defaults
+		// afterwards
+		ttls := config.Cache.TTL
+		config.Cache.TTL = make(map[string]time.Duration)
+		weights := config.QueryLimits.Weights
+		config.QueryLimits.Weights = make(map[string]int)
+
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+
+		for kind, ttl := range ttls {
+			if _, ok := config.Cache.TTL[kind]; !ok {
+				config.Cache.TTL[kind] = ttl
+			}
+		}
+
+		for field, weight := range weights {
+			if _, ok := config.QueryLimits.Weights[field]; !ok {
+				config.QueryLimits.Weights[field] = weight
+			}
+		}
+	}
+
+	if err := config.loadEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadEnv overrides the config with any COMPOST_* variables that getenv
+// returns a value for.
+func (c *Config) loadEnv(getenv func(string) string) error {
+	vars := map[string]*string{
+		"COMPOST_MODE":         &c.Mode,
+		"COMPOST_FIXTURES":     &c.Fixtures,
+		"COMPOST_ADDRESS":      &c.ListenAddr,
+		"COMPOST_STATIC_DIR":   &c.StaticDir,
+		"COMPOST_VAPE_KEYFILE": &c.VapeKeyfile,
+		"COMPOST_TLS_CA_FILE":  &c.TLS.CAFile,
+
+		"COMPOST_SERVER_TLS_CERT_FILE":      &c.ServerTLS.CertFile,
+		"COMPOST_SERVER_TLS_KEY_FILE":       &c.ServerTLS.KeyFile,
+		"COMPOST_SERVER_TLS_CLIENT_CA_FILE": &c.ServerTLS.ClientCAFile,
+
+		"COMPOST_PERSISTED_QUERIES_MODE": &c.PersistedQueries.Mode,
+		"COMPOST_PERSISTED_QUERIES_DIR":  &c.PersistedQueries.Dir,
+		"COMPOST_TRACING_EXPORTER":       &c.Tracing.Exporter,
+	}
+
+	for _, b := range c.Backends.all() {
+		prefix := "COMPOST_" + strings.ToUpper(b.name)
+		if b.isHTTP {
+			vars[prefix+"_URL"] = &b.config.URL
+		} else {
+			vars[prefix+"_ADDR"] = &b.config.Addr
+		}
+
+		if v := getenv(prefix + "_TIMEOUT"); v != "" {
+			timeout, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s_TIMEOUT: %s", prefix, err)
+			}
+			b.config.Timeout = timeout
+		}
+	}
+
+	for name, field := range vars {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+
+	for _, kind := range resolver.CacheKinds {
+		name := "COMPOST_CACHE_" + strings.ToUpper(kind) + "_TTL"
+		if v := getenv(name); v != "" {
+			ttl, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+
+			if c.Cache.TTL == nil {
+				c.Cache.TTL = make(map[string]time.Duration)
+			}
+			c.Cache.TTL[kind] = ttl
+		}
+	}
+
+	if v := getenv("COMPOST_CACHE_MAX_ENTRIES"); v != "" {
+		maxEntries, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_CACHE_MAX_ENTRIES: %s", err)
+		}
+		c.Cache.MaxEntries = maxEntries
+	}
+
+	if v := getenv("COMPOST_FAN_OUT_CONCURRENCY"); v != "" {
+		concurrency, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_FAN_OUT_CONCURRENCY: %s", err)
+		}
+		c.FanOut.Concurrency = concurrency
+	}
+
+	if v := getenv("COMPOST_FAN_OUT_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_FAN_OUT_TIMEOUT: %s", err)
+		}
+		c.FanOut.Timeout = timeout
+	}
+
+	if v := getenv("COMPOST_SERVER_TLS_RELOAD_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_SERVER_TLS_RELOAD_INTERVAL: %s", err)
+		}
+		c.ServerTLS.ReloadInterval = interval
+	}
+
+	if v := getenv("COMPOST_SHUTDOWN_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_SHUTDOWN_TIMEOUT: %s", err)
+		}
+		c.ShutdownTimeout = timeout
+	}
+
+	if v := getenv("COMPOST_SUBSCRIPTIONS_POLL_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_SUBSCRIPTIONS_POLL_INTERVAL: %s", err)
+		}
+		c.Subscriptions.PollInterval = interval
+	}
+
+	if v := getenv("COMPOST_PERSISTED_QUERIES_MAX_ENTRIES"); v != "" {
+		maxEntries, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_PERSISTED_QUERIES_MAX_ENTRIES: %s", err)
+		}
+		c.PersistedQueries.MaxEntries = maxEntries
+	}
+
+	ints := map[string]*int{
+		"COMPOST_QUERY_MAX_DEPTH": &c.QueryLimits.MaxDepth,
+		"COMPOST_QUERY_MAX_COST":  &c.QueryLimits.MaxCost,
+	}
+
+	for name, field := range ints {
+		if v := getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			*field = n
+		}
+	}
+
+	for kind, limit := range c.RateLimits.kinds() {
+		prefix := "COMPOST_RATE_LIMIT_" + strings.ToUpper(kind)
+
+		if v := getenv(prefix + "_RATE"); v != "" {
+			rate, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s_RATE: %s", prefix, err)
+			}
+			limit.Rate = rate
+		}
+
+		for name, field := range map[string]*int{"_BURST": &limit.Burst, "_CONCURRENCY": &limit.Concurrency} {
+			if v := getenv(prefix + name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return fmt.Errorf("%s%s: %s", prefix, name, err)
+				}
+				*field = n
+			}
+		}
+	}
+
+	if v := getenv("COMPOST_AUDIT_RECENT"); v != "" {
+		recent, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_AUDIT_RECENT: %s", err)
+		}
+		c.Audit.Recent = recent
+	}
+
+	// sinks are listed as stdout or file:path
+	if v := getenv("COMPOST_AUDIT_SINKS"); v != "" {
+		c.Audit.Sinks = nil
+		for _, item := range splitList(v) {
+			parts := strings.SplitN(item, ":", 2)
+			sink := AuditSinkConfig{Type: parts[0]}
+			if len(parts) == 2 {
+				sink.Path = parts[1]
+			}
+			c.Audit.Sinks = append(c.Audit.Sinks, sink)
+		}
+	}
+
+	if v := getenv("COMPOST_CORS_ORIGINS"); v != "" {
+		c.CORSOrigins = splitList(v)
+	}
+
+	if v := getenv("COMPOST_SERVER_TLS_CLIENT_NAMES"); v != "" {
+		c.ServerTLS.ClientNames = splitList(v)
+	}
+
+	if v := getenv("COMPOST_SKIP_VERIFY"); v != "" {
+		skipVerify, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("COMPOST_SKIP_VERIFY: %s", err)
+		}
+		c.TLS.SkipVerify = skipVerify
+	}
+
+	return nil
+}
+
+// Validate returns an error describing every problem with the config.
+func (c *Config) Validate() error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.Mode != "" && c.Mode != modeLocal {
+		fail("mode must be empty or %q, got %q", modeLocal, c.Mode)
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		fail("listen_addr: %s", err)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		fail("shutdown_timeout must not be negative")
+	}
+
+	if (c.ServerTLS.CertFile == "") != (c.ServerTLS.KeyFile == "") {
+		fail("server_tls.cert_file and server_tls.key_file must be set together")
+	}
+
+	if c.ServerTLS.CertFile == "" && (c.ServerTLS.ClientCAFile != "" || len(c.ServerTLS.ClientNames) > 0) {
+		fail("server_tls.client_ca_file and client_names require server_tls.cert_file")
+	}
+
+	if len(c.ServerTLS.ClientNames) > 0 && c.ServerTLS.ClientCAFile == "" {
+		fail("server_tls.client_names requires server_tls.client_ca_file")
+	}
+
+	if c.ServerTLS.ReloadInterval < 0 {
+		fail("server_tls.reload_interval must not be negative")
+	}
+
+	for _, origin := range c.CORSOrigins {
+		if _, err := regexp.Compile(origin); err != nil {
+			fail("cors_origins: %s", err)
+		}
+	}
+
+	if c.Cache.MaxEntries < 0 {
+		fail("cache.max_entries must not be negative")
+	}
+
+	for kind, ttl := range c.Cache.TTL {
+		if !isCacheKind(kind) {
+			fail("cache.ttl: unknown kind %q, must be one of %s", kind, strings.Join(resolver.CacheKinds, ", "))
+		} else if ttl < 0 {
+			fail("cache.ttl.%s must not be negative", kind)
+		}
+	}
+
+	if c.FanOut.Concurrency < 0 {
+		fail("fan_out.concurrency must not be negative")
+	}
+
+	if c.FanOut.Timeout < 0 {
+		fail("fan_out.timeout must not be negative")
+	}
+
+	if c.Subscriptions.PollInterval < 0 {
+		fail("subscriptions.poll_interval must not be negative")
+	}
+
+	if !isPersistedQueryMode(c.PersistedQueries.Mode) {
+		fail("persisted_queries.mode: unknown mode %q, must be one of %s", c.PersistedQueries.Mode, strings.Join(composter.PersistedQueryModes, ", "))
+	} else if c.PersistedQueries.Mode == composter.PersistedQueriesAllowlist && c.PersistedQueries.Dir == "" {
+		fail("persisted_queries.dir must be set in allowlist mode")
+	}
+
+	if c.PersistedQueries.MaxEntries < 0 {
+		fail("persisted_queries.max_entries must not be negative")
+	}
+
+	if c.QueryLimits.MaxDepth < 0 {
+		fail("query_limits.max_depth must not be negative")
+	}
+
+	if c.QueryLimits.MaxCost < 0 {
+		fail("query_limits.max_cost must not be negative")
+	}
+
+	if c.QueryLimits.ListSize < 0 {
+		fail("query_limits.list_size must not be negative")
+	}
+
+	for field, weight := range c.QueryLimits.Weights {
+		if parts := strings.Split(field, "."); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fail("query_limits.weights: %q must be Type.field or Type.*", field)
+		} else if weight < 0 {
+			fail("query_limits.weights.%s must not be negative", field)
+		}
+	}
+
+	for kind, limit := range c.RateLimits.kinds() {
+		if limit.Rate < 0 || limit.Burst < 0 || limit.Concurrency < 0 {
+			fail("rate_limits.%s must not be negative", kind)
+		}
+	}
+
+	if c.Audit.Recent < 0 {
+		fail("audit.recent must not be negative")
+	}
+
+	for i, sink := range c.Audit.Sinks {
+		switch sink.Type {
+		case composter.AuditSinkStdout:
+			if sink.Path != "" {
+				fail("audit.sinks[%d]: a stdout sink takes no path", i)
+			}
+		case composter.AuditSinkFile:
+			if sink.Path == "" {
+				fail("audit.sinks[%d]: a file sink needs a path", i)
+			}
+		default:
+			fail("audit.sinks[%d]: unknown type %q, must be one of %s", i, sink.Type, strings.Join(composter.AuditSinkTypes, ", "))
+		}
+	}
+
+	if c.Tracing.Exporter != "" && c.Tracing.Exporter != tracingStdout {
+		fail("tracing.exporter must be empty or %q, got %q", tracingStdout, c.Tracing.Exporter)
+	}
+
+	if c.Mode == modeLocal {
+		if c.Fixtures == "" {
+			fail("fixtures must be set in local mode")
+		}
+	} else {
+		if c.VapeKeyfile == "" {
+			fail("vape_keyfile must be set")
+		}
+
+		for _, b := range c.Backends.all() {
+			if err := b.validate(); err != nil {
+				fail("backends.%s: %s", b.name, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func isCacheKind(kind string) bool {
+	for _, k := range resolver.CacheKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func isPersistedQueryMode(mode string) bool {
+	for _, m := range composter.PersistedQueryModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func (b namedBackend) validate() error {
+	if b.config.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if b.isHTTP {
+		if b.config.Addr != "" {
+			return fmt.Errorf("takes a url, not an addr")
+		}
+
+		u, err := url.Parse(b.config.URL)
+		if err != nil {
+			return err
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http or https url, got %q", b.config.URL)
+		}
+
+		return nil
+	}
+
+	if b.config.URL != "" {
+		return fmt.Errorf("takes an addr, not a url")
+	}
+
+	host, port, err := net.SplitHostPort(b.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	if host == "" || port == "" {
+		return fmt.Errorf("addr must be host:port, got %q", b.config.Addr)
+	}
+
+	return nil
+}
+
+// ClientConfig returns the resolver configuration for the backends.
+func (c *Config) ClientConfig() resolver.ClientConfig {
+	timeouts := make(map[string]time.Duration)
+	for _, b := range c.Backends.all() {
+		if b.config.Timeout > 0 {
+			timeouts[b.name] = b.config.Timeout
+		}
+	}
+
+	return resolver.ClientConfig{
+		SkipVerify:   c.TLS.SkipVerify,
+		CAFile:       c.TLS.CAFile,
+		Timeouts:     timeouts,
+		Cache:        c.CacheConfig(),
+		FanOut:       c.FanOutConfig(),
+		PollInterval: c.Subscriptions.PollInterval,
+		RateLimits:   c.RateLimitConfig(),
+		Bartnet:      c.Backends.Bartnet.URL,
+		Beavis:       c.Backends.Beavis.URL,
+		Hugs:         c.Backends.Hugs.URL,
+		Etcd:         c.Backends.Etcd.URL,
+		Spanx:        c.Backends.Spanx.Addr,
+		Cats:         c.Backends.Cats.Addr,
+		Keelhaul:     c.Backends.Keelhaul.Addr,
+		Bezos:        c.Backends.Bezos.Addr,
+		Marktricks:   c.Backends.Marktricks.Addr,
+	}
+}
+
+// CacheConfig returns the resolver configuration for the cache.
+func (c *Config) CacheConfig() resolver.CacheConfig {
+	return resolver.CacheConfig{
+		TTLs:       c.Cache.TTL,
+		MaxEntries: c.Cache.MaxEntries,
+	}
+}
+
+// FanOutConfig returns the resolver configuration for parallel backend calls.
+func (c *Config) FanOutConfig() resolver.FanOutConfig {
+	return resolver.FanOutConfig{
+		Concurrency: c.FanOut.Concurrency,
+		Timeout:     c.FanOut.Timeout,
+	}
+}
+
+// RateLimitConfig returns the resolver configuration for the rate limiter.
+func (c *Config) RateLimitConfig() resolver.RateLimitConfig {
+	return resolver.RateLimitConfig{
+		Reads: resolver.RateLimit{
+			Rate:        c.RateLimits.Reads.Rate,
+			Burst:       c.RateLimits.Reads.Burst,
+			Concurrency: c.RateLimits.Reads.Concurrency,
+		},
+		Mutations: resolver.RateLimit{
+			Rate:        c.RateLimits.Mutations.Rate,
+			Burst:       c.RateLimits.Mutations.Burst,
+			Concurrency: c.RateLimits.Mutations.Concurrency,
+		},
+	}
+}
+
+// PersistedQueryConfig returns the configuration for the persisted query
+// registry.
+func (c *Config) PersistedQueryConfig() composter.PersistedQueryConfig {
+	return composter.PersistedQueryConfig{
+		Mode:       c.PersistedQueries.Mode,
+		MaxEntries: c.PersistedQueries.MaxEntries,
+	}
+}
+
+// ComposterConfig returns the configuration for the http server.
+func (c *Config) ComposterConfig() composter.Config {
+	return composter.Config{
+		CORSOrigins: c.CORSOrigins,
+		StaticDir:   c.StaticDir,
+		QueryLimits: composter.QueryLimits{
+			MaxDepth: c.QueryLimits.MaxDepth,
+			MaxCost:  c.QueryLimits.MaxCost,
+			ListSize: c.QueryLimits.ListSize,
+			Weights:  c.QueryLimits.Weights,
+		},
+		TLS: composter.TLSConfig{
+			CertFile:       c.ServerTLS.CertFile,
+			KeyFile:        c.ServerTLS.KeyFile,
+			ReloadInterval: c.ServerTLS.ReloadInterval,
+			ClientCAFile:   c.ServerTLS.ClientCAFile,
+			ClientNames:    c.ServerTLS.ClientNames,
+		},
+	}
+}
+
+// AuditLog opens the audit log's sinks and returns the log.
+func (c *Config) AuditLog() (*composter.AuditLog, error) {
+	var sinks []composter.AuditSink
+	for _, s := range c.Audit.Sinks {
+		switch s.Type {
+		case composter.AuditSinkStdout:
+			sinks = append(sinks, composter.NewWriterAuditSink(os.Stdout))
+		case composter.AuditSinkFile:
+			sink, err := composter.NewFileAuditSink(s.Path)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		}
+	}
+
+	return composter.NewAuditLog(c.Audit.Recent, sinks...), nil
+}
+
+// Tracer returns the tracer to set, or nil if tracing is off.
+func (c *Config) Tracer() *tracing.Tracer {
+	if c.Tracing.Exporter == tracingStdout {
+		return tracing.NewTracer(tracing.NewJSONExporter(os.Stdout))
+	}
+	return nil
+}
+
+// Print writes the config to w as YAML.
+func (c *Config) Print(w io.Writer) error {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/compost/composter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigEnv(t *testing.T) {
+	config := defaultConfig()
+	config.VapeKeyfile = "/vape.key"
+
+	env := map[string]string{
+		"COMPOST_BARTNET_URL":      "http://localhost:8080",
+		"COMPOST_CATS_ADDR":        "localhost:9101",
+		"COMPOST_CATS_TIMEOUT":     "2s",
+		"COMPOST_CORS_ORIGINS":     "https?://localhost:3000, https://staging\\.example\\.com",
+		"COMPOST_SKIP_VERIFY":      "true",
+		"COMPOST_CACHE_GROUPS_TTL": "30s",
+		"COMPOST_AUDIT_SINKS":      "stdout, file:/var/log/compost/audit.jsonl",
+		"COMPOST_SHUTDOWN_TIMEOUT": "1m",
+	}
+
+	err := config.loadEnv(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, "http://localhost:8080", config.Backends.Bartnet.URL)
+	assert.Equal(t, "localhost:9101", config.Backends.Cats.Addr)
+	assert.Equal(t, []string{`https?://localhost:3000`, `https://staging\.example\.com`}, config.CORSOrigins)
+	assert.Equal(t, []AuditSinkConfig{{Type: "stdout"}, {Type: "file", Path: "/var/log/compost/audit.jsonl"}}, config.Audit.Sinks)
+	assert.Equal(t, time.Minute, config.ShutdownTimeout)
+
+	client := config.ClientConfig()
+	assert.True(t, client.SkipVerify)
+	assert.Equal(t, 2*time.Second, client.Timeouts["cats"])
+	assert.Equal(t, "localhost:9101", client.Cats)
+	assert.Equal(t, 30*time.Second, client.Cache.TTLs["groups"])
+	assert.Equal(t, time.Minute, client.Cache.TTLs["instances"])
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := defaultConfig()
+	config.Backends.Bartnet.URL = "bartnet.in.opsee.com"
+	config.Backends.Cats.Addr = "cats.in.opsee.com"
+	config.Backends.Bezos.Timeout = -time.Second
+	config.Cache.TTL["volumes"] = time.Minute
+	config.PersistedQueries.Mode = "cached"
+	config.QueryLimits.Weights["instances"] = 5
+	config.Audit.Sinks = []AuditSinkConfig{{Type: "syslog"}}
+	config.ServerTLS.ClientCAFile = "/etc/compost/clients.pem"
+
+	err := config.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "vape_keyfile")
+		assert.Contains(t, err.Error(), "backends.bartnet")
+		assert.Contains(t, err.Error(), "backends.cats")
+		assert.Contains(t, err.Error(), "backends.bezos")
+		assert.Contains(t, err.Error(), `unknown kind "volumes"`)
+		assert.Contains(t, err.Error(), `unknown mode "cached"`)
+		assert.Contains(t, err.Error(), `"instances" must be Type.field`)
+		assert.Contains(t, err.Error(), `audit.sinks[0]: unknown type "syslog"`)
+		assert.Contains(t, err.Error(), "server_tls.client_ca_file and client_names require server_tls.cert_file")
+	}
+
+	delete(config.Cache.TTL, "volumes")
+	config.PersistedQueries.Mode = composter.PersistedQueriesRegister
+	delete(config.QueryLimits.Weights, "instances")
+	config.Audit.Sinks = nil
+	config.ServerTLS.CertFile = "/etc/compost/tls.crt"
+	config.ServerTLS.KeyFile = "/etc/compost/tls.key"
+	config.Mode = modeLocal
+	assert.NoError(t, config.Validate())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	log "github.com/opsee/logrus"
+	"github.com/opsee/vaper"
+	"golang.org/x/net/context"
+)
+
+var (
+	// devVapeKey signs and verifies bearer tokens in local mode. It is public,
+	// so tokens signed with it must never be accepted by a deployed compost.
+	devVapeKey = []byte("compost-dev-key!")
+)
+
+// devResolver returns a resolver backed by in-memory fakes seeded from the
+// fixture directory. It logs, for every fixture user, the basic authorization
+// accepted by /graphql and the bearer token accepted by /admin/graphql. The
+// fakes are wrapped like real backends, so calls to them are bounded by
+// timeouts and instrumented.
+func devResolver(fixtureDir string, timeouts map[string]time.Duration) *resolver.Client {
+	vaper.Init(devVapeKey)
+
+	backends := fake.New()
+	if err := backends.LoadFixtures(fixtureDir); err != nil {
+		log.WithError(err).Fatal("Unable to load fixtures.")
+	}
+
+	resp, err := backends.Cats.ListUsers(context.Background(), &opsee.ListUsersRequest{})
+	if err != nil {
+		log.WithError(err).Fatal("Unable to list fixture users.")
+	}
+
+	now := time.Now()
+	for _, user := range resp.Users {
+		if user.Perms == nil {
+			user.Perms = &schema.UserFlags{}
+		}
+
+		userJson, err := json.Marshal(user)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to encode fixture user.")
+		}
+
+		token, err := vaper.New(user, user.Email, now.Add(-time.Minute), now.Add(30*24*time.Hour)).Marshal()
+		if err != nil {
+			log.WithError(err).Fatal("Unable to sign dev token.")
+		}
+
+		log.WithFields(log.Fields{
+			"email":       user.Email,
+			"customer_id": user.CustomerId,
+			"graphql":     "Basic " + base64.StdEncoding.EncodeToString(userJson),
+			"admin":       "Bearer " + token,
+		}).Info("dev authorization")
+	}
+
+	return resolver.NewClientWithBackends(resolver.WrapBackends(backends.Backends(), timeouts))
+}
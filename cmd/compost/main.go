@@ -1,52 +1,140 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/opsee/compost/composter"
 	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/tracing"
 	log "github.com/opsee/logrus"
 	"github.com/opsee/vaper"
+	"golang.org/x/net/context"
 )
 
 func main() {
-	key, err := ioutil.ReadFile(mustEnvString("COMPOST_VAPE_KEYFILE"))
-	if err != nil {
-		log.Fatal("Unable to read vape key: ", err)
-	}
-	vaper.Init(key)
-
-	// for local dev only
-	skipVerify := os.Getenv("COMPOST_SKIP_VERIFY")
-
-	resolver, err := resolver.NewClient(resolver.ClientConfig{
-		SkipVerify: skipVerify == "true",
-		Bartnet:    "https://bartnet.in.opsee.com",
-		Beavis:     "https://beavis.in.opsee.com",
-		Spanx:      "spanx.in.opsee.com:8443",
-		Cats:       "cats.in.opsee.com:443",
-		Keelhaul:   "keelhaul.in.opsee.com:443",
-		Bezos:      "bezosphere.in.opsee.com:8443",
-		Hugs:       "https://hugs.in.opsee.com",
-		Marktricks: "marktricks.in.opsee.com:443",
-		Etcd:       "http://etcd.in.opsee.com:2479",
-	})
+	var (
+		configFile = flag.String("config", os.Getenv("COMPOST_CONFIG"), "path to a YAML config file (or set COMPOST_CONFIG)")
+		dev        = flag.Bool("dev", false, "run against local fixtures instead of the opsee services (or set COMPOST_MODE=local)")
+		fixtures   = flag.String("fixtures", "", "directory of json or yaml fixtures loaded in dev mode (or set COMPOST_FIXTURES)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [config print]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
+	config, err := loadConfig(*configFile)
 	if err != nil {
+		log.WithError(err).Fatal("Unable to load config.")
+	}
+
+	if *dev {
+		config.Mode = modeLocal
+	}
+
+	if *fixtures != "" {
+		config.Fixtures = *fixtures
+	}
+
+	switch args := flag.Args(); {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := config.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := config.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.Validate(); err != nil {
 		log.Fatal(err)
 	}
 
-	composter := composter.New(resolver)
-	composter.StartHTTP(
-		mustEnvString("COMPOST_ADDRESS"),
-	)
-}
+	tracing.SetTracer(config.Tracer())
+
+	var client *resolver.Client
+	if config.Mode == modeLocal {
+		log.Info("Starting in local dev mode with fixtures from ", config.Fixtures)
+		client = devResolver(config.Fixtures, config.ClientConfig().Timeouts)
+		client.UseCache(resolver.NewCache(config.CacheConfig()))
+		client.UseRateLimiter(resolver.NewRateLimiter(config.RateLimitConfig()))
+		client.FanOut = config.FanOutConfig()
+		client.Events = resolver.NewPollingEventSource(client.Cats, config.Subscriptions.PollInterval)
+	} else {
+		key, err := ioutil.ReadFile(config.VapeKeyfile)
+		if err != nil {
+			log.Fatal("Unable to read vape key: ", err)
+		}
+		vaper.Init(key)
+
+		client, err = resolver.NewClient(config.ClientConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-func mustEnvString(envVar string) string {
-	out := os.Getenv(envVar)
-	if out == "" {
-		log.Fatal(envVar, "must be set")
+	queries := composter.NewPersistedQueries(config.PersistedQueryConfig())
+	if config.PersistedQueries.Dir != "" {
+		loaded, err := queries.LoadDir(config.PersistedQueries.Dir)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to load persisted queries.")
+		}
+		log.WithField("dir", config.PersistedQueries.Dir).Infof("Loaded %d persisted queries", loaded)
 	}
-	return out
+
+	auditLog, err := config.AuditLog()
+	if err != nil {
+		log.WithError(err).Fatal("Unable to open audit log.")
+	}
+
+	composterConfig := config.ComposterConfig()
+	composterConfig.PersistedQueries = queries
+	composterConfig.AuditLog = auditLog
+
+	composter := composter.New(client, composterConfig)
+
+	// drain requests on SIGTERM or SIGINT, then clean up once StartHTTP
+	// returns and the drain is done
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-signals
+
+		log.WithField("signal", sig).Infof("Shutting down, waiting up to %s for requests to finish.", config.ShutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+
+		if err := composter.Shutdown(ctx); err != nil {
+			log.WithError(err).Warn("Cancelled the requests still in flight.")
+		}
+	}()
+
+	if err := composter.StartHTTP(config.ListenAddr); err != nil {
+		log.WithError(err).Fatal("Unable to serve HTTP.")
+	}
+	<-drained
+
+	if err := client.Close(); err != nil {
+		log.WithError(err).Error("Error closing backend connections.")
+	}
+
+	if err := auditLog.Close(); err != nil {
+		log.WithError(err).Error("Error closing audit log.")
+	}
+
+	log.Info("Shut down.")
 }
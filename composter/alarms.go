@@ -0,0 +1,261 @@
+package composter
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/basic/schema"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	"github.com/opsee/compost/resolver"
+	opsee_scalars "github.com/opsee/protobuf/plugin/graphql/scalars"
+)
+
+var (
+	AlarmStateEnumType = graphql.NewEnum(graphql.EnumConfig{
+		Name:        "AlarmState",
+		Description: "The state of a CloudWatch alarm",
+		Values:      alarmStateValues(),
+	})
+
+	AlarmType = newAlarmType()
+
+	errCheckAlarmsRegion = resolver.NewError(resolver.ErrorInvalidInput, "region is required for the alarms of a check without results")
+)
+
+// newAlarmType returns the type of a CloudWatch alarm. Bezos doesn't serve
+// DescribeAlarmHistory, so the fields of its last state change are all there
+// is of an alarm's history.
+func newAlarmType() *graphql.Object {
+	alarmType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        opsee_aws_cloudwatch.GraphQLMetricAlarmType.Name(),
+		Description: "A CloudWatch alarm",
+		Fields:      graphql.Fields{},
+	})
+	addFields(alarmType, opsee_aws_cloudwatch.GraphQLMetricAlarmType.Fields())
+
+	alarmType.AddFieldConfig("StateReason", &graphql.Field{
+		Type:        graphql.String,
+		Description: "Why the alarm entered its current state, in words",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if alarm, ok := p.Source.(*opsee_aws_cloudwatch.MetricAlarm); ok && alarm.StateReason != nil {
+				return alarm.GetStateReason(), nil
+			}
+			return nil, nil
+		},
+	})
+	alarmType.AddFieldConfig("StateReasonData", &graphql.Field{
+		Type:        graphql.String,
+		Description: "Why the alarm entered its current state, as JSON",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if alarm, ok := p.Source.(*opsee_aws_cloudwatch.MetricAlarm); ok && alarm.StateReasonData != nil {
+				return alarm.GetStateReasonData(), nil
+			}
+			return nil, nil
+		},
+	})
+	alarmType.AddFieldConfig("StateUpdatedTimestamp", &graphql.Field{
+		Type:        opsee_scalars.Timestamp,
+		Description: "When the alarm entered its current state",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if alarm, ok := p.Source.(*opsee_aws_cloudwatch.MetricAlarm); ok && alarm.StateUpdatedTimestamp != nil {
+				return alarm.GetStateUpdatedTimestamp(), nil
+			}
+			return nil, nil
+		},
+	})
+
+	return alarmType
+}
+
+func alarmStateValues() graphql.EnumValueConfigMap {
+	values := make(graphql.EnumValueConfigMap)
+	for _, state := range resolver.AlarmStates {
+		values[state] = &graphql.EnumValueConfig{Value: state}
+	}
+	return values
+}
+
+// queryAlarms returns the alarms field of resources with metrics: the alarms
+// on any of their metrics, in any namespace.
+func (c *Composter) queryAlarms() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(AlarmType),
+		Description: "The CloudWatch alarms on the resource's metrics",
+		Args: graphql.FieldConfigArgument{
+			"state": &graphql.ArgumentConfig{
+				Description: "Only alarms in this state",
+				Type:        AlarmStateEnumType,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			dimensions, err := metricDimensions(p.Source)
+			if err != nil {
+				return nil, err
+			}
+
+			state, _ := p.Args["state"].(string)
+			return c.resolver.GetResourceAlarms(p.Context, user, queryContext.Region, state, dimensions)
+		},
+	}
+}
+
+// queryCheckAlarms returns the alarms on the metrics of a check's target.
+// Checks aren't queried within a region, so it's the region of the check's
+// results unless one is given.
+func (c *Composter) queryCheckAlarms() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(AlarmType),
+		Description: "The CloudWatch alarms on the metrics of the check's target",
+		Args: graphql.FieldConfigArgument{
+			"state": &graphql.ArgumentConfig{
+				Description: "Only alarms in this state",
+				Type:        AlarmStateEnumType,
+			},
+			"region": &graphql.ArgumentConfig{
+				Description: "The target's region, if not the region of the check's results",
+				Type:        graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			check, ok := p.Source.(*schema.Check)
+			if !ok {
+				return nil, errMissingCheckId
+			}
+
+			if check.Target == nil {
+				return nil, nil
+			}
+
+			dimension, ok := resolver.CheckTargetDimensions[check.Target.Type]
+			if !ok {
+				return nil, nil
+			}
+
+			region, _ := p.Args["region"].(string)
+			if region == "" {
+				results, err := c.checkResults(p.Context, user, check)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(results) > 0 {
+					region = results[0].Region
+				}
+			}
+
+			if region == "" {
+				return nil, errCheckAlarmsRegion
+			}
+
+			state, _ := p.Args["state"].(string)
+			return c.resolver.GetResourceAlarms(p.Context, user, region, state, []*opsee_aws_cloudwatch.Dimension{
+				{
+					Name:  aws.String(dimension),
+					Value: aws.String(check.Target.Id),
+				},
+			})
+		},
+	}
+}
+
+func (c *Composter) queryRegionAlarms() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(AlarmType),
+		Description: "The region's CloudWatch alarms",
+		Args: graphql.FieldConfigArgument{
+			"state": &graphql.ArgumentConfig{
+				Description: "Only alarms in this state",
+				Type:        AlarmStateEnumType,
+			},
+			"prefix": &graphql.ArgumentConfig{
+				Description: "Only alarms whose names start with this",
+				Type:        graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			input := &opsee_aws_cloudwatch.DescribeAlarmsInput{}
+			if state, ok := p.Args["state"].(string); ok && state != "" {
+				input.StateValue = aws.String(state)
+			}
+
+			if prefix, ok := p.Args["prefix"].(string); ok && prefix != "" {
+				input.AlarmNamePrefix = aws.String(prefix)
+			}
+
+			return c.resolver.GetAlarms(p.Context, user, queryContext.Region, input)
+		},
+	}
+}
+
+// importAlarms creates a cloudwatch check for each of the region's alarms with
+// the names, or only returns the checks it would create if dryRun is set.
+func (c *Composter) importAlarms() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(c.checkType),
+		Description: "Create checks that fail when CloudWatch alarms would alarm",
+		Args: graphql.FieldConfigArgument{
+			"region": &graphql.ArgumentConfig{
+				Description: "The alarms' region",
+				Type:        graphql.NewNonNull(graphql.String),
+			},
+			"alarmNames": &graphql.ArgumentConfig{
+				Description: "The names of the alarms to import",
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.String)),
+			},
+			"dryRun": &graphql.ArgumentConfig{
+				Description:  "Return the checks without creating them",
+				Type:         graphql.Boolean,
+				DefaultValue: false,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			// must have admin or edit to upsert checks
+			_, err := UserPermittedFromContext(p.Context, "admin", PermissionOp{"or", "edit"})
+			if err != nil {
+				return nil, err
+			}
+
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			region, _ := p.Args["region"].(string)
+			dryRun, _ := p.Args["dryRun"].(bool)
+
+			var alarmNames []string
+			list, _ := p.Args["alarmNames"].([]interface{})
+			for _, n := range list {
+				if name, ok := n.(string); ok {
+					alarmNames = append(alarmNames, name)
+				}
+			}
+
+			return c.resolver.ImportAlarms(p.Context, user, region, alarmNames, dryRun)
+		},
+	}
+}
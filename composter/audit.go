@@ -0,0 +1,327 @@
+package composter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	log "github.com/opsee/logrus"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+
+	// Audit sink types, as configured.
+	AuditSinkStdout = "stdout"
+	AuditSinkFile   = "file"
+
+	// DefaultAuditRecent is how many events an AuditLog keeps in memory when
+	// it isn't told.
+	DefaultAuditRecent = 1000
+
+	auditRedacted = "[REDACTED]"
+)
+
+// AuditRedactedArgs are the names of the mutation arguments, and of the
+// fields of input objects, whose values aren't recorded. A name containing
+// any of them, ignoring case, is redacted.
+var AuditRedactedArgs = []string{"password", "secret", "token", "authorization", "credential", "headers"}
+
+// AuditRedactedPaths are the arguments and input object fields whose values
+// aren't recorded though their names are too common to redact everywhere,
+// each given as the mutation, argument and fields leading to it, without list
+// indexes. A notification's value may be a webhook url, but an assertion's
+// value is only a header or metric name.
+var AuditRedactedPaths = []string{
+	"notifications.default.value",
+	"checks.checks.notifications.value",
+	"testCheck.check.notifications.value",
+}
+
+// AuditSinkTypes are the types of sink that can be configured.
+var AuditSinkTypes = []string{AuditSinkStdout, AuditSinkFile}
+
+// AuditEvent records a mutation run through compost.
+type AuditEvent struct {
+	Time        time.Time              `json:"time"`
+	RequestorId int32                  `json:"requestor_id"`
+	CustomerId  string                 `json:"customer_id"`
+	Mutation    string                 `json:"mutation"`
+	Region      string                 `json:"region,omitempty"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	Outcome     string                 `json:"outcome"`
+	Error       string                 `json:"error,omitempty"`
+	ErrorCode   resolver.ErrorCode     `json:"error_code,omitempty"`
+	DurationMs  float64                `json:"duration_ms"`
+}
+
+// AuditSink stores audit events somewhere durable.
+type AuditSink interface {
+	Write(event *AuditEvent) error
+	Close() error
+}
+
+// jsonAuditSink writes each event as a line of JSON.
+type jsonAuditSink struct {
+	mut     sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewWriterAuditSink returns a sink writing JSON lines to w, such as
+// os.Stdout. Closing the sink doesn't close w.
+func NewWriterAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{encoder: json.NewEncoder(w)}
+}
+
+// NewFileAuditSink returns a sink appending JSON lines to the file at path,
+// which is created if it doesn't exist.
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonAuditSink{encoder: json.NewEncoder(file), closer: file}, nil
+}
+
+func (s *jsonAuditSink) Write(event *AuditEvent) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.encoder.Encode(event)
+}
+
+func (s *jsonAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// AuditFilter selects audit events. Empty fields match every event.
+type AuditFilter struct {
+	CustomerId string
+	Mutation   string
+	Since      time.Time
+}
+
+// AuditLog records mutations to its sinks, and keeps the most recent events
+// in memory to be queried. It is safe for concurrent use.
+type AuditLog struct {
+	sinks  []AuditSink
+	recent int
+
+	mut    sync.Mutex
+	events []*AuditEvent
+	next   int
+}
+
+// NewAuditLog returns a log keeping the last recent events in memory, or
+// DefaultAuditRecent if recent is zero, and writing every event to sinks.
+func NewAuditLog(recent int, sinks ...AuditSink) *AuditLog {
+	if recent <= 0 {
+		recent = DefaultAuditRecent
+	}
+
+	return &AuditLog{
+		sinks:  sinks,
+		recent: recent,
+	}
+}
+
+// Record writes event to each sink. A sink that fails is logged and
+// doesn't stop the others.
+func (a *AuditLog) Record(event *AuditEvent) {
+	a.mut.Lock()
+	if len(a.events) < a.recent {
+		a.events = append(a.events, event)
+	} else {
+		a.events[a.next] = event
+	}
+	a.next = (a.next + 1) % a.recent
+	a.mut.Unlock()
+
+	for _, sink := range a.sinks {
+		if err := sink.Write(event); err != nil {
+			log.WithError(err).WithField("mutation", event.Mutation).Error("error writing audit event")
+		}
+	}
+}
+
+// Events returns up to limit of the recent events matching filter, most
+// recent first. A zero limit returns every match.
+func (a *AuditLog) Events(filter AuditFilter, limit int) []*AuditEvent {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	var events []*AuditEvent
+	for i := 1; i <= len(a.events); i++ {
+		event := a.events[(a.next-i+len(a.events))%len(a.events)]
+
+		if (filter.CustomerId != "" && event.CustomerId != filter.CustomerId) ||
+			(filter.Mutation != "" && event.Mutation != filter.Mutation) ||
+			event.Time.Before(filter.Since) {
+			continue
+		}
+
+		events = append(events, event)
+		if limit > 0 && len(events) == limit {
+			break
+		}
+	}
+
+	return events
+}
+
+// Close closes each sink, returning the first error.
+func (a *AuditLog) Close() error {
+	var first error
+	for _, sink := range a.sinks {
+		if err := sink.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// auditMutations records each mutation of schema to the audit log. Root
+// mutations returning an object of further mutations, like region, are
+// recorded as those mutations, like region.rebootInstances, and only
+// recorded themselves when they fail, so that denied requests are kept.
+func (c *Composter) auditMutations(schema graphql.Schema) {
+	mutation := schema.MutationType()
+	if mutation == nil {
+		return
+	}
+
+	for name, field := range mutation.Fields() {
+		nested, ok := field.Type.(*graphql.Object)
+		if !ok || !strings.HasSuffix(nested.Name(), "Mutation") {
+			c.auditField(mutation, field, name, false)
+			continue
+		}
+
+		for nestedName, nestedField := range nested.Fields() {
+			c.auditField(nested, nestedField, name+"."+nestedName, false)
+		}
+		c.auditField(mutation, field, name, true)
+	}
+}
+
+// auditField replaces a field's resolver with one recording it as mutation.
+func (c *Composter) auditField(object *graphql.Object, field *graphql.FieldDefinition, mutation string, failuresOnly bool) {
+	if field.Resolve == nil {
+		return
+	}
+
+	object.AddFieldConfig(field.Name, &graphql.Field{
+		Name:              field.Name,
+		Description:       field.Description,
+		Type:              field.Type,
+		Args:              fieldConfigArgs(field.Args),
+		Resolve:           c.auditingResolve(mutation, field.Resolve, failuresOnly),
+		DeprecationReason: field.DeprecationReason,
+	})
+}
+
+// auditingResolve records mutation each time resolve runs, including when it
+// panics, which graphql-go turns into an error after the event is recorded.
+func (c *Composter) auditingResolve(mutation string, resolve graphql.FieldResolveFn, failuresOnly bool) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				c.audit(p, mutation, start, fmt.Errorf("%v", r))
+				panic(r)
+			}
+		}()
+
+		result, err := resolve(p)
+		if err != nil || !failuresOnly {
+			c.audit(p, mutation, start, err)
+		}
+		return result, err
+	}
+}
+
+// audit records a mutation that started at start and returned err.
+func (c *Composter) audit(p graphql.ResolveParams, mutation string, start time.Time, err error) {
+	event := &AuditEvent{
+		Time:       start,
+		Mutation:   mutation,
+		Arguments:  redactArgs(mutation, p.Args).(map[string]interface{}),
+		Outcome:    AuditSuccess,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	if user, ok := p.Context.Value(userKey).(*schema.User); ok && user != nil {
+		event.RequestorId = user.Id
+		event.CustomerId = user.CustomerId
+	}
+
+	if queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext); ok {
+		event.Region = queryContext.Region
+	}
+
+	if err != nil {
+		event.Outcome = AuditFailure
+		event.Error = err.Error()
+		event.ErrorCode = resolver.ErrorOf(err).Code
+	}
+
+	c.config.AuditLog.Record(event)
+}
+
+// redactArgs returns a copy of args, found at path, without the values of
+// AuditRedactedArgs and AuditRedactedPaths.
+func redactArgs(path string, args interface{}) interface{} {
+	switch args := args.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(args))
+		for name, value := range args {
+			if isRedactedArg(name) || isRedactedPath(path+"."+name) {
+				redacted[name] = auditRedacted
+			} else {
+				redacted[name] = redactArgs(path+"."+name, value)
+			}
+		}
+		return redacted
+
+	case []interface{}:
+		redacted := make([]interface{}, len(args))
+		for i, value := range args {
+			redacted[i] = redactArgs(path, value)
+		}
+		return redacted
+	}
+
+	return args
+}
+
+func isRedactedPath(path string) bool {
+	for _, r := range AuditRedactedPaths {
+		if path == r {
+			return true
+		}
+	}
+	return false
+}
+
+func isRedactedArg(name string) bool {
+	name = strings.ToLower(name)
+	for _, r := range AuditRedactedArgs {
+		if strings.Contains(name, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package composter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestAuditLog(t *testing.T) {
+	var out bytes.Buffer
+	c := New(&resolver.Client{}, Config{AuditLog: NewAuditLog(2, NewWriterAuditSink(&out))})
+
+	ctx := context.WithValue(context.Background(), userKey, &schema.User{Id: 7, CustomerId: "customer-1"})
+
+	for _, query := range []string{
+		`mutation notify { notifications(default: [{type: "slack_hook", value: "https://hooks.slack.com/secret"}]) { type } }`,
+		`mutation reboot { region(id: "us-west-2") { rebootInstances(ids: ["i-1"]) } }`,
+		`mutation remove { deleteChecks(ids: ["check-1"]) }`,
+	} {
+		_, err := c.Compost(context.WithValue(ctx, requestKey, &GraphQLRequest{Query: query}), c.Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the log keeps the last 2 events in memory, and its sink all 3
+	events := c.config.AuditLog.Events(AuditFilter{}, 0)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "deleteChecks", events[0].Mutation)
+		assert.Equal(t, "region", events[1].Mutation)
+		assert.Equal(t, map[string]interface{}{"id": "us-west-2"}, events[1].Arguments)
+	}
+
+	assert.Len(t, c.config.AuditLog.Events(AuditFilter{Mutation: "region"}, 0), 1)
+	assert.Empty(t, c.config.AuditLog.Events(AuditFilter{CustomerId: "customer-2"}, 0))
+
+	var notify AuditEvent
+	if err := json.NewDecoder(&out).Decode(&notify); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "notifications", notify.Mutation)
+	assert.EqualValues(t, 7, notify.RequestorId)
+	assert.Equal(t, "customer-1", notify.CustomerId)
+	assert.Equal(t, AuditFailure, notify.Outcome)
+	assert.Equal(t, resolver.ErrorForbidden, notify.ErrorCode)
+	assert.Equal(t, map[string]interface{}{
+		"default": []interface{}{map[string]interface{}{"type": "slack_hook", "value": auditRedacted}},
+	}, notify.Arguments)
+}
+
+func TestAuditRedactArgs(t *testing.T) {
+	args := map[string]interface{}{
+		"checks": []interface{}{
+			map[string]interface{}{
+				"name": "web",
+				"assertions": []interface{}{
+					map[string]interface{}{"key": "header", "value": "Content-Type", "relationship": "equal", "operand": "text/html"},
+				},
+				"notifications": []interface{}{
+					map[string]interface{}{"type": "slack_hook", "value": "https://hooks.slack.com/secret"},
+				},
+				"http_check": map[string]interface{}{
+					"headers": []interface{}{map[string]interface{}{"name": "Authorization", "values": []interface{}{"Bearer x"}}},
+				},
+			},
+		},
+	}
+
+	// notifications' values are redacted, but assertions' aren't
+	assert.Equal(t, map[string]interface{}{
+		"checks": []interface{}{
+			map[string]interface{}{
+				"name": "web",
+				"assertions": []interface{}{
+					map[string]interface{}{"key": "header", "value": "Content-Type", "relationship": "equal", "operand": "text/html"},
+				},
+				"notifications": []interface{}{
+					map[string]interface{}{"type": "slack_hook", "value": auditRedacted},
+				},
+				"http_check": map[string]interface{}{
+					"headers": auditRedacted,
+				},
+			},
+		},
+	}, redactArgs("checks", args))
+}
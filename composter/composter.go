@@ -4,7 +4,15 @@ package composter
 
 import (
 	"errors"
+	"net/http"
+	"sync"
+	"time"
+
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
 	"github.com/opsee/basic/tp"
 	"github.com/opsee/compost/resolver"
 	"golang.org/x/net/context"
@@ -14,51 +22,176 @@ const (
 	userKey = iota
 	requestKey
 	queryContextKey
+	fieldErrorsKey
+	subscriptionStartKey
 )
 
 var (
 	errDecodeRequest = errors.New("error decoding request from context")
-	errNoQuery       = errors.New("query not provided")
+	errNoQuery       = resolver.NewError(resolver.ErrorInvalidInput, "query not provided")
+
+	// DefaultCORSOrigins are the origin patterns allowed when a Config doesn't
+	// list any.
+	DefaultCORSOrigins = []string{`https?://localhost:8080`, `https?://localhost:8008`, `https://(.+)?(opsy\.co|opsee\.co|opsee\.com)`, `https?://coreys-mbp-8:\d+`}
 )
 
+const DefaultStaticDir = "/static"
+
+// Config holds the settings of the composter's http server. Zero values are
+// replaced by defaults.
+type Config struct {
+	// CORSOrigins are regular expressions matching the origins allowed to
+	// make cross-origin requests.
+	CORSOrigins []string
+	// StaticDir is the directory served under /static.
+	StaticDir string
+	// PersistedQueries are the queries /graphql requests may send by hash.
+	// If nil, an empty registry in register mode is used.
+	PersistedQueries *PersistedQueries
+	// QueryLimits bound the depth and cost of /graphql requests.
+	QueryLimits QueryLimits
+	// AuditLog records the mutations run through either schema. If nil, a
+	// log keeping recent events in memory alone is used.
+	AuditLog *AuditLog
+	// TLS configures the server's TLS, and the client certificates accepted
+	// by /admin/graphql.
+	TLS TLSConfig
+}
+
 type Composter struct {
 	Schema      graphql.Schema
 	AdminSchema graphql.Schema
-	resolver    *resolver.Client
-	router      *tp.Router
+	// SubscriptionSchema is Schema's subscription root. graphql-go can't
+	// execute subscriptions, so it's kept apart for Subscribe to run.
+	SubscriptionSchema graphql.Schema
+	resolver           *resolver.Client
+	router             *tp.Router
+	config             Config
+
+	// The object types whose fields resolve through resolver are built for
+	// each composter, rather than shared with every other.
+	instanceType         *graphql.Object
+	dbInstanceType       *graphql.Object
+	ecsServiceType       *graphql.Object
+	loadBalancerType     *graphql.Object
+	autoScalingGroupType *graphql.Object
+	checkType            *graphql.Object
+	// metricObjects are the catalog's metrics types by namespace.
+	metricObjects map[string]*graphql.Object
+
+	// ctx is the root of every request's context. It's cancelled when
+	// Shutdown gives up waiting for requests to finish, or by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// subscriptionsCtx is the root of every subscription's context. It's
+	// cancelled as soon as Shutdown is called, since subscriptions don't
+	// finish on their own.
+	subscriptionsCtx    context.Context
+	cancelSubscriptions context.CancelFunc
+
+	serverMut sync.Mutex
+	server    *http.Server
+	closed    bool
 }
 
-func New(resolver *resolver.Client) *Composter {
+func New(resolver *resolver.Client, config Config) *Composter {
+	if len(config.CORSOrigins) == 0 {
+		config.CORSOrigins = DefaultCORSOrigins
+	}
+
+	if config.StaticDir == "" {
+		config.StaticDir = DefaultStaticDir
+	}
+
+	if config.PersistedQueries == nil {
+		config.PersistedQueries = NewPersistedQueries(PersistedQueryConfig{})
+	}
+
+	if config.AuditLog == nil {
+		config.AuditLog = NewAuditLog(DefaultAuditRecent)
+	}
+
 	composter := &Composter{
 		resolver: resolver,
+		config:   config,
 	}
+	composter.ctx, composter.cancel = context.WithCancel(context.Background())
+	composter.subscriptionsCtx, composter.cancelSubscriptions = context.WithCancel(composter.ctx)
 
 	composter.mustSchema()
 	composter.initHTTP()
 	return composter
 }
 
-func (c *Composter) Compost(ctx context.Context, schema graphql.Schema) (*graphql.Result, error) {
+// Compost runs the request in ctx against schema.
+func (c *Composter) Compost(ctx context.Context, schema graphql.Schema) (*Result, error) {
+	return c.compost(ctx, schema, QueryLimits{})
+}
+
+// compost runs the request in ctx against schema, unless it exceeds limits.
+func (c *Composter) compost(ctx context.Context, schema graphql.Schema, limits QueryLimits) (*Result, error) {
 	request, ok := ctx.Value(requestKey).(*GraphQLRequest)
 	if !ok {
 		return nil, errDecodeRequest
 	}
 
+	start := time.Now()
+	doc, result := c.run(ctx, schema, limits, request)
+	c.observeRequest(schema, doc, start, result)
+
+	return result, nil
+}
+
+// run parses, validates and executes request, returning its document, or nil
+// if it couldn't be parsed, and its result.
+func (c *Composter) run(ctx context.Context, schema graphql.Schema, limits QueryLimits, request *GraphQLRequest) (*ast.Document, *Result) {
+	if request.Query == "" {
+		return nil, errorResult(errNoQuery)
+	}
+
+	doc, err := parseRequest(request.Query)
+	if err != nil {
+		return nil, newResult(&graphql.Result{Errors: gqlerrors.FormatErrors(err)}, &fieldErrors{})
+	}
+
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		return doc, newResult(&graphql.Result{Errors: validation.Errors}, &fieldErrors{})
+	}
+
+	if err := limits.checkLimits(schema, doc, request.Variables); err != nil {
+		return doc, errorResult(err)
+	}
+
+	return doc, c.execute(ctx, schema, doc, request, nil)
+}
+
+// execute runs a parsed request, resolving its root fields from root.
+func (c *Composter) execute(ctx context.Context, schema graphql.Schema, doc *ast.Document, request *GraphQLRequest, root interface{}) *Result {
+	errs := &fieldErrors{}
 	ctx = context.WithValue(ctx, queryContextKey, &QueryContext{})
+	ctx = context.WithValue(ctx, fieldErrorsKey, errs)
+	ctx = resolver.WithLoader(ctx, resolver.NewLoader())
 
-	response := graphql.Do(graphql.Params{
-		Schema:         schema,
-		RequestString:  request.Query,
-		VariableValues: request.Variables,
-		Context:        ctx,
-	})
+	return newResult(graphql.Execute(graphql.ExecuteParams{
+		Schema:  schema,
+		Root:    root,
+		AST:     doc,
+		Args:    request.Variables,
+		Context: ctx,
+	}), errs)
+}
 
-	return response, nil
+func parseRequest(query string) (*ast.Document, error) {
+	return parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: query,
+		Name: "GraphQL request",
+	})})
 }
 
 type GraphQLRequest struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables,omitempty"`
+	Query      string                 `json:"query"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Extensions *RequestExtensions     `json:"extensions,omitempty"`
 }
 
 type QueryContext struct {
@@ -67,7 +200,12 @@ type QueryContext struct {
 }
 
 func (req *GraphQLRequest) Validate() error {
-	if req.Query == "" {
+	hash, err := req.persistedHash()
+	if err != nil {
+		return err
+	}
+
+	if req.Query == "" && hash == "" {
 		return errNoQuery
 	}
 
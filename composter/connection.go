@@ -0,0 +1,316 @@
+package composter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/basic/schema"
+	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
+	opsee_aws_rds "github.com/opsee/basic/schema/aws/rds"
+	"github.com/opsee/compost/resolver"
+)
+
+const (
+	// DefaultPageSize is the page size of a connection given neither first
+	// nor last.
+	DefaultPageSize = DefaultListSize
+
+	// MaxPageSize is the most items a connection returns at once. A larger
+	// first or last is reduced to it.
+	MaxPageSize = 100
+)
+
+var (
+	errInvalidCursor = resolver.NewError(resolver.ErrorInvalidInput, "invalid cursor")
+	errNegativePage  = resolver.NewError(resolver.ErrorInvalidInput, "first and last must not be negative")
+
+	PageInfoType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PageInfo",
+		Description: "Information about a page of a connection",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"hasPreviousPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"startCursor": &graphql.Field{
+				Type: graphql.String,
+			},
+			"endCursor": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	})
+)
+
+// connection is a Relay connection: one page of a list, with a cursor for
+// each item that can be passed back as after or before to page from it.
+// TotalCount is nil where the number of items isn't known.
+type connection struct {
+	Edges      []*edge     `json:"edges"`
+	PageInfo   pageInfo    `json:"pageInfo"`
+	TotalCount interface{} `json:"totalCount"`
+}
+
+type edge struct {
+	Node   interface{} `json:"node"`
+	Cursor string      `json:"cursor"`
+}
+
+type pageInfo struct {
+	HasNextPage     bool        `json:"hasNextPage"`
+	HasPreviousPage bool        `json:"hasPreviousPage"`
+	StartCursor     interface{} `json:"startCursor"`
+	EndCursor       interface{} `json:"endCursor"`
+}
+
+// connectionType returns a connection type of nodes named name. The node
+// type may be an object or a union.
+func connectionType(name string, nodeType graphql.Output) *graphql.Object {
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        name + "Edge",
+		Description: fmt.Sprintf("A %s and its cursor", name),
+		Fields: graphql.Fields{
+			"node": &graphql.Field{
+				Type: nodeType,
+			},
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        name + "Connection",
+		Description: fmt.Sprintf("A page of %ss", name),
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewList(edgeType),
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(PageInfoType),
+			},
+			"totalCount": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "The number of items across every page, if known",
+			},
+		},
+	})
+}
+
+// connectionArgs adds the Relay paging arguments to args.
+func connectionArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["first"] = &graphql.ArgumentConfig{
+		Description: "Return at most this many items from the start of the page",
+		Type:        graphql.Int,
+	}
+	args["after"] = &graphql.ArgumentConfig{
+		Description: "Return items after this cursor",
+		Type:        graphql.String,
+	}
+	args["last"] = &graphql.ArgumentConfig{
+		Description: "Return at most this many items from the end of the page",
+		Type:        graphql.Int,
+	}
+	args["before"] = &graphql.ArgumentConfig{
+		Description: "Return items before this cursor",
+		Type:        graphql.String,
+	}
+	return args
+}
+
+// newConnection pages nodes, a slice, according to the Relay arguments in
+// args, returning at most MaxPageSize of them, or DefaultPageSize if neither
+// first nor last is given. nodes must be sorted by position, a string that
+// places each node in the connection's order, such as its key. Each node's
+// cursor encodes kind and the node's position, so paging from a node that no
+// longer exists resumes where it was, and a cursor can't be used with another
+// kind of connection.
+func newConnection(args map[string]interface{}, kind string, nodes interface{}, position func(interface{}) string) (*connection, error) {
+	var (
+		items     = toSlice(nodes)
+		positions = make([]string, len(items))
+	)
+
+	for i, item := range items {
+		positions[i] = position(item)
+	}
+
+	start, end := 0, len(items)
+
+	if after, ok := args["after"].(string); ok && after != "" {
+		pos, err := decodeCursor(kind, after)
+		if err != nil {
+			return nil, err
+		}
+		start = sort.Search(len(positions), func(i int) bool { return positions[i] > pos })
+	}
+
+	if before, ok := args["before"].(string); ok && before != "" {
+		pos, err := decodeCursor(kind, before)
+		if err != nil {
+			return nil, err
+		}
+		end = sort.Search(len(positions), func(i int) bool { return positions[i] >= pos })
+	}
+
+	if end < start {
+		end = start
+	}
+
+	first, hasFirst, last, hasLast, err := pageSizes(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasFirst && end-start > first {
+		end = start + first
+	}
+
+	if hasLast && end-start > last {
+		start = end - last
+	}
+
+	conn := &connection{
+		Edges:      make([]*edge, 0, end-start),
+		TotalCount: len(items),
+		PageInfo: pageInfo{
+			HasPreviousPage: start > 0,
+			HasNextPage:     end < len(items),
+		},
+	}
+
+	for i := start; i < end; i++ {
+		conn.Edges = append(conn.Edges, &edge{Node: items[i], Cursor: encodeCursor(kind, positions[i])})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
+
+// pageSizes returns the first and last arguments in args, reduced to
+// MaxPageSize. first is DefaultPageSize if neither is given.
+func pageSizes(args map[string]interface{}) (first int, hasFirst bool, last int, hasLast bool, err error) {
+	first, hasFirst = args["first"].(int)
+	last, hasLast = args["last"].(int)
+
+	if !hasFirst && !hasLast {
+		first, hasFirst = DefaultPageSize, true
+	}
+
+	if first < 0 || last < 0 {
+		return 0, false, 0, false, errNegativePage
+	}
+
+	if first > MaxPageSize {
+		first = MaxPageSize
+	}
+	if last > MaxPageSize {
+		last = MaxPageSize
+	}
+
+	return first, hasFirst, last, hasLast, nil
+}
+
+func encodeCursor(kind, position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + ":" + position))
+}
+
+// decodeCursor returns the position encoded in cursor, which must be one of a
+// kind connection's.
+func decodeCursor(kind, cursor string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), kind+":") {
+		return "", resolver.Errorf(resolver.ErrorInvalidInput, "%s: not a %s cursor", errInvalidCursor, kind)
+	}
+
+	return strings.TrimPrefix(string(decoded), kind+":"), nil
+}
+
+// toSlice returns the elements of a slice of any type.
+func toSlice(slice interface{}) []interface{} {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+
+	return items
+}
+
+// sortByKey returns the elements of nodes, a slice, sorted by key. AWS
+// doesn't promise to describe resources in any order, so their connections
+// are sorted to keep pages stable between requests.
+func sortByKey(nodes interface{}, key func(interface{}) string) []interface{} {
+	items := toSlice(nodes)
+	sort.Sort(byKey{items, key})
+	return items
+}
+
+type byKey struct {
+	items []interface{}
+	key   func(interface{}) string
+}
+
+func (b byKey) Len() int           { return len(b.items) }
+func (b byKey) Swap(i, j int)      { b.items[i], b.items[j] = b.items[j], b.items[i] }
+func (b byKey) Less(i, j int) bool { return b.key(b.items[i]) < b.key(b.items[j]) }
+
+// checkKey identifies a check in a check connection.
+func checkKey(node interface{}) string {
+	if check, ok := node.(*schema.Check); ok {
+		return check.Id
+	}
+	return ""
+}
+
+// customerKey identifies a customer in a customer connection.
+func customerKey(node interface{}) string {
+	if customer, ok := node.(*schema.Customer); ok {
+		return customer.Id
+	}
+	return ""
+}
+
+// instanceKey identifies an instance in an instance connection.
+func instanceKey(node interface{}) string {
+	switch t := node.(type) {
+	case *opsee_aws_ec2.Instance:
+		return "ec2/" + aws.StringValue(t.InstanceId)
+	case *opsee_aws_rds.DBInstance:
+		return "rds/" + aws.StringValue(t.DBInstanceIdentifier)
+	}
+	return ""
+}
+
+// groupKey identifies a group in a group connection.
+func groupKey(node interface{}) string {
+	switch t := node.(type) {
+	case *opsee_aws_ec2.SecurityGroup:
+		return "security/" + aws.StringValue(t.GroupId)
+	case *opsee_aws_ecs.Service:
+		return "ecs_service/" + aws.StringValue(t.ServiceArn)
+	case *opsee_aws_elb.LoadBalancerDescription:
+		return "elb/" + aws.StringValue(t.LoadBalancerName)
+	case *opsee_aws_autoscaling.Group:
+		return "autoscaling/" + aws.StringValue(t.AutoScalingGroupName)
+	}
+	return ""
+}
@@ -0,0 +1,323 @@
+package composter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/opsee/basic/schema"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestConnection(t *testing.T) {
+	var (
+		nodes = []string{"a", "b", "c", "d", "e"}
+		key   = func(node interface{}) string { return node.(string) }
+		page  = func(args map[string]interface{}) ([]interface{}, pageInfo) {
+			conn, err := newConnection(args, "letter", nodes, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var page []interface{}
+			for _, e := range conn.Edges {
+				page = append(page, e.Node)
+			}
+			return page, conn.PageInfo
+		}
+	)
+
+	first, info := page(map[string]interface{}{"first": 2})
+	assert.Equal(t, []interface{}{"a", "b"}, first)
+	assert.True(t, info.HasNextPage)
+	assert.False(t, info.HasPreviousPage)
+
+	next, info := page(map[string]interface{}{"first": 2, "after": info.EndCursor})
+	assert.Equal(t, []interface{}{"c", "d"}, next)
+	assert.True(t, info.HasNextPage)
+	assert.True(t, info.HasPreviousPage)
+
+	last, info := page(map[string]interface{}{"last": 2, "before": encodeCursor("letter", "e")})
+	assert.Equal(t, []interface{}{"c", "d"}, last)
+
+	_, err := newConnection(map[string]interface{}{"after": encodeCursor("number", "1")}, "letter", nodes, key)
+	assert.EqualError(t, err, "invalid cursor: not a letter cursor")
+
+	// paging from a node that's gone resumes where it was
+	nodes = []string{"a", "b", "d", "e"}
+	next, info = page(map[string]interface{}{"first": 2, "after": encodeCursor("letter", "c")})
+	assert.Equal(t, []interface{}{"d", "e"}, next)
+	assert.True(t, info.HasPreviousPage)
+
+	last, info = page(map[string]interface{}{"last": 2, "before": encodeCursor("letter", "c")})
+	assert.Equal(t, []interface{}{"a", "b"}, last)
+	assert.True(t, info.HasNextPage)
+
+	next, _ = page(map[string]interface{}{"after": encodeCursor("letter", "z")})
+	assert.Empty(t, next)
+
+	// connections without a page size return a default page, and none
+	// return more than the maximum
+	many := make([]int, MaxPageSize+50)
+	conn, err := newConnection(map[string]interface{}{}, "number", many, func(node interface{}) string { return "" })
+	if assert.NoError(t, err) {
+		assert.Len(t, conn.Edges, DefaultPageSize)
+		assert.True(t, conn.PageInfo.HasNextPage)
+		assert.Equal(t, len(many), conn.TotalCount)
+	}
+
+	conn, err = newConnection(map[string]interface{}{"first": len(many)}, "number", many, func(node interface{}) string { return "" })
+	if assert.NoError(t, err) {
+		assert.Len(t, conn.Edges, MaxPageSize)
+	}
+}
+
+func TestCheckConnectionOrder(t *testing.T) {
+	checks := func(ids ...string) []*schema.Check {
+		list := make([]*schema.Check, len(ids))
+		for i, id := range ids {
+			list[i] = &schema.Check{Id: id}
+		}
+		return list
+	}
+
+	page := func(args map[string]interface{}, list []*schema.Check) ([]string, pageInfo) {
+		conn, err := newConnection(args, "check", sortByKey(list, checkKey), checkKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		for _, e := range conn.Edges {
+			ids = append(ids, e.Node.(*schema.Check).Id)
+		}
+		return ids, conn.PageInfo
+	}
+
+	// bartnet may list the checks in a different order for each page
+	first, info := page(map[string]interface{}{"first": 2}, checks("c", "a", "d", "b"))
+	assert.Equal(t, []string{"a", "b"}, first)
+
+	next, info := page(map[string]interface{}{"first": 2, "after": info.EndCursor}, checks("d", "b", "a", "c"))
+	assert.Equal(t, []string{"c", "d"}, next)
+	assert.False(t, info.HasNextPage)
+}
+
+func TestOrderedConnectionResume(t *testing.T) {
+	instance := func(id, name string) *opsee_aws_ec2.Instance {
+		inst := &opsee_aws_ec2.Instance{InstanceId: aws.String(id)}
+		if name != "" {
+			inst.Tags = []*opsee_aws_ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}}
+		}
+		return inst
+	}
+
+	page := func(args map[string]interface{}, list ...*opsee_aws_ec2.Instance) ([]string, pageInfo) {
+		nodes, kind, position := orderResources(args, "instance", sortByKey(list, instanceKey), instanceKey)
+		conn, err := newConnection(args, kind, nodes, position)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, e := range conn.Edges {
+			ids = append(ids, aws.StringValue(e.Node.(*opsee_aws_ec2.Instance).InstanceId))
+		}
+		return ids, conn.PageInfo
+	}
+
+	for _, direction := range []string{orderAscending, orderDescending} {
+		orderBy := map[string]interface{}{"field": resolver.OrderByName, "direction": direction}
+
+		all := []*opsee_aws_ec2.Instance{
+			instance("i-1", "web"),
+			instance("i-2", "web-2"),
+			instance("i-3", "api"),
+			instance("i-4", "web"),
+			instance("i-5", ""),
+		}
+		want, _ := page(map[string]interface{}{"orderBy": orderBy, "first": len(all)}, all...)
+
+		// delete each node in turn, and page on from its cursor
+		for i := range want {
+			_, info := page(map[string]interface{}{"orderBy": orderBy, "first": i + 1}, all...)
+
+			var rest []*opsee_aws_ec2.Instance
+			for _, inst := range all {
+				if aws.StringValue(inst.InstanceId) != want[i] {
+					rest = append(rest, inst)
+				}
+			}
+
+			next, _ := page(map[string]interface{}{"orderBy": orderBy, "after": info.EndCursor}, rest...)
+			assert.Equal(t, want[i+1:], next, "%s after %s", direction, want[i])
+
+			prev, _ := page(map[string]interface{}{"orderBy": orderBy, "last": len(all), "before": info.EndCursor}, rest...)
+			assert.Equal(t, want[:i], prev, "%s before %s", direction, want[i])
+		}
+	}
+
+	// cursors from one order can't page another
+	_, info := page(map[string]interface{}{"first": 1}, instance("i-1", "web"))
+	_, err := newConnection(map[string]interface{}{"after": info.EndCursor}, "instance/name/DESC", nil, instanceKey)
+	assert.EqualError(t, err, "invalid cursor: not a instance/name/DESC cursor")
+}
+
+func TestCustomerConnection(t *testing.T) {
+	// more users than cats lists at once, three to a customer, so that
+	// customer-166 has users on both pages
+	backends := fake.New()
+	for i := 0; i < 600; i++ {
+		backends.Cats.AddUser(&schema.User{Id: int32(i + 1), CustomerId: fmt.Sprintf("customer-%03d", i/3), Status: "active"})
+	}
+
+	cats := &countingCats{CatsClient: backends.Cats}
+	be := backends.Backends()
+	be.Cats = cats
+
+	c := New(resolver.NewClientWithBackends(be), Config{})
+	ctx := context.WithValue(context.Background(), userKey, &schema.User{Id: 1000, Admin: true, Status: "active"})
+
+	query := func(args string) (ids []string, info pageInfo, total interface{}) {
+		q := `query customers { listCustomers` + args + ` { edges { node { id } } pageInfo { hasNextPage hasPreviousPage startCursor endCursor } totalCount } }`
+		doc, err := parseRequest(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := c.execute(ctx, c.AdminSchema, doc, &GraphQLRequest{Query: q}, nil)
+		assert.Empty(t, result.Errors)
+
+		// round trip the result through json for the connection's shape
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded struct {
+			ListCustomers struct {
+				Edges []struct {
+					Node struct {
+						Id string `json:"id"`
+					} `json:"node"`
+				} `json:"edges"`
+				PageInfo   pageInfo    `json:"pageInfo"`
+				TotalCount interface{} `json:"totalCount"`
+			} `json:"listCustomers"`
+		}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, e := range decoded.ListCustomers.Edges {
+			ids = append(ids, e.Node.Id)
+		}
+		return ids, decoded.ListCustomers.PageInfo, decoded.ListCustomers.TotalCount
+	}
+
+	// the first page of customers needs only the first page of users
+	first, info, total := query(`(first: 2)`)
+	assert.Equal(t, []string{"customer-000", "customer-001"}, first)
+	assert.True(t, info.HasNextPage)
+	assert.Nil(t, total)
+	assert.Equal(t, 1, cats.listUsersCalls())
+
+	next, info, _ := query(fmt.Sprintf(`(first: 2, after: %q)`, info.EndCursor))
+	assert.Equal(t, []string{"customer-002", "customer-003"}, next)
+	assert.True(t, info.HasPreviousPage)
+
+	last, _, _ := query(`(last: 1)`)
+	assert.Equal(t, []string{"customer-199"}, last)
+
+	// paging forward or back returns each customer once
+	want := make([]string, 200)
+	for i := range want {
+		want[i] = fmt.Sprintf("customer-%03d", i)
+	}
+
+	var forward []string
+	for args := `(first: 40)`; ; {
+		ids, info, _ := query(args)
+		forward = append(forward, ids...)
+		if !info.HasNextPage {
+			break
+		}
+		args = fmt.Sprintf(`(first: 40, after: %q)`, info.EndCursor)
+	}
+	assert.Equal(t, want, forward)
+
+	var backward []string
+	for args := `(last: 40)`; ; {
+		ids, info, _ := query(args)
+		backward = append(ids, backward...)
+		if !info.HasPreviousPage {
+			break
+		}
+		args = fmt.Sprintf(`(last: 40, before: %q)`, info.StartCursor)
+	}
+	assert.Equal(t, want, backward)
+}
+
+// countingCats counts GetCheckResults and ListUsers calls.
+type countingCats struct {
+	opsee.CatsClient
+	mut       sync.Mutex
+	calls     int
+	listUsers int
+}
+
+func (c *countingCats) ListUsers(ctx context.Context, in *opsee.ListUsersRequest, opts ...grpc.CallOption) (*opsee.ListUsersResponse, error) {
+	c.mut.Lock()
+	c.listUsers++
+	c.mut.Unlock()
+	return c.CatsClient.ListUsers(ctx, in, opts...)
+}
+
+func (c *countingCats) listUsersCalls() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.listUsers
+}
+
+func (c *countingCats) GetCheckResults(ctx context.Context, in *opsee.GetCheckResultsRequest, opts ...grpc.CallOption) (*opsee.GetCheckResultsResponse, error) {
+	c.mut.Lock()
+	c.calls++
+	c.mut.Unlock()
+	return c.CatsClient.GetCheckResults(ctx, in, opts...)
+}
+
+func TestCheckConnectionResults(t *testing.T) {
+	f := fake.New()
+	for i := 0; i < 30; i++ {
+		check := f.Bartnet.AddCheck("customer-1", &schema.Check{Name: fmt.Sprintf("check-%d", i)})
+		f.Cats.AddResult(&schema.CheckResult{CheckId: check.Id, CustomerId: "customer-1", Passing: true})
+	}
+
+	backends := f.Backends()
+	cats := &countingCats{CatsClient: backends.Cats}
+	backends.Cats = cats
+	c := New(resolver.NewClientWithBackends(backends), Config{})
+
+	// only the page's checks have their results fetched
+	data := queryComposter(t, c, `query checks { checks(first: 3) { edges { node { id results { passing } } } } }`)
+	edges := data["checks"].(map[string]interface{})["edges"].([]interface{})
+	if assert.Len(t, edges, 3) {
+		for _, e := range edges {
+			node := e.(map[string]interface{})["node"].(map[string]interface{})
+			assert.Equal(t, []interface{}{map[string]interface{}{"passing": true}}, node["results"])
+		}
+	}
+	assert.Equal(t, 3, cats.calls)
+
+	// and none are fetched if they aren't selected
+	queryComposter(t, c, `query checks { checks(first: 3) { edges { node { id } } } }`)
+	assert.Equal(t, 3, cats.calls)
+}
@@ -0,0 +1,240 @@
+package composter
+
+import (
+	"fmt"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/resolver"
+	"golang.org/x/net/context"
+)
+
+// customersPerCatsPage is the number of users asked of cats for each page of
+// customers. Customer cursors are positions in those pages, so changing it
+// invalidates them.
+const customersPerCatsPage = 500
+
+// customerPosition places a customer in cats' listing of users: the page of
+// users it's found on, and its index among that page's customers, which are
+// sorted by id.
+type customerPosition struct {
+	page  int
+	index int
+}
+
+func (p customerPosition) before(q customerPosition) bool {
+	return p.page < q.page || p.page == q.page && p.index < q.index
+}
+
+func (p customerPosition) cursor() string {
+	return encodeCursor("customer", fmt.Sprintf("%d/%d", p.page, p.index))
+}
+
+func decodeCustomerCursor(cursor string) (customerPosition, error) {
+	position, err := decodeCursor("customer", cursor)
+	if err != nil {
+		return customerPosition{}, err
+	}
+
+	var p customerPosition
+	if _, err := fmt.Sscanf(position, "%d/%d", &p.page, &p.index); err != nil || p.page < 1 || p.index < 0 {
+		return customerPosition{}, resolver.Errorf(resolver.ErrorInvalidInput, "%s: not a customer cursor", errInvalidCursor)
+	}
+
+	return p, nil
+}
+
+// customerPager pages through cats' listing of users for their customers.
+// Cats only lists users, so a customer whose users are listed on more than one
+// page is found on each. A connection returns it only once, from the first
+// page it reads it on, and skips it on the pages it reads after that. Paging
+// from a cursor, the customers beside the cursor on its page were returned
+// already.
+type customerPager struct {
+	resolver  *resolver.Client
+	requestor *schema.User
+}
+
+// page returns the customers of a page of users, sorted by id, and the number
+// of users cats says it has. A page past the last is empty.
+func (p *customerPager) page(ctx context.Context, page int) ([]interface{}, int, error) {
+	resp, err := p.resolver.ListCustomers(ctx, &opsee.ListUsersRequest{
+		Requestor: p.requestor,
+		Page:      int32(page),
+		PerPage:   customersPerCatsPage,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sortByKey(resp.Customers, customerKey), int(resp.Total), nil
+}
+
+// lastPage returns the last page of users that isn't empty, or zero if there
+// are no users. Cats' total is only where it starts looking: the pages after
+// it are read until one is empty.
+func (p *customerPager) lastPage(ctx context.Context) (int, error) {
+	customers, total, err := p.page(ctx, 1)
+	if err != nil || len(customers) == 0 {
+		return 0, err
+	}
+
+	last := 1
+	if pages := (total + customersPerCatsPage - 1) / customersPerCatsPage; pages > last {
+		last = pages
+	}
+
+	for {
+		customers, _, err := p.page(ctx, last+1)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(customers) == 0 {
+			return last, nil
+		}
+
+		last++
+	}
+}
+
+// customerConnection pages customers in the order cats lists their users,
+// according to the Relay arguments in args, reading only as many pages of
+// users as the page of customers needs. Given last, it pages back from before,
+// or from the last page of users; otherwise it pages on from after, or from
+// the first page. The number of customers isn't known without reading every
+// page, so the connection has no TotalCount.
+func customerConnection(ctx context.Context, pager *customerPager, args map[string]interface{}) (*connection, error) {
+	var (
+		after, before       customerPosition
+		hasAfter, hasBefore bool
+		err                 error
+	)
+
+	if cursor, ok := args["after"].(string); ok && cursor != "" {
+		if after, err = decodeCustomerCursor(cursor); err != nil {
+			return nil, err
+		}
+		hasAfter = true
+	}
+
+	if cursor, ok := args["before"].(string); ok && cursor != "" {
+		if before, err = decodeCustomerCursor(cursor); err != nil {
+			return nil, err
+		}
+		hasBefore = true
+	}
+
+	first, hasFirst, last, hasLast, err := pageSizes(args)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(pos customerPosition) bool {
+		return (!hasAfter || after.before(pos)) && (!hasBefore || pos.before(before))
+	}
+
+	var (
+		conn = &connection{Edges: []*edge{}}
+		seen = make(map[string]bool)
+	)
+
+	add := func(customer interface{}, pos customerPosition) {
+		seen[customerKey(customer)] = true
+		conn.Edges = append(conn.Edges, &edge{Node: customer, Cursor: pos.cursor()})
+	}
+
+	if hasLast {
+		page := before.page
+		if !hasBefore {
+			if page, err = pager.lastPage(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+	backward:
+		for ; page >= 1 && (!hasAfter || page >= after.page); page-- {
+			customers, _, err := pager.page(ctx, page)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := len(customers) - 1; i >= 0; i-- {
+				pos := customerPosition{page, i}
+				if hasBefore && !pos.before(before) {
+					// returned with the pages after this one
+					seen[customerKey(customers[i])] = true
+					continue
+				}
+
+				if !inRange(pos) || seen[customerKey(customers[i])] {
+					continue
+				}
+
+				if len(conn.Edges) == last {
+					conn.PageInfo.HasPreviousPage = true
+					break backward
+				}
+
+				add(customers[i], pos)
+			}
+		}
+
+		for i, j := 0, len(conn.Edges)-1; i < j; i, j = i+1, j-1 {
+			conn.Edges[i], conn.Edges[j] = conn.Edges[j], conn.Edges[i]
+		}
+
+		conn.PageInfo.HasNextPage = hasBefore
+		if hasFirst && len(conn.Edges) > first {
+			conn.Edges = conn.Edges[:first]
+			conn.PageInfo.HasNextPage = true
+		}
+	} else {
+		page := 1
+		if hasAfter {
+			page = after.page
+		}
+
+	forward:
+		for ; !hasBefore || page <= before.page; page++ {
+			customers, _, err := pager.page(ctx, page)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(customers) == 0 {
+				break
+			}
+
+			for i, customer := range customers {
+				pos := customerPosition{page, i}
+				if hasAfter && !after.before(pos) {
+					// returned with the pages before this one
+					seen[customerKey(customer)] = true
+					continue
+				}
+
+				if !inRange(pos) || seen[customerKey(customer)] {
+					continue
+				}
+
+				if len(conn.Edges) == first {
+					conn.PageInfo.HasNextPage = true
+					break forward
+				}
+
+				add(customer, pos)
+			}
+		}
+
+		conn.PageInfo.HasPreviousPage = hasAfter
+		conn.PageInfo.HasNextPage = conn.PageInfo.HasNextPage || hasBefore
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
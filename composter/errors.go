@@ -0,0 +1,201 @@
+package composter
+
+import (
+	"math"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/opsee/compost/resolver"
+	"golang.org/x/net/context"
+)
+
+// Result is the response to a GraphQL request. It differs from graphql.Result
+// in that its errors may carry the path of the field they belong to.
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message    string                    `json:"message"`
+	Locations  []location.SourceLocation `json:"locations"`
+	Path       []interface{}             `json:"path,omitempty"`
+	Extensions *ErrorExtensions          `json:"extensions,omitempty"`
+}
+
+// ErrorExtensions classify an Error, so that clients needn't match its
+// message. RetryAfter is in whole seconds.
+type ErrorExtensions struct {
+	Code       resolver.ErrorCode `json:"code"`
+	Backend    string             `json:"backend,omitempty"`
+	Retryable  bool               `json:"retryable"`
+	RetryAfter int                `json:"retryAfter,omitempty"`
+}
+
+// fieldErrors collects the errors resolvers report for fields that still
+// resolve to a partial value, which graphql-go has no way to express, and the
+// errors resolvers return, which graphql-go reports by message only.
+type fieldErrors struct {
+	mut      sync.Mutex
+	errors   []*Error
+	returned []*Error
+}
+
+// addFieldError reports err against the field being resolved, without
+// failing it.
+func addFieldError(ctx context.Context, info graphql.ResolveInfo, err error) {
+	errs, ok := ctx.Value(fieldErrorsKey).(*fieldErrors)
+	if !ok {
+		return
+	}
+
+	errs.mut.Lock()
+	errs.errors = append(errs.errors, newFieldError(info, err))
+	errs.mut.Unlock()
+}
+
+// addReturnedError records an error a resolver returned for the field being
+// resolved.
+func addReturnedError(ctx context.Context, info graphql.ResolveInfo, err error) {
+	errs, ok := ctx.Value(fieldErrorsKey).(*fieldErrors)
+	if !ok {
+		return
+	}
+
+	errs.mut.Lock()
+	errs.returned = append(errs.returned, newFieldError(info, err))
+	errs.mut.Unlock()
+}
+
+func newFieldError(info graphql.ResolveInfo, err error) *Error {
+	fieldErr := &Error{
+		Message:    err.Error(),
+		Locations:  []location.SourceLocation{},
+		Path:       fieldPath(info),
+		Extensions: newErrorExtensions(resolver.ErrorOf(err)),
+	}
+
+	if len(info.FieldASTs) > 0 && info.FieldASTs[0].Loc != nil {
+		loc := info.FieldASTs[0].Loc
+		fieldErr.Locations = append(fieldErr.Locations, location.GetLocation(loc.Source, loc.Start))
+	}
+
+	return fieldErr
+}
+
+func newErrorExtensions(err *resolver.Error) *ErrorExtensions {
+	return &ErrorExtensions{
+		Code:       err.Code,
+		Backend:    err.Backend,
+		Retryable:  err.Retryable,
+		RetryAfter: int(math.Ceil(err.RetryAfter.Seconds())),
+	}
+}
+
+// newResult builds a Result from graphql-go's result and the field errors
+// reported while resolving it. Each of graphql-go's errors is replaced by the
+// error a resolver returned with the same message, if there is one.
+// Otherwise, it's a query graphql-go failed to parse or validate, if there's
+// no data, or a failure of graphql-go's own.
+func newResult(result *graphql.Result, errs *fieldErrors) *Result {
+	r := &Result{Data: result.Data}
+
+	errs.mut.Lock()
+	defer errs.mut.Unlock()
+
+	returned := errs.returned
+	for _, err := range result.Errors {
+		var matched *Error
+		for i, ret := range returned {
+			if ret != nil && ret.Message == err.Message {
+				matched, returned[i] = ret, nil
+				break
+			}
+		}
+
+		if matched == nil {
+			matched = formatError(err, result.Data == nil)
+		}
+
+		r.Errors = append(r.Errors, matched)
+	}
+
+	r.Errors = append(r.Errors, errs.errors...)
+
+	return r
+}
+
+func formatError(err gqlerrors.FormattedError, invalid bool) *Error {
+	code := resolver.ErrorInternal
+	if invalid {
+		code = resolver.ErrorInvalidInput
+	}
+
+	return &Error{
+		Message:    err.Message,
+		Locations:  err.Locations,
+		Extensions: &ErrorExtensions{Code: code},
+	}
+}
+
+var (
+	wrappedMut     sync.Mutex
+	wrappedObjects = make(map[*graphql.Object]bool)
+)
+
+// recordErrors wraps the resolvers of the schema's fields so that the errors
+// they return are recorded with their path and code. graphql-go rebuilds
+// field definitions from an object's config, so it's the config that's
+// replaced. Objects shared between schemas are only wrapped once.
+func recordErrors(schema graphql.Schema) {
+	wrappedMut.Lock()
+	defer wrappedMut.Unlock()
+
+	for _, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || wrappedObjects[object] {
+			continue
+		}
+		wrappedObjects[object] = true
+
+		for name, field := range object.Fields() {
+			if field.Resolve == nil {
+				continue
+			}
+
+			object.AddFieldConfig(name, &graphql.Field{
+				Name:              field.Name,
+				Description:       field.Description,
+				Type:              field.Type,
+				Args:              fieldConfigArgs(field.Args),
+				Resolve:           recordingResolve(field.Resolve),
+				DeprecationReason: field.DeprecationReason,
+			})
+		}
+	}
+}
+
+func recordingResolve(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		result, err := resolve(p)
+		if err != nil {
+			addReturnedError(p.Context, p.Info, err)
+		}
+		return result, err
+	}
+}
+
+func fieldConfigArgs(args []*graphql.Argument) graphql.FieldConfigArgument {
+	config := make(graphql.FieldConfigArgument, len(args))
+	for _, arg := range args {
+		config[arg.Name()] = &graphql.ArgumentConfig{
+			Type:         arg.Type,
+			DefaultValue: arg.DefaultValue,
+			Description:  arg.Description(),
+		}
+	}
+	return config
+}
@@ -0,0 +1,159 @@
+package composter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestFieldErrors(t *testing.T) {
+	groups := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Groups",
+		Fields: graphql.Fields{
+			"groups": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					addFieldError(p.Context, p.Info, errors.New("error fetching elb groups"))
+					return []string{"sg-123"}, nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"vpc": &graphql.Field{
+					Type: groups,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return struct{}{}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := &fieldErrors{}
+	result := newResult(graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ myVpc: vpc { ...vpcGroups } } fragment vpcGroups on Groups { groups }`,
+		Context:       context.WithValue(context.Background(), fieldErrorsKey, errs),
+	}), errs)
+
+	assert.Equal(t, map[string]interface{}{"myVpc": map[string]interface{}{"groups": []interface{}{"sg-123"}}}, result.Data)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, "error fetching elb groups", result.Errors[0].Message)
+		assert.Equal(t, []interface{}{"myVpc", "groups"}, result.Errors[0].Path)
+		assert.Len(t, result.Errors[0].Locations, 1)
+	}
+}
+
+func TestErrorExtensions(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"check": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, &resolver.Error{
+							Code:      resolver.ErrorUpstreamUnavailable,
+							Backend:   resolver.BackendCats,
+							Retryable: true,
+							Message:   "cats unavailable",
+						}
+					},
+				},
+				"region": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("boom")
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordErrors(schema)
+
+	do := func(query string) *Result {
+		errs := &fieldErrors{}
+		return newResult(graphql.Do(graphql.Params{
+			Schema:        schema,
+			RequestString: query,
+			Context:       context.WithValue(context.Background(), fieldErrorsKey, errs),
+		}), errs)
+	}
+
+	// fields resolve in no particular order, so neither do their errors
+	result := do(`{ check region }`)
+	if assert.Len(t, result.Errors, 2) {
+		extensions := make(map[string]*ErrorExtensions)
+		for _, e := range result.Errors {
+			if assert.Len(t, e.Path, 1) {
+				extensions[e.Path[0].(string)] = e.Extensions
+			}
+		}
+
+		assert.Equal(t, &ErrorExtensions{Code: resolver.ErrorUpstreamUnavailable, Backend: "cats", Retryable: true}, extensions["check"])
+		assert.Equal(t, &ErrorExtensions{Code: resolver.ErrorInternal}, extensions["region"])
+	}
+
+	result = do(`{ nope }`)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, resolver.ErrorInvalidInput, result.Errors[0].Extensions.Code)
+	}
+}
+
+// slowElbBezos holds up DescribeLoadBalancers for delay.
+type slowElbBezos struct {
+	opsee.BezosClient
+	delay time.Duration
+}
+
+func (b *slowElbBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	if in.GetElb_DescribeLoadBalancersInput() != nil {
+		time.Sleep(b.delay)
+	}
+	return b.BezosClient.Get(ctx, in, opts...)
+}
+
+func TestGroupTypeTimeoutExtensions(t *testing.T) {
+	f := fake.New()
+	backends := f.Backends()
+	backends.Bezos = &slowElbBezos{BezosClient: f.Bezos, delay: 100 * time.Millisecond}
+
+	client := resolver.NewClientWithBackends(backends)
+	client.FanOut = resolver.FanOutConfig{Timeout: 10 * time.Millisecond}
+
+	c := New(client, Config{})
+	ctx := context.WithValue(context.Background(), userKey, &schema.User{Id: 1, CustomerId: "customer-1", Status: "active"})
+
+	q := `{ region(id: "us-west-2") { vpc(id: "vpc-123") { groups { totalCount } } } }`
+	doc, err := parseRequest(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the elb groups time out, and the rest of the groups are returned
+	result := c.execute(ctx, c.Schema, doc, &GraphQLRequest{Query: q}, nil)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, &ErrorExtensions{Code: resolver.ErrorUpstreamUnavailable, Retryable: true}, result.Errors[0].Extensions)
+		assert.Contains(t, result.Errors[0].Message, "elb")
+	}
+}
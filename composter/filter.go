@@ -0,0 +1,148 @@
+package composter
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/compost/resolver"
+)
+
+const (
+	orderAscending  = "ASC"
+	orderDescending = "DESC"
+)
+
+var (
+	ResourceFilterInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "ResourceFilter",
+		Description: "Narrows instances or groups. Empty fields match everything",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"tagKey": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only resources with a tag with this key",
+			},
+			"tagValue": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only resources with a tag with this value, or with tagKey and this value",
+			},
+			"state": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only resources in this state (running, available, active...)",
+			},
+			"instanceType": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only instances of this type or class (t2.micro, db.m3.large...)",
+			},
+			"namePrefix": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only resources whose name starts with this",
+			},
+			"availabilityZone": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "Only resources in this availability zone",
+			},
+		},
+	})
+
+	ResourceOrderFieldEnumType = graphql.NewEnum(graphql.EnumConfig{
+		Name:        "ResourceOrderField",
+		Description: "A field instances or groups can be ordered by",
+		Values: graphql.EnumValueConfigMap{
+			"id": &graphql.EnumValueConfig{
+				Value: resolver.OrderById,
+			},
+			"name": &graphql.EnumValueConfig{
+				Value: resolver.OrderByName,
+			},
+			"state": &graphql.EnumValueConfig{
+				Value: resolver.OrderByState,
+			},
+			"instance_type": &graphql.EnumValueConfig{
+				Value: resolver.OrderByInstanceType,
+			},
+			"availability_zone": &graphql.EnumValueConfig{
+				Value: resolver.OrderByAvailabilityZone,
+			},
+		},
+	})
+
+	OrderDirectionEnumType = graphql.NewEnum(graphql.EnumConfig{
+		Name: "OrderDirection",
+		Values: graphql.EnumValueConfigMap{
+			orderAscending: &graphql.EnumValueConfig{
+				Value: orderAscending,
+			},
+			orderDescending: &graphql.EnumValueConfig{
+				Value: orderDescending,
+			},
+		},
+	})
+
+	ResourceOrderInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "ResourceOrder",
+		Description: "Orders instances or groups. Those without the field come last",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"field": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(ResourceOrderFieldEnumType),
+			},
+			"direction": &graphql.InputObjectFieldConfig{
+				Type:        OrderDirectionEnumType,
+				Description: "ASC (the default) or DESC",
+			},
+		},
+	})
+)
+
+// filterArgs adds the filter and orderBy arguments to args.
+func filterArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["filter"] = &graphql.ArgumentConfig{
+		Description: "Return only the items matching the filter",
+		Type:        ResourceFilterInputType,
+	}
+	args["orderBy"] = &graphql.ArgumentConfig{
+		Description: "Order the items by a field instead of by id",
+		Type:        ResourceOrderInputType,
+	}
+	return args
+}
+
+// resourceFilter decodes the filter argument.
+func resourceFilter(args map[string]interface{}) resolver.ResourceFilter {
+	input, _ := args["filter"].(map[string]interface{})
+
+	var filter resolver.ResourceFilter
+	filter.TagKey, _ = input["tagKey"].(string)
+	filter.TagValue, _ = input["tagValue"].(string)
+	filter.State, _ = input["state"].(string)
+	filter.InstanceType, _ = input["instanceType"].(string)
+	filter.NamePrefix, _ = input["namePrefix"].(string)
+	filter.AvailabilityZone, _ = input["availabilityZone"].(string)
+
+	return filter
+}
+
+// orderResources orders items, sorted by key, by the orderBy argument, if
+// there is one. The sort is stable, so items tied on the field stay ordered by
+// key. It returns the connection kind and node positions for the order, so a
+// cursor from one order can't be used with another.
+func orderResources(args map[string]interface{}, kind string, items []interface{}, key func(interface{}) string) ([]interface{}, string, func(interface{}) string) {
+	input, ok := args["orderBy"].(map[string]interface{})
+	if !ok {
+		return items, kind, key
+	}
+
+	field, _ := input["field"].(string)
+	direction, _ := input["direction"].(string)
+
+	order := resolver.ResourceOrder{
+		Field:      field,
+		Descending: direction == orderDescending,
+	}
+	resolver.SortResources(items, order)
+
+	position := func(item interface{}) string {
+		return order.Position(item, key(item))
+	}
+
+	return items, fmt.Sprintf("%s/%s/%s", kind, field, direction), position
+}
@@ -0,0 +1,114 @@
+//go:build ignore
+// +build ignore
+
+// gen_metric_catalog generates metric_catalog.go from metric_catalog.yaml.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+type catalog struct {
+	Namespaces []struct {
+		Namespace   string `yaml:"namespace"`
+		Type        string `yaml:"type"`
+		Description string `yaml:"description"`
+		Metrics     []struct {
+			Name        string `yaml:"name"`
+			Unit        string `yaml:"unit"`
+			Description string `yaml:"description"`
+		} `yaml:"metrics"`
+	} `yaml:"namespaces"`
+}
+
+var catalogTemplate = template.Must(template.New("catalog").Parse(`// Code generated by gen_metric_catalog.go from metric_catalog.yaml. DO NOT EDIT.
+
+package composter
+
+var metricCatalog = []*metricNamespace{
+{{- range .Namespaces}}
+	{
+		Namespace:   {{printf "%q" .Namespace}},
+		TypeName:    {{printf "%q" .Type}},
+		Description: {{printf "%q" .Description}},
+		Metrics: []*metricDescriptor{
+		{{- range .Metrics}}
+			{Name: {{printf "%q" .Name}}, Unit: {{printf "%q" .Unit}}, Description: {{printf "%q" .Description}}},
+		{{- end}}
+		},
+	},
+{{- end}}
+}
+`))
+
+func main() {
+	in, err := ioutil.ReadFile("metric_catalog.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var c catalog
+	if err := yaml.Unmarshal(in, &c); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := validate(&c); err != nil {
+		log.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := catalogTemplate.Execute(&out, &c); err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("metric_catalog.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// validate checks that the catalog makes a valid schema: every namespace has
+// a unique type, and no metric is listed twice in one namespace or shares its
+// name with the fields every metrics type has.
+func validate(c *catalog) error {
+	types := make(map[string]bool)
+	for _, ns := range c.Namespaces {
+		if ns.Namespace == "" || ns.Type == "" {
+			return fmt.Errorf("namespaces need a namespace and a type")
+		}
+
+		if types[ns.Type] {
+			return fmt.Errorf("type %s is used twice", ns.Type)
+		}
+		types[ns.Type] = true
+
+		metrics := make(map[string]bool)
+		for _, m := range ns.Metrics {
+			if m.Name == "" {
+				return fmt.Errorf("%s has a metric without a name", ns.Namespace)
+			}
+
+			if m.Name == "metric" || m.Name == "available" {
+				return fmt.Errorf("%s can't be a metric name", m.Name)
+			}
+
+			if metrics[m.Name] {
+				return fmt.Errorf("%s lists %s twice", ns.Namespace, m.Name)
+			}
+			metrics[m.Name] = true
+		}
+	}
+
+	return nil
+}
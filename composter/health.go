@@ -0,0 +1,67 @@
+package composter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/opsee/basic/tp"
+	"github.com/opsee/compost/resolver"
+	"golang.org/x/net/context"
+)
+
+// Readiness is the body of /ready: whether compost is ready to serve, and the
+// status of each of its dependencies.
+type Readiness struct {
+	Ready        bool               `json:"ready"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+type DependencyStatus struct {
+	Name          string     `json:"name"`
+	Kind          string     `json:"kind"`
+	Target        string     `json:"target"`
+	State         string     `json:"state"`
+	Healthy       bool       `json:"healthy"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// readiness checks compost's dependencies. A client not built by
+// resolver.NewClient, as in dev mode, has none, and is always ready.
+func (c *Composter) readiness(ctx context.Context) *Readiness {
+	statuses := c.resolver.Health.Status(ctx)
+
+	readiness := &Readiness{
+		Ready:        resolver.Ready(statuses),
+		Dependencies: make([]DependencyStatus, len(statuses)),
+	}
+
+	for i, s := range statuses {
+		readiness.Dependencies[i] = DependencyStatus{
+			Name:      s.Name,
+			Kind:      s.Kind,
+			Target:    s.Target,
+			State:     s.State,
+			Healthy:   s.Healthy,
+			LastError: s.LastError,
+		}
+
+		if !s.LastErrorTime.IsZero() {
+			t := s.LastErrorTime
+			readiness.Dependencies[i].LastErrorTime = &t
+		}
+	}
+
+	return readiness
+}
+
+// ready serves /ready, with a 503 if any dependency isn't healthy.
+func (c *Composter) ready() tp.HandleFunc {
+	return func(ctx context.Context) (interface{}, int, error) {
+		readiness := c.readiness(ctx)
+		if !readiness.Ready {
+			return readiness, http.StatusServiceUnavailable, nil
+		}
+		return readiness, http.StatusOK, nil
+	}
+}
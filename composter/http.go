@@ -12,6 +12,7 @@ import (
 	"github.com/opsee/basic/tp"
 	log "github.com/opsee/logrus"
 	"github.com/opsee/vaper"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
 )
 
@@ -19,30 +20,115 @@ var (
 	errUnknown = errors.New("unknown error.")
 )
 
-func (s *Composter) StartHTTP(addr string) {
-	http.ListenAndServe(addr, s.router)
+// StartHTTP serves compost on addr, over TLS if the config enables it. It
+// returns nil once Shutdown or Close is called, without waiting for Shutdown
+// to finish, or the error the server failed with.
+func (s *Composter) StartHTTP(addr string) error {
+	server := &http.Server{Addr: addr, Handler: tracingHandler(s.router)}
+	if s.config.TLS.Enabled() {
+		tlsConfig, err := s.config.TLS.serverTLSConfig()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	s.serverMut.Lock()
+	if s.closed {
+		s.serverMut.Unlock()
+		return nil
+	}
+	s.server = server
+	s.serverMut.Unlock()
+
+	var err error
+	if server.TLSConfig != nil {
+		// the certificate comes from TLSConfig.GetCertificate
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops compost gracefully. It stops accepting connections, ends
+// subscriptions, and waits for the requests in flight to finish. If ctx is
+// done first, it cancels them, closes their connections and returns ctx's
+// error.
+func (s *Composter) Shutdown(ctx context.Context) error {
+	server := s.stop()
+	s.cancelSubscriptions()
+
+	if server == nil {
+		s.cancel()
+		return nil
+	}
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		s.cancel()
+		server.Close()
+	}
+	return err
+}
+
+// Close stops compost at once, cancelling the requests in flight and closing
+// every connection.
+func (s *Composter) Close() error {
+	server := s.stop()
+	s.cancel()
+
+	if server == nil {
+		return nil
+	}
+	return server.Close()
+}
+
+// stop keeps StartHTTP from starting a server, and returns the one it
+// started, if any.
+func (s *Composter) stop() *http.Server {
+	s.serverMut.Lock()
+	defer s.serverMut.Unlock()
+
+	s.closed = true
+	return s.server
 }
 
 func (s *Composter) initHTTP() {
-	router := tp.NewHTTPRouter(context.Background())
+	router := tp.NewHTTPRouter(s.ctx)
 
 	router.CORS(
 		[]string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-		[]string{`https?://localhost:8080`, `https?://localhost:8008`, `https://(.+)?(opsy\.co|opsee\.co|opsee\.com)`, `https?://coreys-mbp-8:\d+`},
+		s.config.CORSOrigins,
 	)
 
 	// graph q l
 	router.Handle("POST", "/graphql", []tp.DecodeFunc{
+		spanDecodeFunc(),
 		tp.AuthorizationDecodeFunc(userKey, schema.User{}),
 		tp.RequestDecodeFunc(requestKey, GraphQLRequest{}),
 	}, s.graphQL())
 	router.Handle("POST", "/admin/graphql", []tp.DecodeFunc{
+		spanDecodeFunc(),
 		s.authorizationDecodeFunc(),
 		tp.RequestDecodeFunc(requestKey, GraphQLRequest{}),
 	}, s.adminGraphQL())
 
+	// subscriptions, over a websocket
+	router.Handler("GET", "/graphql/subscriptions", s.subscriptionsHandler())
+
+	// readiness, next to the liveness check tp serves on /health
+	router.Handle("GET", "/ready", []tp.DecodeFunc{}, s.ready())
+
+	// prometheus metrics
+	router.Handler("GET", "/metrics", promhttp.Handler())
+
 	// fileserver for static things
-	router.Handler("GET", "/static/*stuff", http.StripPrefix("/static/", http.FileServer(http.Dir("/static"))))
+	router.Handler("GET", "/static/*stuff", http.StripPrefix("/static/", http.FileServer(http.Dir(s.config.StaticDir))))
 
 	// set a big timeout bc aws be slow
 	router.Timeout(5 * time.Minute)
@@ -50,46 +136,61 @@ func (s *Composter) initHTTP() {
 	s.router = router
 }
 
+// authorizationDecodeFunc authenticates admin requests by their bearer token
+// or, without one, by their client certificate.
 func (s *Composter) authorizationDecodeFunc() tp.DecodeFunc {
 	return func(ctx context.Context, rw http.ResponseWriter, r *http.Request, p httprouter.Params) (context.Context, int, error) {
 		header := r.Header.Get("authorization")
 		if header == "" {
+			if user, ok := s.certificateUser(r); ok {
+				return context.WithValue(ctx, userKey, user), 0, nil
+			}
 			return ctx, http.StatusUnauthorized, nil
 		}
 
-		var (
-			authType string
-			token    string
-		)
-
-		_, err := fmt.Sscanf(header, "%s %s", &authType, &token)
-		if err != nil || token == "" {
-			return ctx, http.StatusUnauthorized, fmt.Errorf("Authorization header is malformed.")
+		user, status, err := bearerUser(header)
+		if err != nil {
+			return ctx, status, err
 		}
 
-		if strings.ToLower(authType) != "bearer" {
-			return ctx, http.StatusUnauthorized, fmt.Errorf("Authorization type not supported.")
-		}
+		return context.WithValue(ctx, userKey, user), 0, nil
+	}
+}
 
-		decoded, err := vaper.Unmarshal(token)
-		if err != nil {
-			log.WithError(err).Error("error decoding bearer token")
-			return ctx, http.StatusUnauthorized, fmt.Errorf("Authorization token decode error.")
-		}
+// bearerUser decodes the user from a vaper bearer token authorization header.
+func bearerUser(header string) (*schema.User, int, error) {
+	var (
+		authType string
+		token    string
+	)
 
-		user := &schema.User{}
-		err = decoded.Reify(user)
-		if err != nil {
-			return ctx, http.StatusUnauthorized, fmt.Errorf("authorization token unmarshal error.")
-		}
+	_, err := fmt.Sscanf(header, "%s %s", &authType, &token)
+	if err != nil || token == "" {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Authorization header is malformed.")
+	}
 
-		err = user.Validate()
-		if err != nil {
-			return ctx, http.StatusUnauthorized, err
-		}
+	if strings.ToLower(authType) != "bearer" {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Authorization type not supported.")
+	}
 
-		return context.WithValue(ctx, userKey, user), 0, nil
+	decoded, err := vaper.Unmarshal(token)
+	if err != nil {
+		log.WithError(err).Error("error decoding bearer token")
+		return nil, http.StatusUnauthorized, fmt.Errorf("Authorization token decode error.")
+	}
+
+	user := &schema.User{}
+	err = decoded.Reify(user)
+	if err != nil {
+		return nil, http.StatusUnauthorized, fmt.Errorf("authorization token unmarshal error.")
+	}
+
+	err = user.Validate()
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
 	}
+
+	return user, 0, nil
 }
 
 func (s *Composter) graphQL() tp.HandleFunc {
@@ -99,7 +200,16 @@ func (s *Composter) graphQL() tp.HandleFunc {
 			return nil, http.StatusUnauthorized, errDecodeUser
 		}
 
-		response, err := s.Compost(ctx, s.Schema)
+		request, ok := ctx.Value(requestKey).(*GraphQLRequest)
+		if !ok {
+			return nil, http.StatusInternalServerError, errDecodeRequest
+		}
+
+		if err := s.config.PersistedQueries.Resolve(request); err != nil {
+			return errorResult(err), http.StatusOK, nil
+		}
+
+		response, err := s.compost(ctx, s.Schema, s.config.QueryLimits)
 		if err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
@@ -2,12 +2,14 @@ package composter
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/opsee/compost/resolver"
 	"github.com/opsee/vaper"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 const (
@@ -24,7 +26,7 @@ func init() {
 
 func TestAdminAuth(t *testing.T) {
 	assert := assert.New(t)
-	c := New(&resolver.Client{})
+	c := New(&resolver.Client{}, Config{})
 
 	req, err := http.NewRequest("POST", "http://compost/admin/graphql", bytes.NewBuffer([]byte(`{"query": "{}"}`)))
 	if err != nil {
@@ -39,3 +41,49 @@ func TestAdminAuth(t *testing.T) {
 
 	assert.Equal(401, w.Code)
 }
+
+func TestReady(t *testing.T) {
+	assert := assert.New(t)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	c := New(&resolver.Client{}, Config{})
+	for _, test := range []struct {
+		health *resolver.Health
+		code   int
+		ready  bool
+	}{
+		{nil, http.StatusOK, true},
+		{resolver.NewHealth(time.Second, nil), http.StatusOK, true},
+		{resolver.NewHealth(time.Second, nil), http.StatusServiceUnavailable, false},
+	} {
+		if test.code != http.StatusOK {
+			test.health.AddHTTP(resolver.BackendHugs, down.URL)
+		}
+		c.resolver.Health = test.health
+
+		req, err := http.NewRequest("GET", "http://compost/ready", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, req)
+		assert.Equal(test.code, w.Code)
+
+		var readiness Readiness
+		if err := json.NewDecoder(w.Body).Decode(&readiness); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(test.ready, readiness.Ready)
+
+		if !test.ready && assert.Len(readiness.Dependencies, 1) {
+			assert.Equal(resolver.BackendHugs, readiness.Dependencies[0].Name)
+			assert.Contains(readiness.Dependencies[0].LastError, "502")
+			assert.NotNil(readiness.Dependencies[0].LastErrorTime)
+		}
+	}
+}
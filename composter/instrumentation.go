@@ -0,0 +1,187 @@
+package composter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/opsee/compost/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxOperationNames bounds the operation names used as metric labels,
+	// since clients choose them. Later names are counted as otherOperation.
+	maxOperationNames = 500
+
+	anonymousOperation = "anonymous"
+	otherOperation     = "other"
+)
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "compost",
+		Subsystem: "graphql",
+		Name:      "requests_total",
+		Help:      "GraphQL requests, by schema, operation name and whether the response had errors.",
+	}, []string{"schema", "operation", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "compost",
+		Subsystem: "graphql",
+		Name:      "request_duration_seconds",
+		Help:      "How long GraphQL requests took, by schema and operation name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"schema", "operation"})
+
+	requestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "compost",
+		Subsystem: "graphql",
+		Name:      "errors_total",
+		Help:      "Errors in GraphQL responses, by schema and error code.",
+	}, []string{"schema", "code"})
+
+	fieldDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "compost",
+		Subsystem: "graphql",
+		Name:      "field_duration_seconds",
+		Help:      "How long the resolvers of fields returning objects or lists took, by type and field.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type", "field"})
+
+	operationNames = struct {
+		sync.Mutex
+		seen map[string]bool
+	}{seen: make(map[string]bool)}
+
+	instrumentedMut     sync.Mutex
+	instrumentedObjects = make(map[*graphql.Object]bool)
+)
+
+func init() {
+	prometheus.MustRegister(requests, requestDuration, requestErrors, fieldDuration)
+}
+
+// schemaName names schema in metrics.
+func (c *Composter) schemaName(schema graphql.Schema) string {
+	if schema.QueryType() == c.AdminSchema.QueryType() {
+		return "admin"
+	}
+	return "public"
+}
+
+// observeRequest records a request against schema that started at start.
+// doc is nil if the request couldn't be parsed.
+func (c *Composter) observeRequest(schema graphql.Schema, doc *ast.Document, start time.Time, result *Result) {
+	var (
+		name      = c.schemaName(schema)
+		operation = operationName(doc)
+		status    = "ok"
+	)
+
+	if len(result.Errors) > 0 {
+		status = "error"
+	}
+
+	for _, err := range result.Errors {
+		if err.Extensions != nil {
+			requestErrors.WithLabelValues(name, string(err.Extensions.Code)).Inc()
+		}
+	}
+
+	requests.WithLabelValues(name, operation, status).Inc()
+	requestDuration.WithLabelValues(name, operation).Observe(time.Since(start).Seconds())
+}
+
+// operationName returns the name of the first operation in doc, bounded to
+// maxOperationNames distinct names.
+func operationName(doc *ast.Document) string {
+	if doc == nil {
+		return anonymousOperation
+	}
+
+	var name string
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			if op.Name != nil {
+				name = op.Name.Value
+			}
+			break
+		}
+	}
+
+	if name == "" {
+		return anonymousOperation
+	}
+
+	operationNames.Lock()
+	defer operationNames.Unlock()
+
+	if !operationNames.seen[name] {
+		if len(operationNames.seen) >= maxOperationNames {
+			return otherOperation
+		}
+		operationNames.seen[name] = true
+	}
+
+	return name
+}
+
+// instrumentFields times and traces the resolvers of the schema's fields that
+// return objects or lists. Scalar and enum fields, which mostly read a struct
+// field, aren't. Like recordErrors, it replaces the field configs, and wraps
+// objects shared between schemas once.
+func instrumentFields(schema graphql.Schema) {
+	instrumentedMut.Lock()
+	defer instrumentedMut.Unlock()
+
+	for _, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || instrumentedObjects[object] {
+			continue
+		}
+		instrumentedObjects[object] = true
+
+		for name, field := range object.Fields() {
+			if field.Resolve == nil {
+				continue
+			}
+
+			if named, isList := unwrapType(field.Type); isLeaf(named) && !isList {
+				continue
+			}
+
+			object.AddFieldConfig(name, &graphql.Field{
+				Name:              field.Name,
+				Description:       field.Description,
+				Type:              field.Type,
+				Args:              fieldConfigArgs(field.Args),
+				Resolve:           timingResolve(object.Name(), name, field.Resolve),
+				DeprecationReason: field.DeprecationReason,
+			})
+		}
+	}
+}
+
+// timingResolve times a field's resolver, and traces it in a span that's the
+// parent of the backend calls it makes. graphql-go gives every field the same
+// context, so the spans of nested fields are siblings, tagged with their path.
+func timingResolve(typeName, fieldName string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		span, ctx := tracing.StartSpan(p.Context, typeName+"."+fieldName)
+		if span != nil {
+			span.SetTag("graphql.path", pathString(fieldPath(p.Info)))
+			p.Context = ctx
+		}
+
+		defer func(start time.Time) {
+			fieldDuration.WithLabelValues(typeName, fieldName).Observe(time.Since(start).Seconds())
+			span.Finish()
+		}(time.Now())
+
+		result, err := resolve(p)
+		span.SetError(err)
+		return result, err
+	}
+}
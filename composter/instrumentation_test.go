@@ -0,0 +1,46 @@
+package composter
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := counter.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestInstrumentation(t *testing.T) {
+	c := New(&resolver.Client{}, Config{})
+	ctx := context.WithValue(context.Background(), userKey, &schema.User{CustomerId: "customer-1"})
+
+	var (
+		ok        = requests.WithLabelValues("public", "typename", "ok")
+		failed    = requests.WithLabelValues("public", "remove", "error")
+		forbidden = requestErrors.WithLabelValues("public", string(resolver.ErrorForbidden))
+		before    = []float64{counterValue(t, ok), counterValue(t, failed), counterValue(t, forbidden)}
+	)
+
+	for _, query := range []string{
+		`query typename { __typename }`,
+		`mutation remove { deleteChecks(ids: ["check-1"]) }`,
+	} {
+		_, err := c.Compost(context.WithValue(ctx, requestKey, &GraphQLRequest{Query: query}), c.Schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, before[0]+1, counterValue(t, ok))
+	assert.Equal(t, before[1]+1, counterValue(t, failed))
+	assert.Equal(t, before[2]+1, counterValue(t, forbidden))
+}
@@ -0,0 +1,118 @@
+package composter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/clients/bartnet"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type slowBartnet struct {
+	bartnet.Client
+	delay time.Duration
+}
+
+func (b *slowBartnet) ListChecks(user *schema.User) ([]*schema.Check, error) {
+	time.Sleep(b.delay)
+	return b.Client.ListChecks(user)
+}
+
+// startTestServer serves c on a free local port, returning its address and
+// StartHTTP's result.
+func startTestServer(t *testing.T, c *Composter) (string, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan error, 1)
+	go func() {
+		started <- c.StartHTTP(addr)
+	}()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err := http.Get("http://" + addr + "/health"); err == nil {
+			resp.Body.Close()
+			return addr, started
+		}
+	}
+
+	t.Fatal("server didn't start")
+	return "", nil
+}
+
+func checksRequest(addr string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", "http://"+addr+"/graphql", bytes.NewBufferString(`{"query": "query checks { checks { edges { node { id } } } }"}`))
+	if err != nil {
+		return nil, err
+	}
+
+	user := base64.StdEncoding.EncodeToString([]byte(`{"id": 1, "customer_id": "customer-1", "email": "dev@opsee.com", "verified": true, "active": true}`))
+	req.Header.Set("Authorization", "Basic "+user)
+	req.Header.Set("Content-Type", "application/json")
+
+	return http.DefaultClient.Do(req)
+}
+
+func TestShutdown(t *testing.T) {
+	backends := fake.New().Backends()
+	backends.Bartnet = &slowBartnet{backends.Bartnet, 200 * time.Millisecond}
+	client := resolver.NewClientWithBackends(resolver.WrapBackends(backends, nil))
+
+	// requests in flight finish before Shutdown returns
+	c := New(client, Config{})
+	addr, started := startTestServer(t, c)
+
+	responses := make(chan int, 1)
+	go func() {
+		resp, err := checksRequest(addr)
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, c.Shutdown(ctx))
+	assert.NoError(t, <-started)
+	assert.Equal(t, http.StatusOK, <-responses)
+	assert.Error(t, c.subscriptionsCtx.Err())
+
+	_, err := http.Get("http://" + addr + "/health")
+	assert.Error(t, err)
+
+	// requests still in flight when the timeout elapses are cancelled
+	c = New(client, Config{})
+	addr, started = startTestServer(t, c)
+
+	go checksRequest(addr)
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, c.Shutdown(ctx))
+	assert.NoError(t, <-started)
+	assert.Error(t, c.ctx.Err())
+
+	// a closed composter doesn't start
+	c = New(client, Config{})
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.StartHTTP(addr))
+}
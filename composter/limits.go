@@ -0,0 +1,329 @@
+package composter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/opsee/compost/resolver"
+)
+
+const (
+	// DefaultListSize is how many items a list without a page size is
+	// assumed to hold when a query's cost is computed.
+	DefaultListSize = 10
+
+	// defaultFieldWeight is the cost of a field without a weight, unless
+	// it's a scalar or enum.
+	defaultFieldWeight = 1
+)
+
+// QueryLimits bound the queries run against the public schema, which are
+// analyzed after they are validated and before they run. A zero MaxDepth or
+// MaxCost is not enforced.
+//
+// A query's cost is the sum of the weights of the fields it selects, with the
+// cost of the fields selected under a list multiplied by the page size of the
+// connection it belongs to, or else by ListSize. A connection's page size is
+// the one it returns: its first or last argument, up to MaxPageSize, or
+// DefaultPageSize if it's given neither. Weights are
+// keyed by type and field name, like "Query.instances", or by type alone
+// for every field of the type, like "EC2Metrics.*". Fields without a weight cost
+// 1, or nothing if they are scalars or enums. Introspection fields are free.
+type QueryLimits struct {
+	MaxDepth int
+	MaxCost  int
+	ListSize int
+	Weights  map[string]int
+}
+
+// queryAnalysis is the depth and cost of a query document.
+type queryAnalysis struct {
+	Depth int
+	Cost  int
+}
+
+// checkLimits returns an error if doc is deeper or costs more than allowed.
+func (l QueryLimits) checkLimits(schema graphql.Schema, doc *ast.Document, variables map[string]interface{}) error {
+	if l.MaxDepth <= 0 && l.MaxCost <= 0 {
+		return nil
+	}
+
+	analysis := l.analyze(schema, doc, variables)
+
+	if l.MaxDepth > 0 && analysis.Depth > l.MaxDepth {
+		return resolver.Errorf(resolver.ErrorInvalidInput, "query depth %d exceeds the limit of %d", analysis.Depth, l.MaxDepth)
+	}
+
+	if l.MaxCost > 0 && analysis.Cost > l.MaxCost {
+		return resolver.Errorf(resolver.ErrorInvalidInput, "query cost %d exceeds the limit of %d", analysis.Cost, l.MaxCost)
+	}
+
+	return nil
+}
+
+// analyze returns the depth and cost of the deepest and costliest operation
+// in doc, which must be valid for schema.
+func (l QueryLimits) analyze(schema graphql.Schema, doc *ast.Document, variables map[string]interface{}) queryAnalysis {
+	a := &analyzer{
+		limits:    l,
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		fields:    make(map[string]graphql.FieldDefinitionMap),
+	}
+
+	if a.limits.ListSize <= 0 {
+		a.limits.ListSize = DefaultListSize
+	}
+
+	var ops []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			ops = append(ops, def)
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		}
+	}
+
+	var analysis queryAnalysis
+	for _, op := range ops {
+		var root graphql.Type = schema.QueryType()
+		if op.Operation == "mutation" {
+			root = schema.MutationType()
+		}
+
+		a.variables = operationVariables(op, variables)
+		depth, cost := a.selectionSet(op.SelectionSet, root, 0)
+
+		if depth > analysis.Depth {
+			analysis.Depth = depth
+		}
+		if cost > analysis.Cost {
+			analysis.Cost = cost
+		}
+	}
+
+	return analysis
+}
+
+type analyzer struct {
+	limits    QueryLimits
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+
+	// fields caches the fields of each type, which graphql-go rebuilds on
+	// every call to Fields.
+	fields map[string]graphql.FieldDefinitionMap
+}
+
+// selectionSet returns the depth and cost of the fields selected on parent.
+// pageSize is the page size of the connection being selected, or 0.
+func (a *analyzer) selectionSet(set *ast.SelectionSet, parent graphql.Type, pageSize int) (int, int) {
+	if set == nil || parent == nil {
+		return 0, 0
+	}
+
+	var depth, cost int
+	add := func(d, c int) {
+		if d > depth {
+			depth = d
+		}
+		cost += c
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			add(a.field(selection, parent, pageSize))
+
+		case *ast.InlineFragment:
+			t := parent
+			if selection.TypeCondition != nil {
+				t = a.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			add(a.selectionSet(selection.SelectionSet, t, pageSize))
+
+		case *ast.FragmentSpread:
+			fragment, ok := a.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			add(a.selectionSet(fragment.SelectionSet, a.schema.Type(fragment.TypeCondition.Name.Value), pageSize))
+		}
+	}
+
+	return depth, cost
+}
+
+// field returns the depth and cost of a field selected on parent, and of
+// the fields selected under it.
+func (a *analyzer) field(field *ast.Field, parent graphql.Type, pageSize int) (int, int) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0, 0
+	}
+
+	def, ok := a.fieldsOf(parent)[name]
+	if !ok {
+		return 1, a.weight(parent.Name(), name, false)
+	}
+
+	t, isList := unwrapType(def.Type)
+
+	// a list is as long as its own page size or the page size of the
+	// connection it belongs to, and a connection passes its page size on to
+	// its lists
+	size, paged := a.pageSize(def, field)
+
+	multiplier := 1
+	switch {
+	case isList && paged:
+		multiplier, pageSize = size, 0
+	case isList && pageSize > 0:
+		multiplier, pageSize = pageSize, 0
+	case isList:
+		multiplier = a.limits.ListSize
+	case paged:
+		pageSize = size
+	}
+
+	depth, cost := a.selectionSet(field.SelectionSet, t, pageSize)
+	return depth + 1, a.weight(parent.Name(), name, isLeaf(t)) + multiplier*cost
+}
+
+func (a *analyzer) weight(typeName, fieldName string, leaf bool) int {
+	if w, ok := a.limits.Weights[typeName+"."+fieldName]; ok {
+		return w
+	}
+
+	if w, ok := a.limits.Weights[typeName+".*"]; ok {
+		return w
+	}
+
+	if leaf {
+		return 0
+	}
+	return defaultFieldWeight
+}
+
+func (a *analyzer) fieldsOf(t graphql.Type) graphql.FieldDefinitionMap {
+	if fields, ok := a.fields[t.Name()]; ok {
+		return fields
+	}
+
+	var fields graphql.FieldDefinitionMap
+	if withFields, ok := t.(interface {
+		Fields() graphql.FieldDefinitionMap
+	}); ok {
+		fields = withFields.Fields()
+	}
+
+	a.fields[t.Name()] = fields
+	return fields
+}
+
+// pageSize returns the number of items a connection field returns, as
+// newConnection pages them: the larger of its first and last arguments, up to
+// MaxPageSize, or DefaultPageSize if it has neither. Fields that aren't
+// connections have no page size.
+func (a *analyzer) pageSize(def *graphql.FieldDefinition, field *ast.Field) (int, bool) {
+	if !isConnection(def) {
+		return 0, false
+	}
+
+	var (
+		size  int
+		found bool
+	)
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" && arg.Name.Value != "last" {
+			continue
+		}
+
+		if n, ok := a.intValue(arg.Value); ok && n >= size {
+			size, found = n, true
+		}
+	}
+
+	switch {
+	case !found:
+		size = DefaultPageSize
+	case size > MaxPageSize:
+		size = MaxPageSize
+	}
+
+	return size, true
+}
+
+// isConnection returns whether def takes the Relay paging arguments.
+func isConnection(def *graphql.FieldDefinition) bool {
+	for _, arg := range def.Args {
+		if arg.Name() == "first" {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *analyzer) intValue(value ast.Value) (int, bool) {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(value.Value)
+		return n, err == nil
+	case *ast.Variable:
+		switch n := a.variables[value.Name.Value].(type) {
+		case int:
+			return n, true
+		case float64:
+			return int(n), true
+		case ast.Value:
+			return a.intValue(n)
+		}
+	}
+
+	return 0, false
+}
+
+// operationVariables returns the variables of op, with the default values of
+// those that weren't given.
+func operationVariables(op *ast.OperationDefinition, variables map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, def := range op.VariableDefinitions {
+		name := def.Variable.Name.Value
+		if v, ok := variables[name]; ok {
+			values[name] = v
+		} else if def.DefaultValue != nil {
+			values[name] = def.DefaultValue
+		}
+	}
+
+	return values
+}
+
+func isLeaf(t graphql.Type) bool {
+	switch t.(type) {
+	case *graphql.Scalar, *graphql.Enum:
+		return true
+	}
+	return false
+}
+
+// unwrapType returns the named type of t, and whether t is a list.
+func unwrapType(t graphql.Type) (graphql.Type, bool) {
+	var isList bool
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			isList = true
+			t = wrapped.OfType
+		default:
+			return t, isList
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package composter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLimits(t *testing.T) {
+	c := New(&resolver.Client{}, Config{})
+	limits := QueryLimits{
+		MaxDepth: 8,
+		MaxCost:  300,
+		Weights:  map[string]int{"EC2Metrics.*": 10},
+	}
+
+	analyze := func(query string, variables map[string]interface{}) queryAnalysis {
+		doc, err := parseRequest(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return limits.analyze(c.Schema, doc, variables)
+	}
+
+	// checks (1) + edges (1) + 10 * (node (1) + results (1 + 10 * responses (1)))
+	checks := `query checks { checks { edges { node { id name results { passing responses { passing } } } } } }`
+	assert.Equal(t, queryAnalysis{Depth: 6, Cost: 122}, analyze(checks, nil))
+
+	// a page of 3 instead of list size 10, and introspection is free
+	paged := `query checks($n: Int = 3) { __typename checks(first: $n) { edges { node { id } } } }`
+	assert.Equal(t, queryAnalysis{Depth: 4, Cost: 5}, analyze(paged, nil))
+	assert.Equal(t, queryAnalysis{Depth: 4, Cost: 7}, analyze(paged, map[string]interface{}{"n": float64(5)}))
+
+	metrics := `query metrics { region(id: "us-west-2") { vpc(id: "vpc-1") { instances(type: "ec2") { edges { node {
+		... on ec2Instance { metrics { ...cpu CPUCreditBalance { metrics { value } } } } } } } } } }
+		fragment cpu on EC2Metrics { CPUUtilization { metrics { value } } CPUCreditUsage { metrics { value } } }`
+	// 4 + 10 * (node (1) + metrics (1) + 3 * (10 + metrics (1)))
+	assert.Equal(t, queryAnalysis{Depth: 9, Cost: 354}, analyze(metrics, nil))
+
+	doc, err := parseRequest(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = limits.checkLimits(c.Schema, doc, nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "query depth 9 exceeds the limit of 8", err.Error())
+		assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(err).Code)
+	}
+
+	limits.MaxDepth = 0
+	err = limits.checkLimits(c.Schema, doc, nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "query cost 354 exceeds the limit of 300", err.Error())
+	}
+}
+
+func TestQueryLimitsUnpagedConnection(t *testing.T) {
+	// a vpc with more instances than the list size, each with a metric
+	backends := fake.New()
+	region := &fake.BezosRegion{}
+	for i := 0; i < 3*DefaultListSize; i++ {
+		id := fmt.Sprintf("i-%02d", i)
+		region.Instances = append(region.Instances, &opsee_aws_ec2.Instance{InstanceId: aws.String(id), VpcId: aws.String("vpc-1")})
+		region.Metrics = append(region.Metrics, &fake.BezosMetric{
+			Namespace:  "AWS/EC2",
+			Name:       "CPUUtilization",
+			Dimensions: []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String(id)}},
+			Datapoints: []*opsee_aws_cloudwatch.Datapoint{{Average: aws.Float64(1)}},
+		})
+	}
+	backends.Bezos.AddRegion("customer-1", "us-west-2", region)
+
+	c := New(backends.Client(), Config{})
+	limits := QueryLimits{Weights: map[string]int{"EC2Metrics.*": 10}}
+
+	query := `query metrics { region(id: "us-west-2") { vpc(id: "vpc-1") { instances(type: "ec2") { edges { node {
+		... on ec2Instance { metrics { CPUUtilization { metrics { value } } } } } } } } } }`
+
+	doc, err := parseRequest(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 + 10 * (node (1) + metrics (1) + 10 + metrics (1))
+	assert.Equal(t, queryAnalysis{Depth: 9, Cost: 134}, limits.analyze(c.Schema, doc, nil))
+
+	// the query fans out to as many instances as it's charged for
+	nodes := instanceNodes(queryComposter(t, c, query))
+	assert.Len(t, nodes, DefaultListSize)
+	assert.Equal(t, 1+DefaultListSize, backends.Bezos.RequestCount())
+
+	// and a page larger than the maximum is charged as the maximum
+	paged := `query metrics { region(id: "us-west-2") { vpc(id: "vpc-1") { instances(type: "ec2", first: 1000) { edges { node {
+		... on ec2Instance { metrics { CPUUtilization { metrics { value } } } } } } } } } }`
+
+	doc, err = parseRequest(paged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, queryAnalysis{Depth: 9, Cost: 4 + MaxPageSize*13}, limits.analyze(c.Schema, doc, nil))
+}
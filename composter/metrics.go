@@ -0,0 +1,540 @@
+package composter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/graphql-go/graphql"
+	"github.com/opsee/basic/schema"
+	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
+	opsee_aws_rds "github.com/opsee/basic/schema/aws/rds"
+	"github.com/opsee/compost/resolver"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	opsee_scalars "github.com/opsee/protobuf/plugin/graphql/scalars"
+)
+
+//go:generate go run gen_metric_catalog.go
+
+const (
+	defaultMetricPeriod = 60
+	defaultMetricWindow = time.Hour
+	// metricLag keeps the default window's end far enough in the past for
+	// cloudwatch to have statistics for it.
+	metricLag = time.Minute
+	// maxMetricDatapoints is the most datapoints cloudwatch returns for one
+	// call.
+	maxMetricDatapoints = 1440
+)
+
+var (
+	MetricStatisticEnumType = graphql.NewEnum(graphql.EnumConfig{
+		Name:        "MetricStatistic",
+		Description: "A CloudWatch statistic",
+		Values:      metricStatisticValues(),
+	})
+
+	MetricDimensionInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MetricDimension",
+		Description: "A CloudWatch dimension",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"value": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+
+	errMetricPeriod      = resolver.NewError(resolver.ErrorInvalidInput, "period must be a positive multiple of 60 seconds")
+	errMetricWindow      = resolver.NewError(resolver.ErrorInvalidInput, "start_time must be before end_time")
+	errMetricDimensions  = resolver.NewError(resolver.ErrorInvalidInput, "error decoding metric dimensions")
+	errMetricDatapoints  = resolver.Errorf(resolver.ErrorInvalidInput, "metrics are limited to %d datapoints, use a longer period or a shorter window", maxMetricDatapoints)
+	errMetricPercentiles = resolver.Errorf(resolver.ErrorInvalidInput, "metrics are limited to %d percentiles", resolver.MaxPercentiles)
+)
+
+// metricNamespace is a CloudWatch namespace in the metric catalog. It's
+// exposed as a type named TypeName with a field for each of its metrics.
+type metricNamespace struct {
+	Namespace   string
+	TypeName    string
+	Description string
+	Metrics     []*metricDescriptor
+}
+
+type metricDescriptor struct {
+	Name        string
+	Unit        string
+	Description string
+}
+
+// catalogNamespace returns the catalog's namespace. Every namespace compost
+// attaches metrics for must be in the catalog.
+func catalogNamespace(namespace string) *metricNamespace {
+	for _, ns := range metricCatalog {
+		if ns.Namespace == namespace {
+			return ns
+		}
+	}
+	panic("metric namespace not in catalog: " + namespace)
+}
+
+// MetricTypeNames returns the names of the catalog's metrics types, for
+// weighing their fields in QueryLimits.
+func MetricTypeNames() []string {
+	names := make([]string, len(metricCatalog))
+	for i, ns := range metricCatalog {
+		names[i] = ns.TypeName
+	}
+	return names
+}
+
+func (ns *metricNamespace) hasMetric(name string) bool {
+	for _, m := range ns.Metrics {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func metricStatisticValues() graphql.EnumValueConfigMap {
+	values := make(graphql.EnumValueConfigMap)
+	for _, statistic := range resolver.MetricStatistics {
+		values[statistic] = &graphql.EnumValueConfig{Value: statistic}
+	}
+	return values
+}
+
+// metricsArgs are the arguments of the metrics fields, which apply to every
+// metric selected beneath them.
+func metricsArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"start_time": &graphql.ArgumentConfig{
+			Description: "The start of the window, in milliseconds since the epoch. Defaults to an hour before end_time.",
+			Type:        opsee_scalars.Timestamp,
+		},
+		"end_time": &graphql.ArgumentConfig{
+			Description: "The end of the window, in milliseconds since the epoch. Defaults to a minute ago.",
+			Type:        opsee_scalars.Timestamp,
+		},
+		"period": &graphql.ArgumentConfig{
+			Description:  "The seconds each datapoint covers, a multiple of 60.",
+			Type:         graphql.Int,
+			DefaultValue: defaultMetricPeriod,
+		},
+		"statistics": &graphql.ArgumentConfig{
+			Description:  "The statistics to return, each as its own series of datapoints labeled with the statistic.",
+			Type:         graphql.NewList(MetricStatisticEnumType),
+			DefaultValue: []interface{}{resolver.StatisticAverage},
+		},
+		"percentiles": &graphql.ArgumentConfig{
+			Description: fmt.Sprintf("The percentiles to return after the statistics, such as p99 or p99.9, at most %d. Pass an empty statistics list to return only percentiles.", resolver.MaxPercentiles),
+			Type:        graphql.NewList(graphql.String),
+		},
+	}
+}
+
+// metricStatisticsInput returns the input for the window, period and
+// statistics in the args of a metrics field, with the metric name and
+// dimensions left for the caller.
+func metricStatisticsInput(args map[string]interface{}, now time.Time) (*opsee_aws_cloudwatch.GetMetricStatisticsInput, error) {
+	period, ok := args["period"].(int)
+	if !ok {
+		period = defaultMetricPeriod
+	}
+
+	if period <= 0 || period%60 != 0 {
+		return nil, errMetricPeriod
+	}
+
+	endTime := now.UTC().Add(-metricLag)
+	if millis, ok := args["end_time"].(int); ok {
+		endTime = millisTime(millis)
+	}
+
+	startTime := endTime.Add(-defaultMetricWindow)
+	if millis, ok := args["start_time"].(int); ok {
+		startTime = millisTime(millis)
+	}
+
+	if !startTime.Before(endTime) {
+		return nil, errMetricWindow
+	}
+
+	if endTime.Sub(startTime)/(time.Duration(period)*time.Second) > maxMetricDatapoints {
+		return nil, errMetricDatapoints
+	}
+
+	var statistics []string
+	if list, ok := args["statistics"].([]interface{}); ok {
+		seen := make(map[string]bool)
+		for _, s := range list {
+			if statistic, ok := s.(string); ok && !seen[statistic] {
+				seen[statistic] = true
+				statistics = append(statistics, statistic)
+			}
+		}
+	}
+
+	var percentiles []string
+	if list, ok := args["percentiles"].([]interface{}); ok {
+		seen := make(map[string]bool)
+		for _, p := range list {
+			percentile, ok := p.(string)
+			if !ok || seen[percentile] {
+				continue
+			}
+			if !resolver.IsPercentile(percentile) {
+				return nil, resolver.Errorf(resolver.ErrorInvalidInput, "invalid percentile %q, use p0 to p100, such as p99 or p99.9", percentile)
+			}
+			seen[percentile] = true
+			percentiles = append(percentiles, percentile)
+		}
+	}
+
+	if len(percentiles) > resolver.MaxPercentiles {
+		return nil, errMetricPercentiles
+	}
+
+	if len(statistics) == 0 && len(percentiles) == 0 {
+		statistics = []string{resolver.StatisticAverage}
+	}
+
+	var (
+		startTs = &opsee_types.Timestamp{}
+		endTs   = &opsee_types.Timestamp{}
+	)
+
+	startTs.Scan(startTime)
+	endTs.Scan(endTime)
+
+	return &opsee_aws_cloudwatch.GetMetricStatisticsInput{
+		StartTime:          startTs,
+		EndTime:            endTs,
+		Period:             aws.Int64(int64(period)),
+		Statistics:         statistics,
+		ExtendedStatistics: percentiles,
+	}, nil
+}
+
+func millisTime(millis int) time.Time {
+	return time.Unix(0, int64(millis)*int64(time.Millisecond)).UTC()
+}
+
+// queryMetrics returns the metrics field of resources with metrics in the
+// namespace. It resolves to the input for the resource's metrics, which the
+// fields beneath it name.
+func (c *Composter) queryMetrics(namespace string) *graphql.Field {
+	ns := catalogNamespace(namespace)
+
+	return &graphql.Field{
+		Type:        c.metricsType(ns),
+		Description: ns.Description,
+		Args:        metricsArgs(),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			dimensions, err := metricDimensions(p.Source)
+			if err != nil {
+				return nil, err
+			}
+
+			input, err := metricStatisticsInput(p.Args, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			input.Namespace = aws.String(ns.Namespace)
+			input.Dimensions = dimensions
+
+			// fetch every selected metric at once, rather than one at a time as
+			// the metric fields are resolved
+			var inputs []*opsee_aws_cloudwatch.GetMetricStatisticsInput
+			for _, metricName := range selectedFields(p.Info) {
+				if ns.hasMetric(metricName) {
+					inputs = append(inputs, metricInput(input, metricName))
+				}
+			}
+			c.resolver.PrefetchMetricStatistics(p.Context, user, queryContext.Region, inputs)
+
+			return input, nil
+		},
+	}
+}
+
+// metricDimensions returns the dimensions that identify a resource's metrics.
+// An autoscaling group's are the same in each of its namespaces.
+func metricDimensions(source interface{}) ([]*opsee_aws_cloudwatch.Dimension, error) {
+	switch t := source.(type) {
+	case *opsee_aws_ec2.Instance:
+		return []*opsee_aws_cloudwatch.Dimension{
+			{
+				Name:  aws.String("InstanceId"),
+				Value: t.InstanceId,
+			},
+		}, nil
+	case *opsee_aws_rds.DBInstance:
+		return []*opsee_aws_cloudwatch.Dimension{
+			{
+				Name:  aws.String("DBInstanceIdentifier"),
+				Value: t.DBInstanceIdentifier,
+			},
+		}, nil
+	case *opsee_aws_ecs.Service:
+		clustername, err := clusterNameFromArn(t.ClusterArn)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*opsee_aws_cloudwatch.Dimension{
+			{
+				Name:  aws.String("ClusterName"),
+				Value: clustername,
+			},
+			{
+				Name:  aws.String("ServiceName"),
+				Value: t.ServiceName,
+			},
+		}, nil
+	case *opsee_aws_elb.LoadBalancerDescription:
+		return []*opsee_aws_cloudwatch.Dimension{
+			{
+				Name:  aws.String("LoadBalancerName"),
+				Value: t.LoadBalancerName,
+			},
+		}, nil
+	case *opsee_aws_autoscaling.Group:
+		return []*opsee_aws_cloudwatch.Dimension{
+			{
+				Name:  aws.String("AutoScalingGroupName"),
+				Value: t.AutoScalingGroupName,
+			},
+		}, nil
+	}
+
+	return nil, errUnknownInstanceMetricType
+}
+
+// metricsType returns the type of the namespace's metrics: a field for each
+// metric in the catalog, and the metric and available fields for those that
+// aren't. It's built once per composter, since resources of different types
+// may share a namespace.
+func (c *Composter) metricsType(ns *metricNamespace) *graphql.Object {
+	if object, ok := c.metricObjects[ns.Namespace]; ok {
+		return object
+	}
+
+	fields := graphql.Fields{
+		"metric":    c.queryMetric(),
+		"available": c.queryAvailableMetrics(),
+	}
+
+	for _, m := range ns.Metrics {
+		field := c.queryMetricName(m.Name)
+		field.Description = m.Description
+		if m.Unit != "" {
+			field.Description += " Unit: " + m.Unit + "."
+		}
+		fields[m.Name] = field
+	}
+
+	object := graphql.NewObject(graphql.ObjectConfig{
+		Name:        ns.TypeName,
+		Description: ns.Description,
+		Fields:      fields,
+	})
+
+	if c.metricObjects == nil {
+		c.metricObjects = make(map[string]*graphql.Object)
+	}
+	c.metricObjects[ns.Namespace] = object
+	return object
+}
+
+func (c *Composter) queryMetricName(metricName string) *graphql.Field {
+	return &graphql.Field{
+		Type: schema.GraphQLCloudWatchResponseType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			input, ok := p.Source.(*opsee_aws_cloudwatch.GetMetricStatisticsInput)
+			if !ok {
+				return nil, errDecodeMetricStatisticsInput
+			}
+
+			return c.resolver.GetMetricStatistics(p.Context, user, queryContext.Region, metricInput(input, metricName))
+		},
+	}
+}
+
+// queryMetric returns any metric the resource has in the namespace, whether
+// or not it's in the catalog. CloudWatch only returns statistics for the exact
+// dimensions of a metric, so the metric is looked up with ListMetrics first.
+func (c *Composter) queryMetric() *graphql.Field {
+	return &graphql.Field{
+		Type:        schema.GraphQLCloudWatchResponseType,
+		Description: "A metric by name, with the given dimensions in addition to the resource's. Null if CloudWatch has no such metric.",
+		Args: graphql.FieldConfigArgument{
+			"name": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"dimensions": &graphql.ArgumentConfig{
+				Type: graphql.NewList(MetricDimensionInputType),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			input, ok := p.Source.(*opsee_aws_cloudwatch.GetMetricStatisticsInput)
+			if !ok {
+				return nil, errDecodeMetricStatisticsInput
+			}
+
+			metricName, _ := p.Args["name"].(string)
+
+			dimensions := append([]*opsee_aws_cloudwatch.Dimension(nil), input.Dimensions...)
+			if list, ok := p.Args["dimensions"].([]interface{}); ok {
+				for _, d := range list {
+					dimension, ok := d.(map[string]interface{})
+					if !ok {
+						return nil, errMetricDimensions
+					}
+
+					name, _ := dimension["name"].(string)
+					value, _ := dimension["value"].(string)
+					dimensions = append(dimensions, &opsee_aws_cloudwatch.Dimension{Name: aws.String(name), Value: aws.String(value)})
+				}
+			}
+
+			metrics, err := c.resolver.ListMetrics(p.Context, user, queryContext.Region, aws.StringValue(input.Namespace), dimensionFilters(input.Dimensions))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, m := range metrics {
+				if aws.StringValue(m.MetricName) == metricName && sameDimensions(m.Dimensions, dimensions) {
+					named := metricInput(input, metricName)
+					named.Dimensions = dimensions
+					return c.resolver.GetMetricStatistics(p.Context, user, queryContext.Region, named)
+				}
+			}
+
+			return nil, nil
+		},
+	}
+}
+
+// queryAvailableMetrics returns the names of the metrics CloudWatch has for
+// the resource, with exactly its dimensions.
+func (c *Composter) queryAvailableMetrics() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(graphql.String),
+		Description: "The names of the metrics CloudWatch has for the resource in this namespace",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
+			if !ok {
+				return nil, errDecodeQueryContext
+			}
+
+			input, ok := p.Source.(*opsee_aws_cloudwatch.GetMetricStatisticsInput)
+			if !ok {
+				return nil, errDecodeMetricStatisticsInput
+			}
+
+			metrics, err := c.resolver.ListMetrics(p.Context, user, queryContext.Region, aws.StringValue(input.Namespace), dimensionFilters(input.Dimensions))
+			if err != nil {
+				return nil, err
+			}
+
+			var (
+				names []string
+				seen  = make(map[string]bool)
+			)
+
+			for _, m := range metrics {
+				name := aws.StringValue(m.MetricName)
+				if !seen[name] && sameDimensions(m.Dimensions, input.Dimensions) {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+
+			sort.Strings(names)
+			return names, nil
+		},
+	}
+}
+
+// metricInput returns a copy of input for the named metric.
+func metricInput(input *opsee_aws_cloudwatch.GetMetricStatisticsInput, metricName string) *opsee_aws_cloudwatch.GetMetricStatisticsInput {
+	named := *input
+	named.MetricName = aws.String(metricName)
+	return &named
+}
+
+func dimensionFilters(dimensions []*opsee_aws_cloudwatch.Dimension) []*opsee_aws_cloudwatch.DimensionFilter {
+	filters := make([]*opsee_aws_cloudwatch.DimensionFilter, len(dimensions))
+	for i, d := range dimensions {
+		filters[i] = &opsee_aws_cloudwatch.DimensionFilter{Name: d.Name, Value: d.Value}
+	}
+	return filters
+}
+
+// sameDimensions returns whether a and b have the same names and values, in
+// any order.
+func sameDimensions(a, b []*opsee_aws_cloudwatch.Dimension) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, da := range a {
+		found := false
+		for _, db := range b {
+			if aws.StringValue(da.Name) == aws.StringValue(db.Name) && aws.StringValue(da.Value) == aws.StringValue(db.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,125 @@
+package composter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMetricStatisticsInput(t *testing.T) {
+	now := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	input, err := metricStatisticsInput(map[string]interface{}{}, now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, now.Add(-time.Hour-time.Minute), input.StartTime.Time())
+		assert.Equal(t, now.Add(-time.Minute), input.EndTime.Time())
+		assert.EqualValues(t, 60, *input.Period)
+		assert.Equal(t, []string{resolver.StatisticAverage}, input.Statistics)
+	}
+
+	input, err = metricStatisticsInput(map[string]interface{}{
+		"start_time": int(now.Add(-24*time.Hour).UnixNano() / int64(time.Millisecond)),
+		"end_time":   int(now.UnixNano() / int64(time.Millisecond)),
+		"period":     300,
+		"statistics": []interface{}{resolver.StatisticMaximum, resolver.StatisticSum, resolver.StatisticMaximum},
+	}, now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, now.Add(-24*time.Hour), input.StartTime.Time())
+		assert.Equal(t, now, input.EndTime.Time())
+		assert.EqualValues(t, 300, *input.Period)
+		assert.Equal(t, []string{resolver.StatisticMaximum, resolver.StatisticSum}, input.Statistics)
+	}
+
+	// an empty statistics list asks for only the percentiles
+	input, err = metricStatisticsInput(map[string]interface{}{
+		"statistics":  []interface{}{},
+		"percentiles": []interface{}{"p99", "p99.9", "p99", "p100"},
+	}, now)
+	if assert.NoError(t, err) {
+		assert.Empty(t, input.Statistics)
+		assert.Equal(t, []string{"p99", "p99.9", "p100"}, input.ExtendedStatistics)
+	}
+
+	var tooMany []interface{}
+	for i := 0; i <= resolver.MaxPercentiles; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("p%d", 50+i))
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"period": 90},
+		{"period": 0},
+		{"percentiles": []interface{}{"99"}},
+		{"percentiles": []interface{}{"p101"}},
+		{"percentiles": []interface{}{"p99.999"}},
+		{"percentiles": tooMany},
+		{"start_time": int(now.UnixNano() / int64(time.Millisecond))},
+		{"start_time": int(now.Add(-48*time.Hour).UnixNano() / int64(time.Millisecond))},
+	} {
+		_, err := metricStatisticsInput(args, now)
+		if assert.Error(t, err, "%v", args) {
+			assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(err).Code)
+		}
+	}
+}
+
+func TestMetricDimensions(t *testing.T) {
+	for _, test := range []struct {
+		source    interface{}
+		dimension string
+		value     string
+	}{
+		{&opsee_aws_elb.LoadBalancerDescription{LoadBalancerName: aws.String("web-elb")}, "LoadBalancerName", "web-elb"},
+		{&opsee_aws_autoscaling.Group{AutoScalingGroupName: aws.String("web-asg")}, "AutoScalingGroupName", "web-asg"},
+	} {
+		dimensions, err := metricDimensions(test.source)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []*opsee_aws_cloudwatch.Dimension{{Name: aws.String(test.dimension), Value: aws.String(test.value)}}, dimensions)
+		}
+	}
+
+	_, err := metricDimensions(struct{}{})
+	assert.Equal(t, errUnknownInstanceMetricType, err)
+}
+
+// TestMetricCatalog checks that metric_catalog.go was regenerated after the
+// last change to metric_catalog.yaml.
+func TestMetricCatalog(t *testing.T) {
+	in, err := ioutil.ReadFile("metric_catalog.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var catalog struct {
+		Namespaces []struct {
+			Namespace   string `yaml:"namespace"`
+			Type        string `yaml:"type"`
+			Description string `yaml:"description"`
+			Metrics     []*metricDescriptor
+		}
+	}
+
+	if err := yaml.Unmarshal(in, &catalog); err != nil {
+		t.Fatal(err)
+	}
+
+	var namespaces []*metricNamespace
+	for _, ns := range catalog.Namespaces {
+		namespaces = append(namespaces, &metricNamespace{
+			Namespace:   ns.Namespace,
+			TypeName:    ns.Type,
+			Description: ns.Description,
+			Metrics:     ns.Metrics,
+		})
+	}
+
+	assert.Equal(t, namespaces, metricCatalog, "run go generate ./composter")
+}
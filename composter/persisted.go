@@ -0,0 +1,324 @@
+package composter
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/opsee/compost/resolver"
+	log "github.com/opsee/logrus"
+)
+
+// Persisted query modes. In every mode a request may name its query and
+// variables by their sha256 instead of sending them, much as in Apollo's
+// automatic persisted queries.
+const (
+	// PersistedQueriesRegister runs any query, and registers the queries
+	// and variables sent along with their hash so that later requests can
+	// send the hash alone.
+	PersistedQueriesRegister = "register"
+	// PersistedQueriesLookup runs any query, but only registered queries
+	// can be sent by hash.
+	PersistedQueriesLookup = "lookup"
+	// PersistedQueriesAllowlist only runs queries loaded into the registry,
+	// whether they are sent by hash or in full, and with any variables. It
+	// registers allowed queries and variables sent along with their hash.
+	PersistedQueriesAllowlist = "allowlist"
+
+	// DefaultPersistedQueryMaxEntries bounds the queries clients register
+	// in a registry whose config doesn't.
+	DefaultPersistedQueryMaxEntries = 10000
+
+	persistedQueryVersion = 1
+	persistedQueryExt     = ".graphql"
+)
+
+var (
+	// PersistedQueryModes are the modes a PersistedQueryConfig may have.
+	PersistedQueryModes = []string{PersistedQueriesRegister, PersistedQueriesLookup, PersistedQueriesAllowlist}
+
+	errPersistedQueryNotFound  = resolver.NewError(resolver.ErrorPersistedQueryNotFound, "PersistedQueryNotFound")
+	errPersistedQueryVersion   = resolver.NewError(resolver.ErrorInvalidInput, "unsupported persisted query version")
+	errPersistedQueryHash      = resolver.NewError(resolver.ErrorInvalidInput, "provided sha256Hash does not match query")
+	errPersistedQueryVariables = resolver.NewError(resolver.ErrorInvalidInput, "error encoding variables")
+	errQueryNotAllowed         = resolver.NewError(resolver.ErrorForbidden, "query is not allowed")
+)
+
+// PersistedQueryConfig configures a PersistedQueries registry. An empty Mode
+// is PersistedQueriesRegister. MaxEntries bounds the queries registered by
+// clients, and not those added with Add or LoadDir.
+type PersistedQueryConfig struct {
+	Mode       string
+	MaxEntries int
+}
+
+// RequestExtensions are the extensions of a GraphQLRequest.
+type RequestExtensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+}
+
+// PersistedQuery names a request's query and variables by their hex sha256,
+// as computed by requestHash.
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// PersistedQueries is a registry of queries and their variables keyed by
+// their hex sha256. Queries registered by clients are evicted least recently
+// used first once there are too many, so they can't crowd out each other or
+// the queries loaded at startup. It is safe for concurrent use.
+type PersistedQueries struct {
+	mode       string
+	maxEntries int
+
+	mut sync.Mutex
+	// loaded are the queries added with Add, which are never evicted.
+	loaded map[string]*persistedQuery
+	// registered are the queries registered by clients, with lru in order of
+	// use.
+	registered map[string]*list.Element
+	lru        *list.List
+}
+
+// persistedQuery is a registered query, with the variables it was sent with.
+type persistedQuery struct {
+	hash      string
+	query     string
+	variables map[string]interface{}
+}
+
+func NewPersistedQueries(config PersistedQueryConfig) *PersistedQueries {
+	mode := config.Mode
+	if mode == "" {
+		mode = PersistedQueriesRegister
+	}
+
+	maxEntries := config.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultPersistedQueryMaxEntries
+	}
+
+	return &PersistedQueries{
+		mode:       mode,
+		maxEntries: maxEntries,
+		loaded:     make(map[string]*persistedQuery),
+		registered: make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Add registers query without variables, whatever the registry's mode or
+// size, and returns its hash. Queries added are the ones an allowlist allows.
+func (pq *PersistedQueries) Add(query string) string {
+	hash := queryHash(query)
+
+	pq.mut.Lock()
+	pq.loaded[hash] = &persistedQuery{hash: hash, query: query}
+	pq.mut.Unlock()
+
+	return hash
+}
+
+// Get returns the query with hash.
+func (pq *PersistedQueries) Get(hash string) (string, bool) {
+	entry, ok := pq.lookup(hash)
+	if !ok {
+		return "", false
+	}
+	return entry.query, true
+}
+
+func (pq *PersistedQueries) lookup(hash string) (*persistedQuery, bool) {
+	pq.mut.Lock()
+	defer pq.mut.Unlock()
+
+	hash = strings.ToLower(hash)
+	if entry, ok := pq.loaded[hash]; ok {
+		return entry, true
+	}
+
+	if el, ok := pq.registered[hash]; ok {
+		pq.lru.MoveToFront(el)
+		return el.Value.(*persistedQuery), true
+	}
+
+	return nil, false
+}
+
+// Len returns the number of registered queries.
+func (pq *PersistedQueries) Len() int {
+	pq.mut.Lock()
+	defer pq.mut.Unlock()
+
+	return len(pq.loaded) + pq.lru.Len()
+}
+
+// LoadDir registers every .graphql file under dir, each holding one query
+// document, and returns how many were loaded. A file is registered without
+// variables, so its hash is of its exact contents and clients must send the
+// same text.
+func (pq *PersistedQueries) LoadDir(dir string) (int, error) {
+	var loaded int
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(path) != persistedQueryExt {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		hash := pq.Add(string(data))
+		log.WithFields(log.Fields{"file": path, "hash": hash}).Debug("loaded persisted query")
+		loaded++
+
+		return nil
+	})
+
+	return loaded, err
+}
+
+// Resolve fills in the query and variables of a request sent by hash and
+// checks that the registry's mode allows the request's query, registering it
+// with its variables if the mode does and the request asked for it. A request
+// sent by hash may still send its variables, which must be the registered
+// ones.
+func (pq *PersistedQueries) Resolve(request *GraphQLRequest) error {
+	hash, err := request.persistedHash()
+	if err != nil {
+		return err
+	}
+
+	if request.Query == "" {
+		// an allowlist checks the query once the client sends its text, since
+		// an allowed query sent with variables has a hash of its own
+		entry, ok := pq.lookup(hash)
+		if !ok {
+			return errPersistedQueryNotFound
+		}
+
+		if len(request.Variables) > 0 {
+			sum, err := requestHash(entry.query, request.Variables)
+			if err != nil {
+				return err
+			}
+
+			if sum != hash {
+				return errPersistedQueryHash
+			}
+		}
+
+		request.Query = entry.query
+		request.Variables = entry.variables
+		return nil
+	}
+
+	sum, err := requestHash(request.Query, request.Variables)
+	if err != nil {
+		return err
+	}
+
+	if hash != "" && hash != sum {
+		return errPersistedQueryHash
+	}
+
+	switch pq.mode {
+	case PersistedQueriesAllowlist:
+		// queries are allowed by their text, whatever their variables
+		if _, ok := pq.lookup(queryHash(request.Query)); !ok {
+			return errQueryNotAllowed
+		}
+
+		if hash != "" {
+			pq.register(sum, request.Query, request.Variables)
+		}
+	case PersistedQueriesRegister:
+		if hash != "" {
+			pq.register(sum, request.Query, request.Variables)
+		}
+	}
+
+	return nil
+}
+
+// register adds a query and variables sent by a client, evicting the least
+// recently used query a client registered if there are too many.
+func (pq *PersistedQueries) register(hash, query string, variables map[string]interface{}) {
+	pq.mut.Lock()
+	defer pq.mut.Unlock()
+
+	if _, ok := pq.loaded[hash]; ok {
+		return
+	}
+
+	if el, ok := pq.registered[hash]; ok {
+		pq.lru.MoveToFront(el)
+		return
+	}
+
+	for pq.lru.Len() >= pq.maxEntries {
+		oldest := pq.lru.Back()
+		pq.lru.Remove(oldest)
+		delete(pq.registered, oldest.Value.(*persistedQuery).hash)
+	}
+
+	pq.registered[hash] = pq.lru.PushFront(&persistedQuery{hash: hash, query: query, variables: variables})
+}
+
+// persistedHash returns the lowercased hash of a request's persisted query,
+// or "" if it doesn't name one.
+func (req *GraphQLRequest) persistedHash() (string, error) {
+	if req.Extensions == nil || req.Extensions.PersistedQuery == nil {
+		return "", nil
+	}
+
+	if req.Extensions.PersistedQuery.Version != persistedQueryVersion {
+		return "", errPersistedQueryVersion
+	}
+
+	return strings.ToLower(req.Extensions.PersistedQuery.Sha256Hash), nil
+}
+
+// requestHash returns the hex sha256 of a query and its variables. Without
+// variables it's the hash of the query's text, and otherwise of the text, a
+// NUL byte and the variables as JSON without whitespace or HTML escaping,
+// with the keys of every object sorted.
+func requestHash(query string, variables map[string]interface{}) (string, error) {
+	if len(variables) == 0 {
+		return queryHash(query), nil
+	}
+
+	// json sorts map keys, so the encoding is canonical
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(variables); err != nil {
+		return "", errPersistedQueryVariables
+	}
+
+	h := sha256.New()
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+	h.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// queryHash returns the hex sha256 of a query's text.
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
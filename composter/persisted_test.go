@@ -0,0 +1,172 @@
+package composter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistedQueries(t *testing.T) {
+	const query = `query checks { checks { edges { node { id } } } }`
+
+	byHash := func(hash string) *GraphQLRequest {
+		return &GraphQLRequest{Extensions: &RequestExtensions{
+			PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash},
+		}}
+	}
+
+	code := func(err error) resolver.ErrorCode {
+		if err == nil {
+			return ""
+		}
+		return resolver.ErrorOf(err).Code
+	}
+
+	hash := queryHash(query)
+
+	queries := NewPersistedQueries(PersistedQueryConfig{})
+	assert.Equal(t, resolver.ErrorPersistedQueryNotFound, code(queries.Resolve(byHash(hash))))
+
+	request := byHash(hash)
+	request.Query = query
+	assert.NoError(t, queries.Resolve(request))
+
+	request = byHash(hash)
+	assert.NoError(t, queries.Resolve(request))
+	assert.Equal(t, query, request.Query)
+
+	request = byHash(queryHash("query other { checks }"))
+	request.Query = query
+	assert.Equal(t, resolver.ErrorInvalidInput, code(queries.Resolve(request)))
+
+	dir, err := ioutil.TempDir("", "compost-queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "checks.graphql"), []byte(query), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a query"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	queries = NewPersistedQueries(PersistedQueryConfig{Mode: PersistedQueriesAllowlist})
+	loaded, err := queries.LoadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+
+	assert.NoError(t, queries.Resolve(byHash(hash)))
+	assert.NoError(t, queries.Resolve(&GraphQLRequest{Query: query}))
+	assert.Equal(t, resolver.ErrorForbidden, code(queries.Resolve(&GraphQLRequest{Query: "query other { checks }"})))
+	// unknown hashes aren't forbidden until the client sends their text
+	assert.Equal(t, resolver.ErrorPersistedQueryNotFound, code(queries.Resolve(byHash(queryHash("query other { checks }")))))
+
+	// an allowed query may be sent with any variables, and registered with
+	// them
+	variables := map[string]interface{}{"id": "check-1"}
+	varsHash, err := requestHash(query, variables)
+	assert.NoError(t, err)
+
+	assert.Equal(t, resolver.ErrorPersistedQueryNotFound, code(queries.Resolve(byHash(varsHash))))
+
+	request = byHash(varsHash)
+	request.Query = query
+	request.Variables = variables
+	assert.NoError(t, queries.Resolve(request))
+
+	request = byHash(varsHash)
+	assert.NoError(t, queries.Resolve(request))
+	assert.Equal(t, variables, request.Variables)
+}
+
+func TestPersistedQueryVariables(t *testing.T) {
+	const query = `query check($id: String) { checks(id: $id) { edges { node { id } } } }`
+
+	variables := map[string]interface{}{
+		"id":     "check-<1>",
+		"filter": map[string]interface{}{"state": "failing", "limit": float64(10)},
+	}
+
+	// the variables are hashed as compact JSON with sorted keys
+	sum := sha256.Sum256([]byte(query + "\x00" + `{"filter":{"limit":10,"state":"failing"},"id":"check-<1>"}`))
+	hash, err := requestHash(query, variables)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+
+	noVariables, err := requestHash(query, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, queryHash(query), noVariables)
+
+	queries := NewPersistedQueries(PersistedQueryConfig{})
+
+	request := &GraphQLRequest{Query: query, Variables: variables, Extensions: &RequestExtensions{
+		PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: noVariables},
+	}}
+	assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(queries.Resolve(request)).Code)
+
+	request.Extensions.PersistedQuery.Sha256Hash = hash
+	assert.NoError(t, queries.Resolve(request))
+
+	// a registered query and its variables can be sent by hash alone, or
+	// with the same variables, but not with others
+	request = &GraphQLRequest{Extensions: &RequestExtensions{PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash}}}
+	assert.NoError(t, queries.Resolve(request))
+	assert.Equal(t, query, request.Query)
+	assert.Equal(t, variables, request.Variables)
+
+	request = &GraphQLRequest{Variables: variables, Extensions: &RequestExtensions{PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash}}}
+	assert.NoError(t, queries.Resolve(request))
+
+	request = &GraphQLRequest{Variables: map[string]interface{}{"id": "check-2"}, Extensions: &RequestExtensions{PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash}}}
+	assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(queries.Resolve(request)).Code)
+
+	_, ok := queries.Get(noVariables)
+	assert.False(t, ok)
+}
+
+func TestPersistedQueryEviction(t *testing.T) {
+	register := func(queries *PersistedQueries, query string) string {
+		hash := queryHash(query)
+		request := &GraphQLRequest{Query: query, Extensions: &RequestExtensions{
+			PersistedQuery: &PersistedQuery{Version: 1, Sha256Hash: hash},
+		}}
+		assert.NoError(t, queries.Resolve(request))
+		return hash
+	}
+
+	queries := NewPersistedQueries(PersistedQueryConfig{MaxEntries: 2})
+	loaded := queries.Add("query loaded { checks }")
+
+	first := register(queries, "query first { checks }")
+	second := register(queries, "query second { checks }")
+
+	// using the first makes the second the least recently used
+	_, ok := queries.Get(first)
+	assert.True(t, ok)
+
+	third := register(queries, "query third { checks }")
+	assert.Equal(t, 3, queries.Len())
+
+	for hash, registered := range map[string]bool{loaded: true, first: true, second: false, third: true} {
+		_, ok := queries.Get(hash)
+		assert.Equal(t, registered, ok, hash)
+	}
+
+	// queries clients register never crowd out the loaded ones
+	for i := 0; i < 10; i++ {
+		register(queries, fmt.Sprintf("query q%d { checks }", i))
+	}
+
+	_, ok = queries.Get(loaded)
+	assert.True(t, ok)
+	assert.Equal(t, 3, queries.Len())
+}
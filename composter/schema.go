@@ -2,10 +2,8 @@ package composter
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"golang.org/x/net/context"
 
@@ -13,45 +11,41 @@ import (
 	"github.com/graphql-go/graphql"
 	"github.com/opsee/basic/schema"
 	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
-	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
 	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
 	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
 	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
 	opsee_aws_rds "github.com/opsee/basic/schema/aws/rds"
 	opsee "github.com/opsee/basic/service"
 	log "github.com/opsee/logrus"
+	"github.com/opsee/compost/resolver"
 	opsee_types "github.com/opsee/protobuf/opseeproto/types"
 	opsee_scalars "github.com/opsee/protobuf/plugin/graphql/scalars"
 )
 
 var (
-	errDecodeUser                  = errors.New("error decoding user")
-	errDecodeQueryContext          = errors.New("error decoding query context")
-	errMissingRegion               = errors.New("missing region id")
-	errMissingVpc                  = errors.New("missing vpc id")
-	errMissingService              = errors.New("missing service name")
-	errMissingInstanceType         = errors.New("missing instance type - must be one of (ec2, rds)")
-	errMissingGroupType            = errors.New("missing group type - must be one of (security, elb, autoscaling)")
-	errDecodeInstances             = errors.New("error decoding instances")
-	errDecodeInstanceIds           = errors.New("error decoding instance ids")
-	errDecodeUserPermissions       = errors.New("error decoding permissions")
-	errUnknownInstanceMetricType   = errors.New("no metrics for that instance type")
-	errDecodeMetricStatisticsInput = errors.New("error decoding metric statistics input")
-	errDecodeCheckInput            = errors.New("error decoding checks input")
-	errDecodeTeamInput             = errors.New("error decoding team input")
-	errDecodeUserInput             = errors.New("error decoding user input")
-	errDecodeNotificationsInput    = errors.New("error decoding notifications input")
-	errUnknownAction               = errors.New("unknown action")
+	errDecodeUser                  = resolver.NewError(resolver.ErrorUnauthenticated, "error decoding user")
+	errDecodeQueryContext          = resolver.NewError(resolver.ErrorInternal, "error decoding query context")
+	errMissingRegion               = resolver.NewError(resolver.ErrorInvalidInput, "missing region id")
+	errMissingVpc                  = resolver.NewError(resolver.ErrorInvalidInput, "missing vpc id")
+	errMissingService              = resolver.NewError(resolver.ErrorInvalidInput, "missing service name")
+	errMissingCheckId              = resolver.NewError(resolver.ErrorInvalidInput, "missing check id")
+	errMissingInstanceType         = resolver.NewError(resolver.ErrorInvalidInput, "missing instance type - must be one of (ec2, rds)")
+	errMissingGroupType            = resolver.NewError(resolver.ErrorInvalidInput, "missing group type - must be one of (security, elb, autoscaling)")
+	errDecodeInstances             = resolver.NewError(resolver.ErrorInvalidInput, "error decoding instances")
+	errDecodeInstanceIds           = resolver.NewError(resolver.ErrorInvalidInput, "error decoding instance ids")
+	errDecodeUserPermissions       = resolver.NewError(resolver.ErrorInvalidInput, "error decoding permissions")
+	errUnknownInstanceMetricType   = resolver.NewError(resolver.ErrorInvalidInput, "no metrics for that instance type")
+	errDecodeMetricStatisticsInput = resolver.NewError(resolver.ErrorInvalidInput, "error decoding metric statistics input")
+	errDecodeCheckInput            = resolver.NewError(resolver.ErrorInvalidInput, "error decoding checks input")
+	errDecodeTeamInput             = resolver.NewError(resolver.ErrorInvalidInput, "error decoding team input")
+	errDecodeUserInput             = resolver.NewError(resolver.ErrorInvalidInput, "error decoding user input")
+	errDecodeNotificationsInput    = resolver.NewError(resolver.ErrorInvalidInput, "error decoding notifications input")
+	errUnknownAction               = resolver.NewError(resolver.ErrorInvalidInput, "unknown action")
 
 	UserStatusEnumType       *graphql.Enum
 	TeamSubscriptionEnumType *graphql.Enum
 	AggregationEnumType      *graphql.Enum
 
-	InstanceType   *graphql.Object
-	DbInstanceType *graphql.Object
-	EcsServiceType *graphql.Object
-	CheckType      *graphql.Object
-
 	CheckInputType        *graphql.InputObject
 	TeamInputType         *graphql.InputObject
 	UserInputType         *graphql.InputObject
@@ -99,11 +93,19 @@ func UserPermittedFromContext(ctx context.Context, perm string, extra ...Permiss
 	for _, op := range extra {
 		has = op.Op(user, has)
 		if has != nil {
-			return user, has
+			return user, forbidden(has)
 		}
 	}
 
-	return user, has
+	return user, forbidden(has)
+}
+
+// forbidden marks a permission check's error as FORBIDDEN.
+func forbidden(err error) error {
+	if err == nil {
+		return nil
+	}
+	return resolver.NewError(resolver.ErrorForbidden, err.Error())
 }
 
 func (c *Composter) mustSchema() {
@@ -127,8 +129,26 @@ func (c *Composter) mustSchema() {
 		panic(fmt.Sprint("error generating graphql schema: ", err))
 	}
 
+	recordErrors(schema)
+	recordErrors(adminSchema)
+	c.auditMutations(schema)
+	c.auditMutations(adminSchema)
+	instrumentFields(schema)
+	instrumentFields(adminSchema)
+
+	subscriptionSchema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: c.subscription(),
+	})
+
+	if err != nil {
+		panic(fmt.Sprint("error generating graphql schema: ", err))
+	}
+
+	recordErrors(subscriptionSchema)
+
 	c.Schema = schema
 	c.AdminSchema = adminSchema
+	c.SubscriptionSchema = subscriptionSchema
 }
 
 func (c *Composter) initTypes() {
@@ -169,36 +189,51 @@ func (c *Composter) initTypes() {
 		})
 	}
 
-	metrics := c.queryMetrics()
-	if InstanceType == nil {
-		InstanceType = graphql.NewObject(graphql.ObjectConfig{
-			Name: opsee_aws_ec2.GraphQLInstanceType.Name(),
-			Fields: graphql.Fields{
-				"metrics": metrics,
-			},
-		})
-		addFields(InstanceType, opsee_aws_ec2.GraphQLInstanceType.Fields())
-	}
+	c.instanceType = graphql.NewObject(graphql.ObjectConfig{
+		Name: opsee_aws_ec2.GraphQLInstanceType.Name(),
+		Fields: graphql.Fields{
+			"metrics": c.queryMetrics("AWS/EC2"),
+			"alarms":  c.queryAlarms(),
+		},
+	})
+	addFields(c.instanceType, opsee_aws_ec2.GraphQLInstanceType.Fields())
 
-	if DbInstanceType == nil {
-		DbInstanceType = graphql.NewObject(graphql.ObjectConfig{
-			Name: opsee_aws_rds.GraphQLDBInstanceType.Name(),
-			Fields: graphql.Fields{
-				"metrics": metrics,
-			},
-		})
-		addFields(DbInstanceType, opsee_aws_rds.GraphQLDBInstanceType.Fields())
-	}
+	c.dbInstanceType = graphql.NewObject(graphql.ObjectConfig{
+		Name: opsee_aws_rds.GraphQLDBInstanceType.Name(),
+		Fields: graphql.Fields{
+			"metrics": c.queryMetrics("AWS/RDS"),
+			"alarms":  c.queryAlarms(),
+		},
+	})
+	addFields(c.dbInstanceType, opsee_aws_rds.GraphQLDBInstanceType.Fields())
 
-	if EcsServiceType == nil {
-		EcsServiceType = graphql.NewObject(graphql.ObjectConfig{
-			Name: opsee_aws_ecs.GraphQLServiceType.Name(),
-			Fields: graphql.Fields{
-				"metrics": metrics,
-			},
-		})
-		addFields(EcsServiceType, opsee_aws_ecs.GraphQLServiceType.Fields())
-	}
+	c.ecsServiceType = graphql.NewObject(graphql.ObjectConfig{
+		Name: opsee_aws_ecs.GraphQLServiceType.Name(),
+		Fields: graphql.Fields{
+			"metrics": c.queryMetrics("AWS/ECS"),
+			"alarms":  c.queryAlarms(),
+		},
+	})
+	addFields(c.ecsServiceType, opsee_aws_ecs.GraphQLServiceType.Fields())
+
+	c.loadBalancerType = graphql.NewObject(graphql.ObjectConfig{
+		Name: opsee_aws_elb.GraphQLLoadBalancerDescriptionType.Name(),
+		Fields: graphql.Fields{
+			"metrics": c.queryMetrics("AWS/ELB"),
+			"alarms":  c.queryAlarms(),
+		},
+	})
+	addFields(c.loadBalancerType, opsee_aws_elb.GraphQLLoadBalancerDescriptionType.Fields())
+
+	c.autoScalingGroupType = graphql.NewObject(graphql.ObjectConfig{
+		Name: opsee_aws_autoscaling.GraphQLGroupType.Name(),
+		Fields: graphql.Fields{
+			"metrics":            c.queryMetrics("AWS/EC2"),
+			"autoScalingMetrics": c.queryMetrics("AWS/AutoScaling"),
+			"alarms":             c.queryAlarms(),
+		},
+	})
+	addFields(c.autoScalingGroupType, opsee_aws_autoscaling.GraphQLGroupType.Fields())
 
 	if AggregationEnumType == nil {
 		AggregationEnumType = graphql.NewEnum(graphql.EnumConfig{
@@ -241,21 +276,19 @@ func (c *Composter) initTypes() {
 		})
 	}
 
-	checkStateTransitions := c.queryCheckStateTransitions()
-	checkMetrics := c.queryCheckMetrics()
-	if CheckType == nil {
-		CheckType = graphql.NewObject(graphql.ObjectConfig{
-			Name: schema.GraphQLCheckType.Name(),
-			Fields: graphql.Fields{
-				"notifications": &graphql.Field{
-					Type: graphql.NewList(schema.GraphQLNotificationType),
-				},
-				"metrics":           checkMetrics,
-				"state_transitions": checkStateTransitions,
+	c.checkType = graphql.NewObject(graphql.ObjectConfig{
+		Name: schema.GraphQLCheckType.Name(),
+		Fields: graphql.Fields{
+			"notifications": &graphql.Field{
+				Type: graphql.NewList(schema.GraphQLNotificationType),
 			},
-		})
-		addFields(CheckType, schema.GraphQLCheckType.Fields())
-	}
+			"metrics":           c.queryCheckMetrics(),
+			"state_transitions": c.queryCheckStateTransitions(),
+			"alarms":            c.queryCheckAlarms(),
+		},
+	})
+	addFields(c.checkType, schema.GraphQLCheckType.Fields())
+	c.checkType.AddFieldConfig("results", c.queryCheckResults())
 
 	if TeamInputType == nil {
 		TeamInputType = graphql.NewInputObject(graphql.InputObjectConfig{
@@ -508,6 +541,35 @@ func (c *Composter) query() *graphql.Object {
 	return query
 }
 
+// queryCheckResults returns a check's current results, or a snapshot's.
+func (c *Composter) queryCheckResults() *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewList(schema.GraphQLCheckResultType),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, ok := p.Context.Value(userKey).(*schema.User)
+			if !ok {
+				return nil, errDecodeUser
+			}
+
+			check, ok := p.Source.(*schema.Check)
+			if !ok {
+				return nil, errMissingCheckId
+			}
+
+			return c.checkResults(p.Context, user, check)
+		},
+	}
+}
+
+// checkResults returns the results a check was listed with, or else loads its
+// current results.
+func (c *Composter) checkResults(ctx context.Context, user *schema.User, check *schema.Check) ([]*schema.CheckResult, error) {
+	if check.Results != nil {
+		return check.Results, nil
+	}
+	return c.resolver.CheckResults(ctx, user, check.Id)
+}
+
 func (c *Composter) queryCheckStateTransitions() *graphql.Field {
 	return &graphql.Field{
 		Type: graphql.NewList(schema.GraphQLCheckStateTransitionType),
@@ -533,7 +595,7 @@ func (c *Composter) queryCheckStateTransitions() *graphql.Field {
 
 			check, ok := p.Source.(*schema.Check)
 			if !ok {
-				return nil, fmt.Errorf("missing check id")
+				return nil, errMissingCheckId
 			}
 			checkId := check.Id
 
@@ -595,7 +657,7 @@ func (c *Composter) queryCheckMetrics() *graphql.Field {
 
 			check, ok := p.Source.(*schema.Check)
 			if !ok {
-				return nil, fmt.Errorf("missing check id")
+				return nil, errMissingCheckId
 			}
 			checkId := check.Id
 
@@ -652,39 +714,11 @@ func (c *Composter) adminQuery() *graphql.Object {
 			"role":          c.queryRole(),
 			"team":          c.queryTeam(),
 			"notifications": c.queryNotifications(),
-			"listCustomers": &graphql.Field{
-				Type: opsee.GraphQLListCustomersResponseType,
-				Args: graphql.FieldConfigArgument{
-					"page": &graphql.ArgumentConfig{
-						Description: "The page number.",
-						Type:        graphql.Int,
-					},
-					"per_page": &graphql.ArgumentConfig{
-						Description: "The number of customers per page.",
-						Type:        graphql.Int,
-					},
-				},
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					requestor, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
-					if err != nil {
-						return nil, err
-					}
-
-					var (
-						page    int
-						perPage int
-					)
-
-					page, _ = p.Args["page"].(int)
-					perPage, _ = p.Args["per_page"].(int)
-
-					return c.resolver.ListCustomers(p.Context, &opsee.ListUsersRequest{
-						Requestor: requestor,
-						Page:      int32(page),
-						PerPage:   int32(perPage),
-					})
-				},
-			},
+			"cache":         c.queryCache(),
+			"rateLimits":    c.queryRateLimits(),
+			"auditEvents":   c.queryAuditEvents(),
+			"systemStatus":  c.querySystemStatus(),
+			"listCustomers": c.queryListCustomers(),
 			"getUser": &graphql.Field{
 				Type: opsee.GraphQLGetUserResponseType,
 				Args: graphql.FieldConfigArgument{
@@ -753,6 +787,387 @@ func (c *Composter) adminQuery() *graphql.Object {
 	return query
 }
 
+func (c *Composter) queryCache() *graphql.Field {
+	kindType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "CacheKind",
+		Description: "Cache activity for one kind of AWS describe call",
+		Fields: graphql.Fields{
+			"kind": &graphql.Field{
+				Type: graphql.String,
+			},
+			"ttl": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "The time to live in seconds, zero if the kind is not cached",
+			},
+			"entries": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"hits": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"misses": &graphql.Field{
+				Type: graphql.Int,
+			},
+		},
+	})
+
+	entryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "CacheEntry",
+		Description: "A cached AWS describe response",
+		Fields: graphql.Fields{
+			"customer_id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"region": &graphql.Field{
+				Type: graphql.String,
+			},
+			"vpc_id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"kind": &graphql.Field{
+				Type: graphql.String,
+			},
+			"request": &graphql.Field{
+				Type: graphql.String,
+			},
+			"expires": &graphql.Field{
+				Type: opsee_scalars.Timestamp,
+			},
+		},
+	})
+
+	return &graphql.Field{
+		Type: graphql.NewObject(graphql.ObjectConfig{
+			Name:        "Cache",
+			Description: "The cache of AWS describe calls",
+			Fields: graphql.Fields{
+				"max_entries": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"size": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"evictions": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"kinds": &graphql.Field{
+					Type: graphql.NewList(kindType),
+				},
+				"entries": &graphql.Field{
+					Type: graphql.NewList(entryType),
+				},
+			},
+		}),
+		Args: graphql.FieldConfigArgument{
+			"customer_id": &graphql.ArgumentConfig{
+				Description: "Only list the entries for this customer.",
+				Type:        graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			_, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
+			if err != nil {
+				return nil, err
+			}
+
+			customerId, _ := p.Args["customer_id"].(string)
+
+			var (
+				stats   = c.resolver.Cache.Stats()
+				kinds   = make([]map[string]interface{}, len(stats.Kinds))
+				entries []map[string]interface{}
+			)
+
+			for i, k := range stats.Kinds {
+				kinds[i] = map[string]interface{}{
+					"kind":    k.Kind,
+					"ttl":     int(k.TTL.Seconds()),
+					"entries": k.Entries,
+					"hits":    int(k.Hits),
+					"misses":  int(k.Misses),
+				}
+			}
+
+			for _, e := range c.resolver.Cache.Entries(customerId) {
+				entries = append(entries, map[string]interface{}{
+					"customer_id": e.CustomerId,
+					"region":      e.Region,
+					"vpc_id":      e.VpcId,
+					"kind":        e.Kind,
+					"request":     e.Request,
+					"expires":     opsee_types.NewTimestamp(e.Expires),
+				})
+			}
+
+			return map[string]interface{}{
+				"max_entries": stats.MaxEntries,
+				"size":        stats.Entries,
+				"evictions":   int(stats.Evictions),
+				"kinds":       kinds,
+				"entries":     entries,
+			}, nil
+		},
+	}
+}
+
+func (c *Composter) queryRateLimits() *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+			Name:        "RateLimitUsage",
+			Description: "A customer's use of its quota of one kind of AWS call",
+			Fields: graphql.Fields{
+				"customer_id": &graphql.Field{
+					Type: graphql.String,
+				},
+				"kind": &graphql.Field{
+					Type:        graphql.String,
+					Description: "reads or mutations",
+				},
+				"rate": &graphql.Field{
+					Type:        graphql.Float,
+					Description: "The calls allowed per second, zero if unlimited",
+				},
+				"burst": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"concurrency": &graphql.Field{
+					Type:        graphql.Int,
+					Description: "The calls allowed at once, zero if unlimited",
+				},
+				"tokens": &graphql.Field{
+					Type:        graphql.Float,
+					Description: "The calls that may start now, negative while calls wait for the rate",
+				},
+				"in_flight": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"limited": &graphql.Field{
+					Type:        graphql.Int,
+					Description: "The calls rejected for exceeding the rate or the concurrency",
+				},
+			},
+		})),
+		Args: graphql.FieldConfigArgument{
+			"customer_id": &graphql.ArgumentConfig{
+				Description: "Only show this customer's usage.",
+				Type:        graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			_, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
+			if err != nil {
+				return nil, err
+			}
+
+			customerId, _ := p.Args["customer_id"].(string)
+
+			var usage []map[string]interface{}
+			for _, u := range c.resolver.RateLimiter.Usage(customerId) {
+				usage = append(usage, map[string]interface{}{
+					"customer_id": u.CustomerId,
+					"kind":        u.Kind,
+					"rate":        u.Limit.Rate,
+					"burst":       u.Limit.Burst,
+					"concurrency": u.Limit.Concurrency,
+					"tokens":      u.Tokens,
+					"in_flight":   u.InFlight,
+					"limited":     int(u.Limited),
+				})
+			}
+
+			return usage, nil
+		},
+	}
+}
+
+func (c *Composter) queryAuditEvents() *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+			Name:        "AuditEvent",
+			Description: "A mutation run through compost",
+			Fields: graphql.Fields{
+				"time": &graphql.Field{
+					Type: opsee_scalars.Timestamp,
+				},
+				"requestor_id": &graphql.Field{
+					Type: graphql.Int,
+				},
+				"customer_id": &graphql.Field{
+					Type: graphql.String,
+				},
+				"mutation": &graphql.Field{
+					Type:        graphql.String,
+					Description: "The mutation's field, such as deleteChecks or region.rebootInstances",
+				},
+				"region": &graphql.Field{
+					Type: graphql.String,
+				},
+				"arguments": &graphql.Field{
+					Type:        JsonScalar,
+					Description: "The mutation's arguments, with secrets redacted",
+				},
+				"outcome": &graphql.Field{
+					Type:        graphql.String,
+					Description: "success or failure",
+				},
+				"error": &graphql.Field{
+					Type: graphql.String,
+				},
+				"error_code": &graphql.Field{
+					Type: graphql.String,
+				},
+				"duration_ms": &graphql.Field{
+					Type: graphql.Float,
+				},
+			},
+		})),
+		Args: graphql.FieldConfigArgument{
+			"customer_id": &graphql.ArgumentConfig{
+				Description: "Only show this customer's events.",
+				Type:        graphql.String,
+			},
+			"mutation": &graphql.ArgumentConfig{
+				Description: "Only show events of this mutation.",
+				Type:        graphql.String,
+			},
+			"limit": &graphql.ArgumentConfig{
+				Description:  "The most events to show.",
+				Type:         graphql.Int,
+				DefaultValue: 100,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			_, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
+			if err != nil {
+				return nil, err
+			}
+
+			customerId, _ := p.Args["customer_id"].(string)
+			mutation, _ := p.Args["mutation"].(string)
+			limit, _ := p.Args["limit"].(int)
+
+			var events []map[string]interface{}
+			for _, e := range c.config.AuditLog.Events(AuditFilter{CustomerId: customerId, Mutation: mutation}, limit) {
+				args, err := json.Marshal(e.Arguments)
+				if err != nil {
+					return nil, err
+				}
+
+				events = append(events, map[string]interface{}{
+					"time":         opsee_types.NewTimestamp(e.Time),
+					"requestor_id": int(e.RequestorId),
+					"customer_id":  e.CustomerId,
+					"mutation":     e.Mutation,
+					"region":       e.Region,
+					"arguments":    json.RawMessage(args),
+					"outcome":      e.Outcome,
+					"error":        e.Error,
+					"error_code":   string(e.ErrorCode),
+					"duration_ms":  e.DurationMs,
+				})
+			}
+
+			return events, nil
+		},
+	}
+}
+
+func (c *Composter) querySystemStatus() *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewObject(graphql.ObjectConfig{
+			Name:        "SystemStatus",
+			Description: "Whether compost is ready to serve, as reported by /ready",
+			Fields: graphql.Fields{
+				"ready": &graphql.Field{
+					Type:        graphql.Boolean,
+					Description: "Whether every dependency is healthy",
+				},
+				"dependencies": &graphql.Field{
+					Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+						Name:        "DependencyStatus",
+						Description: "A backend compost calls",
+						Fields: graphql.Fields{
+							"name": &graphql.Field{
+								Type: graphql.String,
+							},
+							"kind": &graphql.Field{
+								Type:        graphql.String,
+								Description: "grpc, http or etcd",
+							},
+							"target": &graphql.Field{
+								Type:        graphql.String,
+								Description: "The dependency's address or url",
+							},
+							"state": &graphql.Field{
+								Type:        graphql.String,
+								Description: "The gRPC connectivity state, or REACHABLE or UNREACHABLE",
+							},
+							"healthy": &graphql.Field{
+								Type: graphql.Boolean,
+							},
+							"last_error": &graphql.Field{
+								Type:        graphql.String,
+								Description: "The most recent error, which may be from before the dependency recovered",
+							},
+							"last_error_time": &graphql.Field{
+								Type: opsee_scalars.Timestamp,
+							},
+						},
+					})),
+				},
+			},
+		}),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			_, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
+			if err != nil {
+				return nil, err
+			}
+
+			readiness := c.readiness(p.Context)
+
+			var dependencies []map[string]interface{}
+			for _, d := range readiness.Dependencies {
+				dependency := map[string]interface{}{
+					"name":       d.Name,
+					"kind":       d.Kind,
+					"target":     d.Target,
+					"state":      d.State,
+					"healthy":    d.Healthy,
+					"last_error": d.LastError,
+				}
+
+				if d.LastErrorTime != nil {
+					dependency["last_error_time"] = opsee_types.NewTimestamp(*d.LastErrorTime)
+				}
+
+				dependencies = append(dependencies, dependency)
+			}
+
+			return map[string]interface{}{
+				"ready":        readiness.Ready,
+				"dependencies": dependencies,
+			}, nil
+		},
+	}
+}
+
+// queryListCustomers returns a connection of every customer, in the order
+// cats lists their users.
+func (c *Composter) queryListCustomers() *graphql.Field {
+	return &graphql.Field{
+		Type: connectionType("Customer", schema.GraphQLCustomerType),
+		Args: connectionArgs(graphql.FieldConfigArgument{}),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			requestor, err := UserPermittedFromContext(p.Context, opsee_types.OpseeAdmin)
+			if err != nil {
+				return nil, err
+			}
+
+			return customerConnection(p.Context, &customerPager{c.resolver, requestor}, p.Args)
+		},
+	}
+}
+
 func (c *Composter) queryHasRole() *graphql.Field {
 	return &graphql.Field{
 		Type: graphql.Boolean,
@@ -819,8 +1234,8 @@ func (c *Composter) queryNotifications() *graphql.Field {
 
 func (c *Composter) queryChecks() *graphql.Field {
 	return &graphql.Field{
-		Type: graphql.NewList(CheckType),
-		Args: graphql.FieldConfigArgument{
+		Type: connectionType("Check", c.checkType),
+		Args: connectionArgs(graphql.FieldConfigArgument{
 			"id": &graphql.ArgumentConfig{
 				Description: "A single check Id",
 				Type:        graphql.String,
@@ -829,7 +1244,7 @@ func (c *Composter) queryChecks() *graphql.Field {
 				Description: "A check station transition ID",
 				Type:        graphql.Int,
 			},
-		},
+		}),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			user, ok := p.Context.Value(userKey).(*schema.User)
 			if !ok {
@@ -839,7 +1254,27 @@ func (c *Composter) queryChecks() *graphql.Field {
 			id, _ := p.Args["id"].(string)
 			transitionId, _ := p.Args["state_transition_id"].(int)
 
-			return c.resolver.ListChecks(p.Context, user, id, transitionId)
+			checks, err := c.resolver.ListChecks(p.Context, user, id, transitionId)
+			if err != nil {
+				return nil, err
+			}
+
+			conn, err := newConnection(p.Args, "check", sortByKey(checks, checkKey), checkKey)
+			if err != nil {
+				return nil, err
+			}
+
+			// fetch the results of the checks on the page at once, rather than
+			// one at a time as their results fields are resolved
+			if transitionId == 0 && selectsCheckResults(p.Info) {
+				page := make([]*schema.Check, len(conn.Edges))
+				for i, e := range conn.Edges {
+					page[i] = e.Node.(*schema.Check)
+				}
+				c.resolver.PrefetchCheckResults(p.Context, user, page)
+			}
+
+			return conn, nil
 		},
 	}
 }
@@ -852,6 +1287,7 @@ func (c *Composter) queryRegion() *graphql.Field {
 			Fields: graphql.Fields{
 				"vpc":             c.queryVpc(),
 				"task_definition": c.queryTaskDefinition(),
+				"alarms":          c.queryRegionAlarms(),
 			},
 		}),
 		Args: graphql.FieldConfigArgument{
@@ -954,30 +1390,30 @@ func (c *Composter) queryVpc() *graphql.Field {
 
 func (c *Composter) queryGroups() *graphql.Field {
 	return &graphql.Field{
-		Type: graphql.NewList(graphql.NewUnion(graphql.UnionConfig{
+		Type: connectionType("Group", graphql.NewUnion(graphql.UnionConfig{
 			Name:        "Group",
 			Description: "A group target",
 			Types: []*graphql.Object{
 				opsee_aws_ec2.GraphQLSecurityGroupType,
-				EcsServiceType,
-				opsee_aws_elb.GraphQLLoadBalancerDescriptionType,
-				opsee_aws_autoscaling.GraphQLGroupType,
+				c.ecsServiceType,
+				c.loadBalancerType,
+				c.autoScalingGroupType,
 			},
 			ResolveType: func(value interface{}, info graphql.ResolveInfo) *graphql.Object {
 				switch value.(type) {
 				case *opsee_aws_ec2.SecurityGroup:
 					return opsee_aws_ec2.GraphQLSecurityGroupType
 				case *opsee_aws_ecs.Service:
-					return EcsServiceType
+					return c.ecsServiceType
 				case *opsee_aws_elb.LoadBalancerDescription:
-					return opsee_aws_elb.GraphQLLoadBalancerDescriptionType
+					return c.loadBalancerType
 				case *opsee_aws_autoscaling.Group:
-					return opsee_aws_autoscaling.GraphQLGroupType
+					return c.autoScalingGroupType
 				}
 				return nil
 			},
 		})),
-		Args: graphql.FieldConfigArgument{
+		Args: connectionArgs(filterArgs(graphql.FieldConfigArgument{
 			"id": &graphql.ArgumentConfig{
 				Description: "An optional group identifier",
 				Type:        graphql.String,
@@ -986,7 +1422,7 @@ func (c *Composter) queryGroups() *graphql.Field {
 				Description: "A group type (security, elb, autoscaling)",
 				Type:        graphql.String,
 			},
-		},
+		})),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			user, ok := p.Context.Value(userKey).(*schema.User)
 			if !ok {
@@ -1001,37 +1437,43 @@ func (c *Composter) queryGroups() *graphql.Field {
 			groupId, _ := p.Args["id"].(string)
 			groupType, _ := p.Args["type"].(string)
 
-			groups, err := c.resolver.GetGroups(p.Context, user, queryContext.Region, queryContext.VpcId, groupType, groupId)
-			if err != nil {
+			groups, err := c.resolver.GetGroups(p.Context, user, queryContext.Region, queryContext.VpcId, groupType, groupId, resourceFilter(p.Args))
+			if groupsErr, ok := err.(resolver.GroupsError); ok {
+				// return the groups we have, and an error for each type we don't
+				for _, typeErr := range groupsErr {
+					addFieldError(p.Context, p.Info, typeErr)
+				}
+			} else if err != nil {
 				log.WithError(err).Error("error querying groups")
 				return nil, err
 			}
 
-			return groups, nil
+			nodes, kind, position := orderResources(p.Args, "group", sortByKey(groups, groupKey), groupKey)
+			return newConnection(p.Args, kind, nodes, position)
 		},
 	}
 }
 
 func (c *Composter) queryInstances() *graphql.Field {
 	return &graphql.Field{
-		Type: graphql.NewList(graphql.NewUnion(graphql.UnionConfig{
+		Type: connectionType("Instance", graphql.NewUnion(graphql.UnionConfig{
 			Name:        "Instance",
 			Description: "An instance target",
 			Types: []*graphql.Object{
-				InstanceType,
-				DbInstanceType,
+				c.instanceType,
+				c.dbInstanceType,
 			},
 			ResolveType: func(value interface{}, info graphql.ResolveInfo) *graphql.Object {
 				switch value.(type) {
 				case *opsee_aws_ec2.Instance:
-					return InstanceType
+					return c.instanceType
 				case *opsee_aws_rds.DBInstance:
-					return DbInstanceType
+					return c.dbInstanceType
 				}
 				return nil
 			},
 		})),
-		Args: graphql.FieldConfigArgument{
+		Args: connectionArgs(filterArgs(graphql.FieldConfigArgument{
 			"id": &graphql.ArgumentConfig{
 				Description: "An optional instance id",
 				Type:        graphql.String,
@@ -1040,7 +1482,7 @@ func (c *Composter) queryInstances() *graphql.Field {
 				Description: "An instance type (rds, ec2)",
 				Type:        graphql.NewNonNull(graphql.String),
 			},
-		},
+		})),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			user, ok := p.Context.Value(userKey).(*schema.User)
 			if !ok {
@@ -1059,7 +1501,13 @@ func (c *Composter) queryInstances() *graphql.Field {
 				return nil, errMissingInstanceType
 			}
 
-			return c.resolver.GetInstances(p.Context, user, queryContext.Region, queryContext.VpcId, instanceType, instanceId)
+			instances, err := c.resolver.GetInstances(p.Context, user, queryContext.Region, queryContext.VpcId, instanceType, instanceId, resourceFilter(p.Args))
+			if err != nil {
+				return nil, err
+			}
+
+			nodes, kind, position := orderResources(p.Args, "instance", sortByKey(instances, instanceKey), instanceKey)
+			return newConnection(p.Args, kind, nodes, position)
 		},
 	}
 }
@@ -1119,234 +1567,13 @@ func (c *Composter) instanceAction(action instanceAction) *graphql.Field {
 	}
 }
 
-func (c *Composter) queryMetrics() *graphql.Field {
-	return &graphql.Field{
-		Type: graphql.NewObject(graphql.ObjectConfig{
-			Name:        "Metrics",
-			Description: "Cloudwatch instance metrics",
-			Fields: graphql.Fields{
-				"BackendConnectionErrors":               c.queryMetricName("BackendConnectionErrors"),
-				"CPUCreditUsage":                        c.queryMetricName("CPUCreditUsage"),
-				"CPUCreditBalance":                      c.queryMetricName("CPUCreditBalance"),
-				"VolumeWriteBytes":                      c.queryMetricName("VolumeWriteBytes"),
-				"VolumeIdleTime":                        c.queryMetricName("VolumeIdleTime"),
-				"StatusCheckFailed_Instance":            c.queryMetricName("StatusCheckFailed_Instance"),
-				"StatusCheckFailed_System":              c.queryMetricName("StatusCheckFailed_System"),
-				"StatusCheckFailed":                     c.queryMetricName("StatusCheckFailed"),
-				"VolumeReadBytes":                       c.queryMetricName("VolumeReadBytes"),
-				"SurgeQueueLength":                      c.queryMetricName("SurgeQueueLength"),
-				"ReadLatency":                           c.queryMetricName("ReadLatency"),
-				"DiskWriteOps":                          c.queryMetricName("DiskWriteOps"),
-				"NetworkPacketsOut":                     c.queryMetricName("NetworkPacketsOut"),
-				"OldestReplicationSlotLag":              c.queryMetricName("OldestReplicationSlotLag"),
-				"RequestCount":                          c.queryMetricName("RequestCount"),
-				"NumberOfMessagesPublished":             c.queryMetricName("NumberOfMessagesPublished"),
-				"NumberOfEmptyReceives":                 c.queryMetricName("NumberOfEmptyReceives"),
-				"Evictions":                             c.queryMetricName("Evictions"),
-				"CurrItems":                             c.queryMetricName("CurrItems"),
-				"CurrConnections":                       c.queryMetricName("CurrConnections"),
-				"HealthyHostCount":                      c.queryMetricName("HealthyHostCount"),
-				"CmdTouch":                              c.queryMetricName("CmdTouch"),
-				"UnHealthyHostCount":                    c.queryMetricName("UnHealthyHostCount"),
-				"ReadIOPS":                              c.queryMetricName("ReadIOPS"),
-				"ReadThroughput":                        c.queryMetricName("ReadThroughput"),
-				"CasHits":                               c.queryMetricName("CasHits"),
-				"VolumeQueueLength":                     c.queryMetricName("VolumeQueueLength"),
-				"SwapUsage":                             c.queryMetricName("SwapUsage"),
-				"MemoryReservation":                     c.queryMetricName("MemoryReservation"),
-				"NetworkReceiveThroughput":              c.queryMetricName("NetworkReceiveThroughput"),
-				"GetMisses":                             c.queryMetricName("GetMisses"),
-				"ApproximateNumberOfMessagesNotVisible": c.queryMetricName("ApproximateNumberOfMessagesNotVisible"),
-				"CPUUtilization":                        c.queryMetricName("CPUUtilization"),
-				"DatabaseConnections":                   c.queryMetricName("DatabaseConnections"),
-				"Latency":                               c.queryMetricName("Latency"),
-				"VolumeWriteOps":                        c.queryMetricName("VolumeWriteOps"),
-				"MemoryUtilization":                     c.queryMetricName("MemoryUtilization"),
-				"FreeStorageSpace":                      c.queryMetricName("FreeStorageSpace"),
-				"EvictedUnfetched":                      c.queryMetricName("EvictedUnfetched"),
-				"DecrMisses":                            c.queryMetricName("DecrMisses"),
-				"CmdGet":                                c.queryMetricName("CmdGet"),
-				"HTTPCode_Backend_2XX":                  c.queryMetricName("HTTPCode_Backend_2XX"),
-				"HTTPCode_Backend_3XX":                  c.queryMetricName("HTTPCode_Backend_3XX"),
-				"DiskWriteBytes":                        c.queryMetricName("DiskWriteBytes"),
-				"TransactionLogsDiskUsage":              c.queryMetricName("TransactionLogsDiskUsage"),
-				"DiskReadBytes":                         c.queryMetricName("DiskReadBytes"),
-				"DiskReadOps":                           c.queryMetricName("DiskReadOps"),
-				"NetworkIn":                             c.queryMetricName("NetworkIn"),
-				"ApproximateNumberOfMessagesVisible":    c.queryMetricName("ApproximateNumberOfMessagesVisible"),
-				"NumberOfMessagesSent":                  c.queryMetricName("NumberOfMessagesSent"),
-				"WriteIOPS":                             c.queryMetricName("WriteIOPS"),
-				"HTTPCode_Backend_5XX":                  c.queryMetricName("HTTPCode_Backend_5XX"),
-				"BytesUsedForHash":                      c.queryMetricName("BytesUsedForHash"),
-				"NewConnections":                        c.queryMetricName("NewConnections"),
-				"DiskQueueDepth":                        c.queryMetricName("DiskQueueDepth"),
-				"WriteLatency":                          c.queryMetricName("WriteLatency"),
-				"DeleteHits":                            c.queryMetricName("DeleteHits"),
-				"NetworkTransmitThroughput":             c.queryMetricName("NetworkTransmitThroughput"),
-				"BinLogDiskUsage":                       c.queryMetricName("BinLogDiskUsage"),
-				"VolumeTotalWriteTime":                  c.queryMetricName("VolumeTotalWriteTime"),
-				"TouchMisses":                           c.queryMetricName("TouchMisses"),
-				"CmdConfigGet":                          c.queryMetricName("CmdConfigGet"),
-				"IncrMisses":                            c.queryMetricName("IncrMisses"),
-				"FreeableMemory":                        c.queryMetricName("FreeableMemory"),
-				"Throttles":                             c.queryMetricName("Throttles"),
-				"Reclaimed":                             c.queryMetricName("Reclaimed"),
-				"NetworkPacketsIn":                      c.queryMetricName("NetworkPacketsIn"),
-				"IncrHits":                              c.queryMetricName("IncrHits"),
-				"VolumeReadOps":                         c.queryMetricName("VolumeReadOps"),
-				"WriteThroughput":                       c.queryMetricName("WriteThroughput"),
-				"BucketSizeBytes":                       c.queryMetricName("BucketSizeBytes"),
-				"NetworkOut":                            c.queryMetricName("NetworkOut"),
-				"ReplicaLag":                            c.queryMetricName("ReplicaLag"),
-				"TriggeredRules":                        c.queryMetricName("TriggeredRules"),
-				"BytesUsedForCacheItems":                c.queryMetricName("BytesUsedForCacheItems"),
-				"Invocations":                           c.queryMetricName("Invocations"),
-				"IncomingLogEvents":                     c.queryMetricName("IncomingLogEvents"),
-				"TouchHits":                             c.queryMetricName("TouchHits"),
-				"DecrHits":                              c.queryMetricName("DecrHits"),
-				"VolumeTotalReadTime":                   c.queryMetricName("VolumeTotalReadTime"),
-				"NetworkBytesOut":                       c.queryMetricName("NetworkBytesOut"),
-				"HTTPCode_Backend_4XX":                  c.queryMetricName("HTTPCode_Backend_4XX"),
-				"CasMisses":                             c.queryMetricName("CasMisses"),
-				"NumberOfNotificationsFailed":           c.queryMetricName("NumberOfNotificationsFailed"),
-				"HTTPCode_ELB_5XX":                      c.queryMetricName("HTTPCode_ELB_5XX"),
-				"ExpiredUnfetched":                      c.queryMetricName("ExpiredUnfetched"),
-				"NumberOfObjects":                       c.queryMetricName("NumberOfObjects"),
-				"NewItems":                              c.queryMetricName("NewItems"),
-				"CurrConfig":                            c.queryMetricName("CurrConfig"),
-				"CmdConfigSet":                          c.queryMetricName("CmdConfigSet"),
-				"CmdFlush":                              c.queryMetricName("CmdFlush"),
-				"SentMessageSize":                       c.queryMetricName("SentMessageSize"),
-				"NumberOfMessagesDeleted":               c.queryMetricName("NumberOfMessagesDeleted"),
-				"PublishSize":                           c.queryMetricName("PublishSize"),
-				"NumberOfMessagesReceived":              c.queryMetricName("NumberOfMessagesReceived"),
-				"UnusedMemory":                          c.queryMetricName("UnusedMemory"),
-				"NumberOfNotificationsDelivered":        c.queryMetricName("NumberOfNotificationsDelivered"),
-				"CmdSet":                 c.queryMetricName("CmdSet"),
-				"IncomingBytes":          c.queryMetricName("IncomingBytes"),
-				"Duration":               c.queryMetricName("Duration"),
-				"BytesReadIntoMemcached": c.queryMetricName("BytesReadIntoMemcached"),
-				"NetworkBytesIn":         c.queryMetricName("NetworkBytesIn"),
-				"GetHits":                c.queryMetricName("GetHits"),
-				"ApproximateNumberOfMessagesDelayed": c.queryMetricName("ApproximateNumberOfMessagesDelayed"),
-				"BytesWrittenOutFromMemcached":       c.queryMetricName("BytesWrittenOutFromMemcached"),
-				"Errors":                             c.queryMetricName("Errors"),
-				"DeleteMisses":                       c.queryMetricName("DeleteMisses"),
-				"CPUReservation":                     c.queryMetricName("CPUReservation"),
-				"CasBadval":                          c.queryMetricName("CasBadval"),
-				"MatchedEvents":                      c.queryMetricName("MatchedEvents"),
-			},
-		}),
-		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-			_, ok := p.Context.Value(userKey).(*schema.User)
-			if !ok {
-				return nil, errDecodeUser
-			}
-
-			_, ok = p.Context.Value(queryContextKey).(*QueryContext)
-			if !ok {
-				return nil, errDecodeQueryContext
-			}
-
-			var (
-				namespace  string
-				dimensions []*opsee_aws_cloudwatch.Dimension
-			)
-
-			switch t := p.Source.(type) {
-			case *opsee_aws_ec2.Instance:
-				namespace = "AWS/EC2"
-				dimensions = []*opsee_aws_cloudwatch.Dimension{
-					{
-						Name:  aws.String("InstanceId"),
-						Value: t.InstanceId,
-					},
-				}
-			case *opsee_aws_rds.DBInstance:
-				namespace = "AWS/RDS"
-				dimensions = []*opsee_aws_cloudwatch.Dimension{
-					{
-						Name:  aws.String("DBInstanceIdentifier"),
-						Value: t.DBInstanceIdentifier,
-					},
-				}
-			case *opsee_aws_ecs.Service:
-				clustername, err := clusterNameFromArn(t.ClusterArn)
-				if err != nil {
-					return nil, err
-				}
-
-				namespace = "AWS/ECS"
-				dimensions = []*opsee_aws_cloudwatch.Dimension{
-					{
-						Name:  aws.String("ClusterName"),
-						Value: clustername,
-					},
-					{
-						Name:  aws.String("ServiceName"),
-						Value: t.ServiceName,
-					},
-				}
-			default:
-				return nil, errUnknownInstanceMetricType
-			}
-
-			var (
-				interval  = 3600
-				period    = 60
-				endTime   = time.Now().UTC().Add(time.Duration(-1) * time.Minute) // 1 minute lag.  otherwise we won't get stats
-				startTime = endTime.Add(time.Duration(-1*interval) * time.Second)
-				startTs   = &opsee_types.Timestamp{}
-				endTs     = &opsee_types.Timestamp{}
-			)
-
-			startTs.Scan(startTime)
-			endTs.Scan(endTime)
-
-			return &opsee_aws_cloudwatch.GetMetricStatisticsInput{
-				StartTime:  startTs,
-				EndTime:    endTs,
-				Period:     aws.Int64(int64(period)),
-				Namespace:  aws.String(namespace),
-				Statistics: []string{"Average"},
-				Dimensions: dimensions,
-			}, nil
-		},
-	}
-}
-
-func (c *Composter) queryMetricName(metricName string) *graphql.Field {
-	return &graphql.Field{
-		Type: schema.GraphQLCloudWatchResponseType,
-		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-			user, ok := p.Context.Value(userKey).(*schema.User)
-			if !ok {
-				return nil, errDecodeUser
-			}
-
-			queryContext, ok := p.Context.Value(queryContextKey).(*QueryContext)
-			if !ok {
-				return nil, errDecodeQueryContext
-			}
-
-			input, ok := p.Source.(*opsee_aws_cloudwatch.GetMetricStatisticsInput)
-			if !ok {
-				return nil, errDecodeMetricStatisticsInput
-			}
-
-			input.MetricName = aws.String(metricName)
-
-			return c.resolver.GetMetricStatistics(p.Context, user, queryContext.Region, input)
-		},
-	}
-}
-
 func (c *Composter) mutation() *graphql.Object {
 	mutation := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Mutation",
 		Fields: graphql.Fields{
 			"checks":                    c.upsertChecks(),
 			"deleteChecks":              c.deleteChecks(),
+			"importAlarms":              c.importAlarms(),
 			"testCheck":                 c.testCheck(),
 			"makeLaunchRoleUrlTemplate": c.makeLaunchRoleUrlTemplate(),
 			"makeLaunchRoleUrl":         c.makeLaunchRoleUrl(),
@@ -1639,7 +1866,7 @@ func (c *Composter) makeLaunchRoleUrl() *graphql.Field {
 
 func (c *Composter) upsertChecks() *graphql.Field {
 	return &graphql.Field{
-		Type: graphql.NewList(CheckType),
+		Type: graphql.NewList(c.checkType),
 		Args: graphql.FieldConfigArgument{
 			"checks": &graphql.ArgumentConfig{
 				Description: "A list of checks to create",
@@ -1733,7 +1960,7 @@ func addFields(obj *graphql.Object, fields graphql.FieldDefinitionMap) {
 func clusterNameFromArn(arn *string) (*string, error) {
 	arnParts := strings.Split(aws.StringValue(arn), "/")
 	if len(arnParts) < 2 {
-		return nil, fmt.Errorf("invalid cluster ARN")
+		return nil, resolver.NewError(resolver.ErrorInvalidInput, "invalid cluster ARN")
 	}
 
 	return aws.String(arnParts[len(arnParts)-1]), nil
@@ -3,8 +3,20 @@ package composter
 import (
 	// "github.com/graphql-go/graphql"
 	// "github.com/stretchr/testify/assert"
-	// "encoding/json"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	"github.com/opsee/compost/resolver/fake"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSchema(t *testing.T) {
@@ -77,3 +89,112 @@ func TestSchema(t *testing.T) {
 }
 
 // {"checks":[{"id":"up8ZQoHRDYbJL8mSS3z8Y","interval":30,"check_spec":{"value":{"name":"WELCOME","path":"/","port":80,"verb":"GET","protocol":"http"},"type_url":"HttpCheck"},"last_run":null,"name":"WELCOME","assertions":[{"check_id":"up8ZQoHRDYbJL8mSS3z8Y","customer_id":"a1de53d8-8974-11e5-9e7b-f349fb6fa040","key":"body","relationship":"contain","value":"","operand":"Welcome"}],"target":{"name":"test group","type":"sg","id":"sg-c6551ca2"}},{"id":"72u23sJlP3ZBjUWYlPWZx5","interval":30,"check_spec":{"value":{"name":"Http test group","path":"/","port":80,"verb":"GET","protocol":"http"},"type_url":"HttpCheck"},"last_run":null,"name":"Http test group","assertions":[{"check_id":"72u23sJlP3ZBjUWYlPWZx5","customer_id":"a1de53d8-8974-11e5-9e7b-f349fb6fa040","key":"code","relationship":"equal","value":"","operand":"200"}],"target":{"name":"test group","type":"sg","id":"sg-c6551ca2"}}]}
+
+// queryComposter runs query on c's /graphql as a user of customer-1,
+// returning the response's data.
+func queryComposter(t *testing.T, c *Composter, query string) map[string]interface{} {
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "http://compost/graphql", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := base64.StdEncoding.EncodeToString([]byte(`{"id": 1, "customer_id": "customer-1", "email": "dev@opsee.com", "verified": true, "active": true}`))
+	req.Header.Set("Authorization", "Basic "+user)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+
+	var result struct {
+		Data   map[string]interface{}   `json:"data"`
+		Errors []map[string]interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, result.Errors)
+
+	return result.Data
+}
+
+// instanceNodes returns the nodes of the instances connection in data.
+func instanceNodes(data map[string]interface{}) []map[string]interface{} {
+	var nodes []map[string]interface{}
+	region, _ := data["region"].(map[string]interface{})
+	vpc, _ := region["vpc"].(map[string]interface{})
+	instances, _ := vpc["instances"].(map[string]interface{})
+	edges, _ := instances["edges"].([]interface{})
+	for _, e := range edges {
+		edge, _ := e.(map[string]interface{})
+		node, _ := edge["node"].(map[string]interface{})
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func TestComposterClients(t *testing.T) {
+	// each composter resolves through its own client, not the first one's
+	var (
+		composters []*Composter
+		bezos      []*fake.Bezos
+	)
+	for i, alarmName := range []string{"first-cpu", "second-cpu"} {
+		backends := fake.New()
+		backends.Bezos.AddRegion("customer-1", "us-west-2", &fake.BezosRegion{
+			Instances: []*opsee_aws_ec2.Instance{
+				{InstanceId: aws.String("i-1"), VpcId: aws.String("vpc-1")},
+			},
+			Alarms: []*opsee_aws_cloudwatch.MetricAlarm{
+				{
+					AlarmName:             aws.String(alarmName),
+					Namespace:             aws.String("AWS/EC2"),
+					MetricName:            aws.String("CPUUtilization"),
+					Dimensions:            []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String("i-1")}},
+					StateReason:           aws.String("Threshold Crossed"),
+					StateUpdatedTimestamp: opsee_types.NewTimestamp(time.Unix(1476662400, 0)),
+				},
+			},
+			Metrics: []*fake.BezosMetric{
+				{
+					Namespace:  "AWS/EC2",
+					Name:       "CPUUtilization",
+					Dimensions: []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String("i-1")}},
+					Datapoints: []*opsee_aws_cloudwatch.Datapoint{{Average: aws.Float64(float64(i + 1))}},
+				},
+			},
+		})
+		composters = append(composters, New(backends.Client(), Config{}))
+		bezos = append(bezos, backends.Bezos)
+	}
+
+	query := `query alarms { region(id: "us-west-2") { vpc(id: "vpc-1") { instances(type: "ec2") { edges { node {
+		... on ec2Instance { alarms { AlarmName StateReason StateReasonData StateUpdatedTimestamp } metrics { CPUUtilization { metrics { value } } available } } } } } } } }`
+
+	for i, alarmName := range []string{"first-cpu", "second-cpu"} {
+		nodes := instanceNodes(queryComposter(t, composters[i], query))
+		if assert.Len(t, nodes, 1) {
+			if alarms, ok := nodes[0]["alarms"].([]interface{}); assert.True(t, ok) && assert.Len(t, alarms, 1) {
+				alarm := alarms[0].(map[string]interface{})
+				assert.Equal(t, alarmName, alarm["AlarmName"])
+				assert.Equal(t, "Threshold Crossed", alarm["StateReason"])
+				assert.Nil(t, alarm["StateReasonData"])
+				assert.NotNil(t, alarm["StateUpdatedTimestamp"])
+			}
+			assert.Equal(t, map[string]interface{}{
+				"CPUUtilization": map[string]interface{}{
+					"metrics": []interface{}{map[string]interface{}{"value": float64(i + 1)}},
+				},
+				"available": []interface{}{"CPUUtilization"},
+			}, nodes[0]["metrics"])
+		}
+
+		// describe instances, describe alarms, get metric statistics and list
+		// metrics
+		assert.Equal(t, 4, bezos[i].RequestCount())
+	}
+}
@@ -0,0 +1,127 @@
+package composter
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// selectedFields returns the names of the fields selected beneath the field
+// being resolved, following inline fragments and fragment spreads.
+func selectedFields(info graphql.ResolveInfo) []string {
+	return selectedFieldsAt(info)
+}
+
+// selectedFieldsAt returns the names of the fields selected beneath the
+// fields named by path, which starts beneath the field being resolved. A
+// connection's nodes' fields are at "edges", "node".
+func selectedFieldsAt(info graphql.ResolveInfo, path ...string) []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+		visit func(*ast.SelectionSet, []string)
+	)
+
+	visit = func(set *ast.SelectionSet, path []string) {
+		if set == nil {
+			return
+		}
+
+		for _, selection := range set.Selections {
+			switch t := selection.(type) {
+			case *ast.Field:
+				if t.Name == nil {
+					continue
+				}
+
+				if len(path) > 0 {
+					if t.Name.Value == path[0] {
+						visit(t.SelectionSet, path[1:])
+					}
+					continue
+				}
+
+				if !seen[t.Name.Value] {
+					seen[t.Name.Value] = true
+					names = append(names, t.Name.Value)
+				}
+			case *ast.InlineFragment:
+				visit(t.SelectionSet, path)
+			case *ast.FragmentSpread:
+				if t.Name == nil {
+					continue
+				}
+
+				if fragment, ok := info.Fragments[t.Name.Value].(*ast.FragmentDefinition); ok {
+					visit(fragment.SelectionSet, path)
+				}
+			}
+		}
+	}
+
+	for _, field := range info.FieldASTs {
+		visit(field.SelectionSet, path)
+	}
+
+	return names
+}
+
+// selectsCheckResults returns whether the nodes of the check connection being
+// resolved select a field that needs their results.
+func selectsCheckResults(info graphql.ResolveInfo) bool {
+	for _, name := range selectedFieldsAt(info, "edges", "node") {
+		if name == "results" || name == "alarms" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldPath returns the response keys leading to the field being resolved.
+// graphql-go doesn't track list indices, so a field beneath a list has the
+// path of the list's first element's field, less the index.
+func fieldPath(info graphql.ResolveInfo) []interface{} {
+	operation, ok := info.Operation.(*ast.OperationDefinition)
+	if !ok || len(info.FieldASTs) == 0 {
+		return nil
+	}
+
+	path, _ := findFieldPath(info, operation.SelectionSet, info.FieldASTs[0], nil)
+	return path
+}
+
+func findFieldPath(info graphql.ResolveInfo, set *ast.SelectionSet, target *ast.Field, path []interface{}) ([]interface{}, bool) {
+	if set == nil {
+		return nil, false
+	}
+
+	for _, selection := range set.Selections {
+		var subset *ast.SelectionSet
+		subpath := path
+
+		switch t := selection.(type) {
+		case *ast.Field:
+			key := t.Name.Value
+			if t.Alias != nil {
+				key = t.Alias.Value
+			}
+
+			subpath = append(path[:len(path):len(path)], key)
+			if t == target {
+				return subpath, true
+			}
+			subset = t.SelectionSet
+		case *ast.InlineFragment:
+			subset = t.SelectionSet
+		case *ast.FragmentSpread:
+			if fragment, ok := info.Fragments[t.Name.Value].(*ast.FragmentDefinition); ok {
+				subset = fragment.SelectionSet
+			}
+		}
+
+		if found, ok := findFieldPath(info, subset, target, subpath); ok {
+			return found, true
+		}
+	}
+
+	return nil, false
+}
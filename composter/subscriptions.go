@@ -0,0 +1,190 @@
+package composter
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"golang.org/x/net/context"
+)
+
+var (
+	errNotSubscription    = resolver.NewError(resolver.ErrorInvalidInput, "only subscription operations may be sent here")
+	errSubscriptionFields = resolver.NewError(resolver.ErrorInvalidInput, "a subscription must select exactly one field")
+)
+
+// graphql-go only executes queries and mutations, so subscriptions are
+// executed as queries of SubscriptionSchema, whose query root is the
+// Subscription type. A subscription is executed once to subscribe its root
+// field to an event source, then once for each event, with the event as the
+// root value.
+
+// subscriptionStart is in the context of a subscription's first execution,
+// and holds the events its root field subscribed to.
+type subscriptionStart struct {
+	events <-chan resolver.Event
+}
+
+// subscribeFunc subscribes a root field of the Subscription type to its
+// events.
+type subscribeFunc func(ctx context.Context, user *schema.User, args map[string]interface{}) (<-chan resolver.Event, error)
+
+func (c *Composter) subscription() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Subscription",
+		Description: "Changes to checks, sent as they occur",
+		Fields: graphql.Fields{
+			"checkStateChanged": &graphql.Field{
+				Type:        schema.GraphQLCheckStateTransitionType,
+				Description: "Sent each time the check passes or fails after doing the opposite",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Description: "The check id",
+						Type:        graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: subscriptionResolver(func(ctx context.Context, user *schema.User, args map[string]interface{}) (<-chan resolver.Event, error) {
+					id, _ := args["id"].(string)
+					if id == "" {
+						return nil, errMissingCheckId
+					}
+					return c.resolver.Events.CheckStateTransitions(ctx, user, id)
+				}),
+			},
+			"checkResults": &graphql.Field{
+				Type:        graphql.NewList(schema.GraphQLCheckResultType),
+				Description: "The check's current results, then its results each time they change",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Description: "The check id",
+						Type:        graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: subscriptionResolver(func(ctx context.Context, user *schema.User, args map[string]interface{}) (<-chan resolver.Event, error) {
+					id, _ := args["id"].(string)
+					if id == "" {
+						return nil, errMissingCheckId
+					}
+					return c.resolver.Events.CheckResults(ctx, user, id)
+				}),
+			},
+		},
+	})
+}
+
+// subscriptionResolver resolves a root field of the Subscription type: in a
+// subscription's first execution it subscribes, and afterwards it resolves to
+// the event being sent.
+func subscriptionResolver(subscribe subscribeFunc) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		start, ok := p.Context.Value(subscriptionStartKey).(*subscriptionStart)
+		if !ok {
+			return p.Source, nil
+		}
+
+		user, ok := p.Context.Value(userKey).(*schema.User)
+		if !ok {
+			return nil, errDecodeUser
+		}
+
+		events, err := subscribe(p.Context, user, p.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		start.events = events
+		return nil, nil
+	}
+}
+
+// Subscribe starts the subscription in request, sending a result for each of
+// its events until ctx is done or its event source fails, then closing the
+// channel. If the subscription can't be started, the result explaining why is
+// returned instead.
+func (c *Composter) Subscribe(ctx context.Context, schema graphql.Schema, request *GraphQLRequest) (<-chan *Result, *Result) {
+	doc, err := parseSubscription(request.Query)
+	if err != nil {
+		return nil, newResult(&graphql.Result{Errors: gqlerrors.FormatErrors(err)}, &fieldErrors{})
+	}
+
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		return nil, newResult(&graphql.Result{Errors: validation.Errors}, &fieldErrors{})
+	}
+
+	start := &subscriptionStart{}
+	result := c.execute(context.WithValue(ctx, subscriptionStartKey, start), schema, doc, request, nil)
+	if len(result.Errors) > 0 {
+		return nil, result
+	}
+
+	if start.events == nil {
+		return nil, errorResult(errNotSubscription)
+	}
+
+	results := make(chan *Result)
+	go func() {
+		defer close(results)
+
+		for event := range start.events {
+			if event.Err != nil {
+				result = errorResult(event.Err)
+			} else {
+				result = c.execute(ctx, schema, doc, request, event.Data)
+			}
+
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// parseSubscription parses a document with a single subscription operation
+// of one field, and turns the operation into a query so that graphql-go can
+// validate and execute it.
+func parseSubscription(query string) (*ast.Document, error) {
+	doc, err := parseRequest(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		if o, ok := def.(*ast.OperationDefinition); ok {
+			if op != nil || o.Operation != "subscription" {
+				return nil, errNotSubscription
+			}
+			op = o
+		}
+	}
+
+	if op == nil {
+		return nil, errNotSubscription
+	}
+
+	if op.SelectionSet == nil || len(op.SelectionSet.Selections) != 1 {
+		return nil, errSubscriptionFields
+	}
+
+	if _, ok := op.SelectionSet.Selections[0].(*ast.Field); !ok {
+		return nil, errSubscriptionFields
+	}
+
+	op.Operation = "query"
+	return doc, nil
+}
+
+// errorResult is a result with only err.
+func errorResult(err error) *Result {
+	return &Result{Errors: []*Error{{
+		Message:    err.Error(),
+		Locations:  []location.SourceLocation{},
+		Extensions: newErrorExtensions(resolver.ErrorOf(err)),
+	}}}
+}
@@ -0,0 +1,67 @@
+package composter
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type stubEvents struct {
+	transitions []*schema.CheckStateTransition
+}
+
+func (s *stubEvents) CheckStateTransitions(ctx context.Context, user *schema.User, checkId string) (<-chan resolver.Event, error) {
+	events := make(chan resolver.Event, len(s.transitions))
+	for _, t := range s.transitions {
+		events <- resolver.Event{Data: t}
+	}
+	close(events)
+	return events, nil
+}
+
+func (s *stubEvents) CheckResults(ctx context.Context, user *schema.User, checkId string) (<-chan resolver.Event, error) {
+	return nil, resolver.NewError(resolver.ErrorNotFound, "no such check")
+}
+
+func TestSubscribe(t *testing.T) {
+	c := New(&resolver.Client{Events: &stubEvents{
+		transitions: []*schema.CheckStateTransition{
+			{Id: 1, CheckId: "check-1", From: "OK", To: "FAIL_WAIT"},
+			{Id: 2, CheckId: "check-1", From: "FAIL_WAIT", To: "FAIL"},
+		},
+	}}, Config{})
+
+	ctx := context.WithValue(context.Background(), userKey, &schema.User{CustomerId: "customer-1"})
+
+	results, failed := c.Subscribe(ctx, c.SubscriptionSchema, &GraphQLRequest{
+		Query: `subscription transitions { checkStateChanged(id: "check-1") { id to } }`,
+	})
+	if !assert.Nil(t, failed) {
+		return
+	}
+
+	var to []interface{}
+	for result := range results {
+		assert.Empty(t, result.Errors)
+		data := result.Data.(map[string]interface{})["checkStateChanged"].(map[string]interface{})
+		to = append(to, data["to"])
+	}
+	assert.Equal(t, []interface{}{"FAIL_WAIT", "FAIL"}, to)
+
+	_, failed = c.Subscribe(ctx, c.SubscriptionSchema, &GraphQLRequest{
+		Query: `subscription results { checkResults(id: "check-1") { passing } }`,
+	})
+	if assert.NotNil(t, failed) && assert.Len(t, failed.Errors, 1) {
+		assert.Equal(t, resolver.ErrorNotFound, failed.Errors[0].Extensions.Code)
+	}
+
+	_, failed = c.Subscribe(ctx, c.SubscriptionSchema, &GraphQLRequest{
+		Query: `query results { checkResults(id: "check-1") { passing } }`,
+	})
+	if assert.NotNil(t, failed) && assert.Len(t, failed.Errors, 1) {
+		assert.Equal(t, errNotSubscription.Error(), failed.Errors[0].Message)
+	}
+}
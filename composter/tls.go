@@ -0,0 +1,178 @@
+package composter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	log "github.com/opsee/logrus"
+)
+
+// DefaultCertReloadInterval is how often the certificate files are checked
+// for changes when a TLSConfig doesn't say.
+const DefaultCertReloadInterval = time.Minute
+
+// TLSConfig configures the server's TLS. A zero TLSConfig serves plain HTTP.
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM certificate chain and key served.
+	// They're reloaded when either changes on disk.
+	CertFile string
+	KeyFile  string
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval time.Duration
+	// ClientCAFile is an optional PEM file of CAs. Clients presenting a
+	// certificate they verify may call /admin/graphql as an opsee admin
+	// without a bearer token.
+	ClientCAFile string
+	// ClientNames, if not empty, limits the client certificates accepted
+	// to those with one of these common names.
+	ClientNames []string
+}
+
+// Enabled returns whether the server should serve TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// serverTLSConfig returns the server's tls.Config, whose certificate is
+// reloaded from disk as it changes.
+func (c TLSConfig) serverTLSConfig() (*tls.Config, error) {
+	reloader, err := newCertReloader(c.CertFile, c.KeyFile, c.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		GetCertificate: reloader.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+
+		// clients without certificates still authenticate with tokens
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// certReloader serves a certificate and key pair, reloading it when either
+// file's modification time changes. Files are checked at most once an
+// interval, during a handshake. If reloading fails, the last good
+// certificate is served.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mut      sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+	checked  time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	if interval <= 0 {
+		interval = DefaultCertReloadInterval
+	}
+
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if time.Since(r.checked) >= r.interval {
+		if err := r.reload(); err != nil {
+			log.WithError(err).WithField("cert_file", r.certFile).Error("error reloading TLS certificate")
+		}
+	}
+
+	return r.cert, nil
+}
+
+// reload loads the certificate if its files changed since it was last loaded.
+// REQUIRES r.mut is held, or r is new.
+func (r *certReloader) reload() error {
+	r.checked = time.Now()
+
+	var modTimes [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if r.cert != nil {
+		log.WithField("cert_file", r.certFile).Info("reloaded TLS certificate")
+	}
+
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
+
+// certificateUser returns the admin user a request's verified client
+// certificate authenticates, if it has one.
+func (s *Composter) certificateUser(r *http.Request) (*schema.User, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(s.config.TLS.ClientNames) > 0 {
+		allowed := false
+		for _, n := range s.config.TLS.ClientNames {
+			if n == name {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return nil, false
+		}
+	}
+
+	return &schema.User{
+		Email:    name,
+		Name:     name,
+		Verified: true,
+		Admin:    true,
+		Active:   true,
+		Status:   "active",
+		Perms:    &schema.UserFlags{},
+	}, true
+}
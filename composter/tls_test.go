@@ -0,0 +1,190 @@
+package composter
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCert is a certificate signed by parent, or self-signed if parent is
+// nil.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	for file, data := range map[string][]byte{certFile: c.certPEM, keyFile: c.keyPEM} {
+		if err := ioutil.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compost-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		certFile = filepath.Join(dir, "server.crt")
+		keyFile  = filepath.Join(dir, "server.key")
+		caFile   = filepath.Join(dir, "ca.crt")
+		ca       = newTestCert(t, "compost test ca", nil)
+		server   = newTestCert(t, "compost", ca)
+		renewed  = newTestCert(t, "compost renewed", ca)
+	)
+
+	server.write(t, certFile, keyFile, time.Now().Add(-time.Hour))
+	if err := ioutil.WriteFile(caFile, ca.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate is reloaded once its files change
+	reloader, err := newCertReloader(certFile, keyFile, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed.write(t, certFile, keyFile, time.Now())
+	cert, err := reloader.getCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, renewed.tlsCertificate(t).Certificate, cert.Certificate)
+	}
+
+	// admin requests authenticate with client certificates
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	c := New(&resolver.Client{}, Config{TLS: TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		ClientNames:  []string{"compost-admin"},
+	}})
+	defer c.Close()
+	go c.StartHTTP(addr)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	for _, test := range []struct {
+		name string
+		code int
+	}{
+		{"compost-admin", http.StatusOK},
+		{"someone-else", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	} {
+		config := &tls.Config{RootCAs: roots}
+		if test.name != "" {
+			config.Certificates = []tls.Certificate{newTestCert(t, test.name, ca).tlsCertificate(t)}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+		var resp *http.Response
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			resp, err = client.Post("https://"+addr+"/admin/graphql", "application/json", bytes.NewBufferString(`{"query": "query status { systemStatus { ready } }"}`))
+			if err == nil {
+				break
+			}
+		}
+
+		if assert.NoError(t, err, test.name) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, test.code, resp.StatusCode, test.name)
+			if test.code == http.StatusOK {
+				assert.JSONEq(t, `{"data": {"systemStatus": {"ready": true}}}`, string(body))
+			}
+		}
+	}
+}
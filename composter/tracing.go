@@ -0,0 +1,73 @@
+package composter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/opsee/basic/tp"
+	"github.com/opsee/compost/tracing"
+	"golang.org/x/net/context"
+)
+
+// tracingHandler traces each request to next in a span, continuing the trace
+// in the request's traceparent header if it has one.
+func tracingHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		span, ctx := tracing.StartSpan(tracing.ExtractHTTP(r.Context(), r.Header), "HTTP "+r.Method+" "+r.URL.Path)
+		if span == nil {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		defer span.Finish()
+
+		span.SetTag("http.method", r.Method)
+		span.SetTag("http.path", r.URL.Path)
+
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetTag("http.status", recorder.status)
+	})
+}
+
+// spanDecodeFunc hands the request's span to the handler, since tp starts
+// each handler's context afresh.
+func spanDecodeFunc() tp.DecodeFunc {
+	return func(ctx context.Context, rw http.ResponseWriter, r *http.Request, p httprouter.Params) (context.Context, int, error) {
+		return tracing.ContextWithSpan(ctx, tracing.SpanFromContext(r.Context())), 0, nil
+	}
+}
+
+// statusRecorder records the status of a response. It can be hijacked, for
+// websockets.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response can't be hijacked")
+	}
+
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// pathString formats a field path like "checks.edges.0.node".
+func pathString(path []interface{}) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,52 @@
+package composter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opsee/compost/resolver"
+	"github.com/opsee/compost/resolver/fake"
+	"github.com/opsee/compost/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracing(t *testing.T) {
+	spans := tracing.NewMemoryExporter()
+	tracing.SetTracer(tracing.NewTracer(spans))
+	defer tracing.SetTracer(nil)
+
+	backends := fake.New()
+	c := New(resolver.NewClientWithBackends(resolver.WrapBackends(backends.Backends(), nil)), Config{})
+
+	req, err := http.NewRequest("POST", "http://compost/graphql", bytes.NewBufferString(`{"query": "query checks { checks { edges { node { id } } } }"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := base64.StdEncoding.EncodeToString([]byte(`{"id": 1, "customer_id": "customer-1", "email": "dev@opsee.com", "verified": true, "active": true}`))
+	req.Header.Set("Authorization", "Basic "+user)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	w := httptest.NewRecorder()
+	tracingHandler(c.router).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	byName := make(map[string]*tracing.SpanData)
+	for _, span := range spans.Spans() {
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceId, span.Name)
+		byName[span.Name] = span
+	}
+
+	request, field, call := byName["HTTP POST /graphql"], byName["Query.checks"], byName["bartnet ListChecks"]
+	if assert.NotNil(t, request) && assert.NotNil(t, field) && assert.NotNil(t, call) {
+		assert.Equal(t, "b7ad6b7169203331", request.ParentId)
+		assert.Equal(t, 200, request.Tags["http.status"])
+		assert.Equal(t, request.SpanId, field.ParentId)
+		assert.Equal(t, "checks", field.Tags["graphql.path"])
+		assert.Equal(t, field.SpanId, call.ParentId)
+	}
+}
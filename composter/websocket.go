@@ -0,0 +1,306 @@
+package composter
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+// Subscriptions are served over a websocket with the graphql-ws protocol of
+// subscriptions-transport-ws: the client sends connection_init, then start
+// and stop messages for each subscription, and is sent data messages with
+// each result and a complete message when a subscription ends.
+const (
+	wsProtocol = "graphql-ws"
+
+	wsConnectionInit      = "connection_init"
+	wsConnectionAck       = "connection_ack"
+	wsConnectionError     = "connection_error"
+	wsConnectionTerminate = "connection_terminate"
+	wsKeepAlive           = "ka"
+	wsStart               = "start"
+	wsStop                = "stop"
+	wsData                = "data"
+	wsError               = "error"
+	wsComplete            = "complete"
+
+	wsKeepAliveInterval = 20 * time.Second
+	wsWriteTimeout      = 10 * time.Second
+)
+
+var (
+	errNotInitialized  = resolver.NewError(resolver.ErrorUnauthenticated, "connection_init must be sent first")
+	errDuplicateId     = resolver.NewError(resolver.ErrorInvalidInput, "a subscription with that id is already started")
+	errUnknownMessage  = resolver.NewError(resolver.ErrorInvalidInput, "unknown message type")
+	errDecodeWsRequest = resolver.NewError(resolver.ErrorInvalidInput, "error decoding subscription request")
+)
+
+type wsMessage struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsReply struct {
+	Id      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// wsInitPayload carries the authorization of a client that can't set the
+// header of the websocket request, like a browser.
+type wsInitPayload struct {
+	Authorization string `json:"authorization"`
+}
+
+// subscriptionsHandler upgrades a request to a websocket serving
+// subscriptions. Clients authenticate with the same bearer tokens as
+// /admin/graphql, either in the request's Authorization header or in the
+// connection_init payload.
+func (s *Composter) subscriptionsHandler() http.Handler {
+	origins := make([]*regexp.Regexp, len(s.config.CORSOrigins))
+	for i, o := range s.config.CORSOrigins {
+		origins[i] = regexp.MustCompile(o)
+	}
+
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{wsProtocol},
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+
+			for _, o := range origins {
+				if o.MatchString(origin) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var user *schema.User
+		if header := r.Header.Get("authorization"); header != "" {
+			var (
+				status int
+				err    error
+			)
+
+			user, status, err = bearerUser(header)
+			if err != nil {
+				http.Error(rw, err.Error(), status)
+				return
+			}
+		}
+
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			log.WithError(err).Error("error upgrading subscriptions websocket")
+			return
+		}
+
+		sc := &subscriptionConn{
+			composter: s,
+			conn:      conn,
+			user:      user,
+			ops:       make(map[string]context.CancelFunc),
+		}
+		sc.serve()
+	})
+}
+
+// subscriptionConn is a websocket serving subscriptions.
+type subscriptionConn struct {
+	composter *Composter
+	conn      *websocket.Conn
+	user      *schema.User
+
+	writeMut sync.Mutex
+
+	opsMut sync.Mutex
+	ops    map[string]context.CancelFunc
+}
+
+func (sc *subscriptionConn) serve() {
+	ctx, cancel := context.WithCancel(sc.composter.subscriptionsCtx)
+	defer func() {
+		cancel()
+		sc.conn.Close()
+	}()
+
+	go sc.closeOnShutdown(ctx)
+
+	for {
+		var msg wsMessage
+		if err := sc.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.WithError(err).Info("subscriptions websocket closed")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case wsConnectionInit:
+			if ctx.Value(userKey) != nil {
+				continue
+			}
+
+			if err := sc.init(msg.Payload); err != nil {
+				sc.write(wsReply{Type: wsConnectionError, Payload: errorResult(err)})
+				return
+			}
+
+			ctx = context.WithValue(ctx, userKey, sc.user)
+			sc.write(wsReply{Type: wsConnectionAck})
+			go sc.keepAlive(ctx)
+
+		case wsStart:
+			if ctx.Value(userKey) == nil {
+				sc.write(wsReply{Id: msg.Id, Type: wsError, Payload: errorResult(errNotInitialized)})
+				continue
+			}
+
+			request := &GraphQLRequest{}
+			if err := json.Unmarshal(msg.Payload, request); err != nil {
+				sc.write(wsReply{Id: msg.Id, Type: wsError, Payload: errorResult(errDecodeWsRequest)})
+				continue
+			}
+
+			if err := request.Validate(); err != nil {
+				sc.write(wsReply{Id: msg.Id, Type: wsError, Payload: errorResult(err)})
+				continue
+			}
+
+			sc.start(ctx, msg.Id, request)
+
+		case wsStop:
+			sc.stop(msg.Id)
+
+		case wsConnectionTerminate:
+			return
+
+		default:
+			sc.write(wsReply{Id: msg.Id, Type: wsError, Payload: errorResult(errUnknownMessage)})
+		}
+	}
+}
+
+// init authenticates the connection, unless its request already was.
+func (sc *subscriptionConn) init(payload json.RawMessage) error {
+	if sc.user != nil {
+		return nil
+	}
+
+	init := &wsInitPayload{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, init); err != nil {
+			return errDecodeWsRequest
+		}
+	}
+
+	if init.Authorization == "" {
+		return errDecodeUser
+	}
+
+	user, _, err := bearerUser(init.Authorization)
+	if err != nil {
+		return resolver.NewError(resolver.ErrorUnauthenticated, err.Error())
+	}
+
+	sc.user = user
+	return nil
+}
+
+// start starts a subscription, sending its results until it ends or is
+// stopped.
+func (sc *subscriptionConn) start(ctx context.Context, id string, request *GraphQLRequest) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	sc.opsMut.Lock()
+	_, exists := sc.ops[id]
+	if !exists {
+		sc.ops[id] = cancel
+	}
+	sc.opsMut.Unlock()
+
+	if exists {
+		cancel()
+		sc.write(wsReply{Id: id, Type: wsError, Payload: errorResult(errDuplicateId)})
+		return
+	}
+
+	results, failed := sc.composter.Subscribe(ctx, sc.composter.SubscriptionSchema, request)
+	if failed != nil {
+		sc.stop(id)
+		sc.write(wsReply{Id: id, Type: wsError, Payload: failed})
+		return
+	}
+
+	go func() {
+		for result := range results {
+			sc.write(wsReply{Id: id, Type: wsData, Payload: result})
+		}
+
+		sc.stop(id)
+		sc.write(wsReply{Id: id, Type: wsComplete})
+	}()
+}
+
+func (sc *subscriptionConn) stop(id string) {
+	sc.opsMut.Lock()
+	defer sc.opsMut.Unlock()
+
+	if cancel, ok := sc.ops[id]; ok {
+		cancel()
+		delete(sc.ops, id)
+	}
+}
+
+// closeOnShutdown tells the client compost is going away, and closes the
+// connection, if compost shuts down before ctx is otherwise done.
+func (sc *subscriptionConn) closeOnShutdown(ctx context.Context) {
+	<-ctx.Done()
+	if sc.composter.subscriptionsCtx.Err() == nil {
+		return
+	}
+
+	sc.writeMut.Lock()
+	sc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "compost is shutting down"), time.Now().Add(wsWriteTimeout))
+	sc.writeMut.Unlock()
+
+	sc.conn.Close()
+}
+
+func (sc *subscriptionConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sc.write(wsReply{Type: wsKeepAlive})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (sc *subscriptionConn) write(reply wsReply) {
+	sc.writeMut.Lock()
+	defer sc.writeMut.Unlock()
+
+	sc.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := sc.conn.WriteJSON(reply); err != nil {
+		log.WithError(err).WithField("type", reply.Type).Debug("error writing to subscriptions websocket")
+	}
+}
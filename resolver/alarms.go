@@ -0,0 +1,274 @@
+package resolver
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/opsee/basic/schema"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+)
+
+// The states of a CloudWatch alarm.
+const (
+	AlarmStateOK               = "OK"
+	AlarmStateAlarm            = "ALARM"
+	AlarmStateInsufficientData = "INSUFFICIENT_DATA"
+)
+
+var AlarmStates = []string{AlarmStateOK, AlarmStateAlarm, AlarmStateInsufficientData}
+
+// CheckTargetDimensions are the CloudWatch dimensions identifying the metrics
+// of each type of check target.
+var CheckTargetDimensions = map[string]string{
+	"instance":   "InstanceId",
+	"dbinstance": "DBInstanceIdentifier",
+	"elb":        "LoadBalancerName",
+	"asg":        "AutoScalingGroupName",
+}
+
+// alarmCheckStatistic is the only statistic an imported alarm may use:
+// cloudwatch checks assert on each period's average, and have no way to ask
+// for another statistic or a percentile.
+const alarmCheckStatistic = StatisticAverage
+
+var errImportAlarmNames = NewError(ErrorInvalidInput, "alarmNames must name at least one alarm")
+
+// alarmRelationships are the assertion relationships that pass when an alarm
+// with each comparison would be OK. Assertions have no inclusive comparisons,
+// so a check imported from a strict comparison also fails at the threshold.
+var alarmRelationships = map[string]string{
+	"GreaterThanThreshold":          "lessThan",
+	"GreaterThanOrEqualToThreshold": "lessThan",
+	"LessThanThreshold":             "greaterThan",
+	"LessThanOrEqualToThreshold":    "greaterThan",
+}
+
+// maxDescribeAlarmNames is the most alarm names DescribeAlarms takes at once.
+const maxDescribeAlarmNames = 100
+
+// GetAlarms returns the region's CloudWatch alarms matching the input, paging
+// through every result. Equal inputs are described once per request, so the
+// alarms of every resource in a list cost one call.
+func (c *Client) GetAlarms(ctx context.Context, user *schema.User, region string, input *opsee_aws_cloudwatch.DescribeAlarmsInput) ([]*opsee_aws_cloudwatch.MetricAlarm, error) {
+	resp, err := loaderFromContext(ctx).Load(loaderKeyFor("cloudwatch.DescribeAlarms", user.CustomerId, region, input), func() (interface{}, error) {
+		return c.getAlarms(ctx, user, region, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.([]*opsee_aws_cloudwatch.MetricAlarm), nil
+}
+
+func (c *Client) getAlarms(ctx context.Context, user *schema.User, region string, input *opsee_aws_cloudwatch.DescribeAlarmsInput) ([]*opsee_aws_cloudwatch.MetricAlarm, error) {
+	var alarms []*opsee_aws_cloudwatch.MetricAlarm
+
+	for {
+		resp, err := c.Bezos.Get(ctx, &opsee.BezosRequest{User: user, Region: region, VpcId: "global", Input: &opsee.BezosRequest_Cloudwatch_DescribeAlarmsInput{input}})
+		if err != nil {
+			return nil, err
+		}
+
+		output := resp.GetCloudwatch_DescribeAlarmsOutput()
+		if output == nil {
+			return nil, fmt.Errorf("error decoding aws response")
+		}
+
+		alarms = append(alarms, output.MetricAlarms...)
+
+		if output.NextToken == nil {
+			return alarms, nil
+		}
+
+		next := *input
+		next.NextToken = output.NextToken
+		input = &next
+	}
+}
+
+// GetResourceAlarms returns the region's alarms in the state, or in any state
+// if it's empty, on the metrics of the resource with the dimensions.
+func (c *Client) GetResourceAlarms(ctx context.Context, user *schema.User, region, state string, dimensions []*opsee_aws_cloudwatch.Dimension) ([]*opsee_aws_cloudwatch.MetricAlarm, error) {
+	input := &opsee_aws_cloudwatch.DescribeAlarmsInput{}
+	if state != "" {
+		input.StateValue = aws.String(state)
+	}
+
+	alarms, err := c.GetAlarms(ctx, user, region, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var resourceAlarms []*opsee_aws_cloudwatch.MetricAlarm
+	for _, alarm := range alarms {
+		if alarmHasDimensions(alarm, dimensions) {
+			resourceAlarms = append(resourceAlarms, alarm)
+		}
+	}
+
+	return resourceAlarms, nil
+}
+
+// alarmHasDimensions returns whether the alarm is on a metric with all of the
+// dimensions, and maybe others, as an alarm on a load balancer's latency in
+// one availability zone is still on the load balancer.
+func alarmHasDimensions(alarm *opsee_aws_cloudwatch.MetricAlarm, dimensions []*opsee_aws_cloudwatch.Dimension) bool {
+	for _, d := range dimensions {
+		found := false
+		for _, ad := range alarm.Dimensions {
+			if aws.StringValue(ad.Name) == aws.StringValue(d.Name) && aws.StringValue(ad.Value) == aws.StringValue(d.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return len(dimensions) > 0
+}
+
+// ImportAlarms converts the region's alarms with the names to cloudwatch checks
+// and creates them, or only returns them if dryRun is set. Nothing is created
+// unless every alarm converts, a name given twice is only imported once, and
+// empty names are ignored.
+func (c *Client) ImportAlarms(ctx context.Context, user *schema.User, region string, alarmNames []string, dryRun bool) ([]*schema.Check, error) {
+	var (
+		names = make([]string, 0, len(alarmNames))
+		seen  = make(map[string]bool)
+	)
+	for _, name := range alarmNames {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	alarmNames = names
+
+	if len(alarmNames) == 0 {
+		return nil, errImportAlarmNames
+	}
+
+	byName := make(map[string]*opsee_aws_cloudwatch.MetricAlarm)
+	for i := 0; i < len(alarmNames); i += maxDescribeAlarmNames {
+		end := i + maxDescribeAlarmNames
+		if end > len(alarmNames) {
+			end = len(alarmNames)
+		}
+
+		alarms, err := c.GetAlarms(ctx, user, region, &opsee_aws_cloudwatch.DescribeAlarmsInput{AlarmNames: alarmNames[i:end]})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, alarm := range alarms {
+			byName[aws.StringValue(alarm.AlarmName)] = alarm
+		}
+	}
+
+	checksInput := make([]interface{}, len(alarmNames))
+	for i, name := range alarmNames {
+		alarm, ok := byName[name]
+		if !ok {
+			return nil, Errorf(ErrorNotFound, "alarm %s not found in %s", name, region)
+		}
+
+		checkInput, err := alarmCheckInput(alarm)
+		if err != nil {
+			return nil, err
+		}
+
+		checksInput[i] = checkInput
+	}
+
+	if !dryRun {
+		return c.UpsertChecks(ctx, user, checksInput)
+	}
+
+	checks := make([]*schema.Check, len(checksInput))
+	for i, checkInput := range checksInput {
+		check, err := decodeCheckInput(checkInput.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		checks[i] = check
+	}
+
+	return checks, nil
+}
+
+// alarmCheckInput returns the input of a cloudwatch check that fails when the
+// alarm would be in alarm: on the alarm's metric, for the one resource it's
+// on, and only once it's failed for as long as the alarm evaluates.
+func alarmCheckInput(alarm *opsee_aws_cloudwatch.MetricAlarm) (map[string]interface{}, error) {
+	name := aws.StringValue(alarm.AlarmName)
+
+	relationship, ok := alarmRelationships[aws.StringValue(alarm.ComparisonOperator)]
+	if !ok {
+		return nil, Errorf(ErrorInvalidInput, "alarm %s has an unsupported comparison %s", name, aws.StringValue(alarm.ComparisonOperator))
+	}
+
+	switch statistic := aws.StringValue(alarm.Statistic); statistic {
+	case alarmCheckStatistic:
+	case "":
+		return nil, Errorf(ErrorInvalidInput, "alarm %s uses a percentile, but checks can only assert on the %s", name, alarmCheckStatistic)
+	default:
+		return nil, Errorf(ErrorInvalidInput, "alarm %s uses the %s statistic, but checks can only assert on the %s", name, statistic, alarmCheckStatistic)
+	}
+
+	if len(alarm.Dimensions) != 1 {
+		return nil, Errorf(ErrorInvalidInput, "alarm %s must be on a single instance, RDS instance, load balancer or autoscaling group", name)
+	}
+
+	var (
+		dimension  = alarm.Dimensions[0]
+		targetType string
+	)
+
+	for t, d := range CheckTargetDimensions {
+		if d == aws.StringValue(dimension.Name) {
+			targetType = t
+			break
+		}
+	}
+
+	if targetType == "" {
+		return nil, Errorf(ErrorInvalidInput, "alarm %s has no check target for dimension %s", name, aws.StringValue(dimension.Name))
+	}
+
+	checkInput := map[string]interface{}{
+		"name": name,
+		"target": map[string]interface{}{
+			"type": targetType,
+			"id":   aws.StringValue(dimension.Value),
+			"name": aws.StringValue(dimension.Value),
+		},
+		"cloudwatch_check": map[string]interface{}{
+			"metrics": []interface{}{
+				map[string]interface{}{
+					"namespace": aws.StringValue(alarm.Namespace),
+					"name":      aws.StringValue(alarm.MetricName),
+				},
+			},
+		},
+		"assertions": []interface{}{
+			map[string]interface{}{
+				"key":          "cloudwatch",
+				"value":        aws.StringValue(alarm.MetricName),
+				"relationship": relationship,
+				"operand":      strconv.FormatFloat(aws.Float64Value(alarm.Threshold), 'f', -1, 64),
+			},
+		},
+	}
+
+	if failingTime := aws.Int64Value(alarm.EvaluationPeriods) * aws.Int64Value(alarm.Period); failingTime > 0 {
+		checkInput["min_failing_time"] = failingTime
+	}
+
+	return checkInput, nil
+}
@@ -0,0 +1,163 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/opsee/basic/schema"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee "github.com/opsee/basic/service"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// pagedAlarmsBezos returns one alarm per page.
+type pagedAlarmsBezos struct {
+	alarms []*opsee_aws_cloudwatch.MetricAlarm
+	calls  int
+}
+
+func (b *pagedAlarmsBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	b.calls++
+
+	i := 0
+	if token := in.GetCloudwatch_DescribeAlarmsInput().NextToken; token != nil {
+		i = int(aws.StringValue(token)[0] - '0')
+	}
+
+	output := &opsee_aws_cloudwatch.DescribeAlarmsOutput{MetricAlarms: b.alarms[i : i+1]}
+	if i+1 < len(b.alarms) {
+		output.NextToken = aws.String(string('0' + byte(i+1)))
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Cloudwatch_DescribeAlarmsOutput{output}}, nil
+}
+
+func TestGetResourceAlarms(t *testing.T) {
+	alarm := func(name string, dimensions ...string) *opsee_aws_cloudwatch.MetricAlarm {
+		a := &opsee_aws_cloudwatch.MetricAlarm{AlarmName: aws.String(name)}
+		for i := 0; i < len(dimensions); i += 2 {
+			a.Dimensions = append(a.Dimensions, &opsee_aws_cloudwatch.Dimension{Name: aws.String(dimensions[i]), Value: aws.String(dimensions[i+1])})
+		}
+		return a
+	}
+
+	bezos := &pagedAlarmsBezos{alarms: []*opsee_aws_cloudwatch.MetricAlarm{
+		alarm("latency", "LoadBalancerName", "web-elb"),
+		alarm("latency-2a", "LoadBalancerName", "web-elb", "AvailabilityZone", "us-west-2a"),
+		alarm("other-elb", "LoadBalancerName", "api-elb"),
+		alarm("cpu", "InstanceId", "i-1"),
+	}}
+
+	client := &Client{Bezos: bezos}
+	ctx := WithLoader(context.Background(), NewLoader())
+	user := &schema.User{CustomerId: "customer-1"}
+
+	alarms, err := client.GetResourceAlarms(ctx, user, "us-west-2", "", []*opsee_aws_cloudwatch.Dimension{
+		{Name: aws.String("LoadBalancerName"), Value: aws.String("web-elb")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, a := range alarms {
+		names = append(names, aws.StringValue(a.AlarmName))
+	}
+	assert.Equal(t, []string{"latency", "latency-2a"}, names)
+
+	// the second resource's alarms come from the same pages
+	alarms, err = client.GetResourceAlarms(ctx, user, "us-west-2", "", []*opsee_aws_cloudwatch.Dimension{
+		{Name: aws.String("InstanceId"), Value: aws.String("i-1")},
+	})
+	if assert.NoError(t, err) && assert.Len(t, alarms, 1) {
+		assert.Equal(t, "cpu", aws.StringValue(alarms[0].AlarmName))
+	}
+	assert.Equal(t, 4, bezos.calls)
+}
+
+func TestImportAlarmsDryRun(t *testing.T) {
+	bezos := &pagedAlarmsBezos{alarms: []*opsee_aws_cloudwatch.MetricAlarm{
+		{
+			AlarmName:          aws.String("cpu-high"),
+			Namespace:          aws.String("AWS/EC2"),
+			MetricName:         aws.String("CPUUtilization"),
+			Dimensions:         []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String("i-1")}},
+			Statistic:          aws.String(StatisticAverage),
+			ComparisonOperator: aws.String("GreaterThanThreshold"),
+			Threshold:          aws.Float64(80.5),
+			Period:             aws.Int64(300),
+			EvaluationPeriods:  aws.Int64(2),
+		},
+		{
+			AlarmName:          aws.String("latency-2a"),
+			Namespace:          aws.String("AWS/ELB"),
+			MetricName:         aws.String("Latency"),
+			Dimensions:         []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("LoadBalancerName"), Value: aws.String("web-elb")}, {Name: aws.String("AvailabilityZone"), Value: aws.String("us-west-2a")}},
+			Statistic:          aws.String(StatisticAverage),
+			ComparisonOperator: aws.String("GreaterThanThreshold"),
+			Threshold:          aws.Float64(1),
+		},
+		{
+			AlarmName:          aws.String("cpu-max"),
+			Namespace:          aws.String("AWS/EC2"),
+			MetricName:         aws.String("CPUUtilization"),
+			Dimensions:         []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String("i-1")}},
+			Statistic:          aws.String(StatisticMaximum),
+			ComparisonOperator: aws.String("GreaterThanThreshold"),
+			Threshold:          aws.Float64(95),
+		},
+		{
+			// percentile alarms have an extended statistic instead
+			AlarmName:          aws.String("cpu-p99"),
+			Namespace:          aws.String("AWS/EC2"),
+			MetricName:         aws.String("CPUUtilization"),
+			Dimensions:         []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String("i-1")}},
+			ComparisonOperator: aws.String("GreaterThanThreshold"),
+			Threshold:          aws.Float64(95),
+		},
+	}}
+
+	client := &Client{Bezos: bezos}
+	ctx := WithLoader(context.Background(), NewLoader())
+	user := &schema.User{CustomerId: "customer-1"}
+
+	checks, err := client.ImportAlarms(ctx, user, "us-west-2", []string{"cpu-high"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, checks, 1) {
+		check := checks[0]
+		assert.Equal(t, "cpu-high", check.Name)
+		assert.Equal(t, &schema.Target{Type: "instance", Id: "i-1", Name: "i-1"}, check.Target)
+		assert.Equal(t, []*schema.CloudWatchMetric{{Namespace: "AWS/EC2", Name: "CPUUtilization"}}, check.GetCloudwatchCheck().Metrics)
+		assert.Equal(t, []*schema.Assertion{{Key: "cloudwatch", Value: "CPUUtilization", Relationship: "lessThan", Operand: "80.5"}}, check.Assertions)
+		assert.EqualValues(t, 600, check.MinFailingTime)
+	}
+
+	// a repeated name is only imported once, and empty names are ignored
+	checks, err = client.ImportAlarms(ctx, user, "us-west-2", []string{"cpu-high", "", "cpu-high"}, true)
+	if assert.NoError(t, err) && assert.Len(t, checks, 1) {
+		assert.Equal(t, "cpu-high", checks[0].Name)
+	}
+
+	_, err = client.ImportAlarms(ctx, user, "us-west-2", []string{""}, true)
+	assert.Equal(t, ErrorInvalidInput, ErrorOf(err).Code)
+
+	// checks can't assert on other statistics
+	for _, name := range []string{"cpu-max", "cpu-p99"} {
+		_, err = client.ImportAlarms(ctx, user, "us-west-2", []string{"cpu-high", name}, true)
+		if assert.Error(t, err, name) {
+			assert.Equal(t, ErrorInvalidInput, ErrorOf(err).Code, name)
+			assert.Contains(t, err.Error(), name)
+		}
+	}
+
+	_, err = client.ImportAlarms(ctx, user, "us-west-2", []string{"cpu-high", "latency-2a"}, true)
+	assert.Equal(t, ErrorInvalidInput, ErrorOf(err).Code)
+
+	_, err = client.ImportAlarms(ctx, user, "us-west-2", []string{"missing"}, true)
+	assert.Equal(t, ErrorNotFound, ErrorOf(err).Code)
+}
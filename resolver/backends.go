@@ -0,0 +1,681 @@
+package resolver
+
+import (
+	"fmt"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/opsee/basic/clients/bartnet"
+	"github.com/opsee/basic/clients/beavis"
+	"github.com/opsee/basic/clients/hugs"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/tracing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Backend names, as used to key per-backend settings such as
+// ClientConfig.Timeouts.
+const (
+	BackendBartnet    = "bartnet"
+	BackendBeavis     = "beavis"
+	BackendSpanx      = "spanx"
+	BackendCats       = "cats"
+	BackendKeelhaul   = "keelhaul"
+	BackendHugs       = "hugs"
+	BackendBezos      = "bezos"
+	BackendMarktricks = "marktricks"
+	BackendEtcd       = "etcd"
+)
+
+// backend is embedded in each backend wrapper and runs every call to the
+// wrapped client through do.
+type backend struct {
+	name    string
+	timeout time.Duration
+	// ctx is the request that calls through the HTTP backends, whose
+	// client interfaces don't take a context, are made for. It's set on
+	// copies of the wrappers by Client.bartnetFor and Client.hugsFor, and
+	// compost's own HTTP clients send its span along with each request.
+	ctx context.Context
+}
+
+// requestContext returns the context the backend is bound to, if any.
+func (b backend) requestContext() context.Context {
+	if b.ctx != nil {
+		return b.ctx
+	}
+	return context.Background()
+}
+
+type backendResponse struct {
+	response interface{}
+	err      error
+}
+
+// do calls fn, giving up once ctx is done or the backend's timeout elapses.
+// fn runs in its own goroutine so that clients which don't take a context,
+// like bartnet, beavis and hugs, can be timed out too. Errors are returned as
+// *Error, classified by backendError. Every call is counted and timed, and
+// traced in a span whose context is sent along in gRPC metadata.
+func (b backend) do(ctx context.Context, method string, fn func(context.Context) (interface{}, error)) (resp interface{}, err error) {
+	span, ctx := tracing.StartSpan(ctx, b.name+" "+method)
+	span.SetTag("backend", b.name)
+	span.SetTag("method", method)
+	ctx = tracing.InjectGRPC(ctx)
+
+	defer func(start time.Time) {
+		observeCall(b.name, method, start, err)
+		if err != nil {
+			span.SetTag("code", string(ErrorOf(err).Code))
+		}
+		span.SetError(err)
+		span.Finish()
+	}(time.Now())
+
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	respChan := make(chan backendResponse, 1)
+	go func() {
+		// a panic here would take down the process, as graphql-go only
+		// recovers panics in the resolver's own goroutine
+		defer func() {
+			if r := recover(); r != nil {
+				respChan <- backendResponse{nil, &Error{Code: ErrorInternal, Backend: b.name, Message: fmt.Sprint(r)}}
+			}
+		}()
+
+		resp, err := fn(ctx)
+		respChan <- backendResponse{resp, err}
+	}()
+
+	select {
+	case resp := <-respChan:
+		if resp.err != nil {
+			return nil, backendError(b.name, resp.err)
+		}
+		return resp.response, nil
+	case <-ctx.Done():
+		return nil, &Error{
+			Code:      ErrorUpstreamUnavailable,
+			Backend:   b.name,
+			Retryable: true,
+			Message:   fmt.Sprintf("%s %s: %s", b.name, method, ctx.Err()),
+		}
+	}
+}
+
+// WrapBackends wraps each of the backends so that calls to them are bounded
+// by the backend's timeout, if one is given, and instrumented. NewClient
+// wraps the backends it dials; callers of NewClientWithBackends may wrap
+// theirs. Dynamo is passed through as it is, with the AWS SDK's own retries
+// and timeouts, and backends that are nil are left nil.
+func WrapBackends(backends Backends, timeouts map[string]time.Duration) Backends {
+	be := func(name string) backend {
+		return backend{name: name, timeout: timeouts[name]}
+	}
+
+	wrapped := Backends{Dynamo: backends.Dynamo}
+	if backends.Bartnet != nil {
+		wrapped.Bartnet = &bartnetBackend{backends.Bartnet, be(BackendBartnet)}
+	}
+	if backends.Beavis != nil {
+		wrapped.Beavis = &beavisBackend{backends.Beavis, be(BackendBeavis)}
+	}
+	if backends.Spanx != nil {
+		wrapped.Spanx = &spanxBackend{backends.Spanx, be(BackendSpanx)}
+	}
+	if backends.Cats != nil {
+		wrapped.Cats = &catsBackend{backends.Cats, be(BackendCats)}
+	}
+	if backends.Keelhaul != nil {
+		wrapped.Keelhaul = &keelhaulBackend{backends.Keelhaul, be(BackendKeelhaul)}
+	}
+	if backends.Hugs != nil {
+		wrapped.Hugs = &hugsBackend{backends.Hugs, be(BackendHugs)}
+	}
+	if backends.Bezos != nil {
+		wrapped.Bezos = &bezosBackend{backends.Bezos, be(BackendBezos)}
+	}
+	if backends.Marktricks != nil {
+		wrapped.Marktricks = &marktricksBackend{backends.Marktricks, be(BackendMarktricks)}
+	}
+	if backends.EtcdKeys != nil {
+		wrapped.EtcdKeys = &etcdBackend{backends.EtcdKeys, be(BackendEtcd)}
+	}
+
+	return wrapped
+}
+
+// bartnetFor returns the Bartnet client with its calls bound to ctx, so that
+// they're traced as part of the request and give up once it's done. Clients
+// that aren't wrapped, like the fakes, are returned as they are.
+func (c *Client) bartnetFor(ctx context.Context) bartnet.Client {
+	if b, ok := c.Bartnet.(*bartnetBackend); ok {
+		bound := *b
+		bound.ctx = ctx
+		return &bound
+	}
+	return c.Bartnet
+}
+
+// hugsFor returns the Hugs client with its calls bound to ctx, like
+// bartnetFor.
+func (c *Client) hugsFor(ctx context.Context) hugs.Client {
+	if b, ok := c.Hugs.(*hugsBackend); ok {
+		bound := *b
+		bound.ctx = ctx
+		return &bound
+	}
+	return c.Hugs
+}
+
+type bartnetBackend struct {
+	bartnet.Client
+	backend
+}
+
+// client returns the wrapped client bound to ctx, if it can be.
+func (b *bartnetBackend) client(ctx context.Context) bartnet.Client {
+	if c, ok := b.Client.(*bartnetClient); ok {
+		return c.withContext(ctx)
+	}
+	return b.Client
+}
+
+func (b *bartnetBackend) GetCheck(user *schema.User, id string) (*schema.Check, error) {
+	resp, err := b.do(b.requestContext(), "GetCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).GetCheck(user, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*schema.Check), nil
+}
+
+func (b *bartnetBackend) ListChecks(user *schema.User) ([]*schema.Check, error) {
+	resp, err := b.do(b.requestContext(), "ListChecks", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListChecks(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*schema.Check), nil
+}
+
+func (b *bartnetBackend) CreateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	resp, err := b.do(b.requestContext(), "CreateCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).CreateCheck(user, check)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*schema.Check), nil
+}
+
+func (b *bartnetBackend) UpdateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	resp, err := b.do(b.requestContext(), "UpdateCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).UpdateCheck(user, check)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*schema.Check), nil
+}
+
+func (b *bartnetBackend) DeleteCheck(user *schema.User, id string) error {
+	_, err := b.do(b.requestContext(), "DeleteCheck", func(ctx context.Context) (interface{}, error) {
+		return nil, b.client(ctx).DeleteCheck(user, id)
+	})
+	return err
+}
+
+func (b *bartnetBackend) TestCheck(user *schema.User, check *schema.Check) (*opsee.TestCheckResponse, error) {
+	resp, err := b.do(b.requestContext(), "TestCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).TestCheck(user, check)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.TestCheckResponse), nil
+}
+
+type beavisBackend struct {
+	beavis.Client
+	backend
+}
+
+func (b *beavisBackend) client(ctx context.Context) beavis.Client {
+	if c, ok := b.Client.(*beavisClient); ok {
+		return c.withContext(ctx)
+	}
+	return b.Client
+}
+
+func (b *beavisBackend) ListResults(user *schema.User) ([]*schema.CheckResult, error) {
+	resp, err := b.do(b.requestContext(), "ListResults", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListResults(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*schema.CheckResult), nil
+}
+
+func (b *beavisBackend) ListResultsCheck(user *schema.User, checkId string) ([]*schema.CheckResult, error) {
+	resp, err := b.do(b.requestContext(), "ListResultsCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListResultsCheck(user, checkId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*schema.CheckResult), nil
+}
+
+func (b *beavisBackend) ListResultsTarget(user *schema.User, targetId string) ([]*schema.CheckResult, error) {
+	resp, err := b.do(b.requestContext(), "ListResultsTarget", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListResultsTarget(user, targetId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*schema.CheckResult), nil
+}
+
+type hugsBackend struct {
+	hugs.Client
+	backend
+}
+
+func (b *hugsBackend) client(ctx context.Context) hugs.Client {
+	if c, ok := b.Client.(*hugsClient); ok {
+		return c.withContext(ctx)
+	}
+	return b.Client
+}
+
+func (b *hugsBackend) ListNotifications(user *schema.User) ([]*hugs.Notification, error) {
+	resp, err := b.do(b.requestContext(), "ListNotifications", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListNotifications(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*hugs.Notification), nil
+}
+
+func (b *hugsBackend) ListNotificationsDefault(user *schema.User) ([]*hugs.Notification, error) {
+	resp, err := b.do(b.requestContext(), "ListNotificationsDefault", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListNotificationsDefault(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*hugs.Notification), nil
+}
+
+func (b *hugsBackend) ListNotificationsCheck(user *schema.User, checkId string) ([]*hugs.Notification, error) {
+	resp, err := b.do(b.requestContext(), "ListNotificationsCheck", func(ctx context.Context) (interface{}, error) {
+		return b.client(ctx).ListNotificationsCheck(user, checkId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.([]*hugs.Notification), nil
+}
+
+func (b *hugsBackend) CreateNotifications(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	_, err := b.do(b.requestContext(), "CreateNotifications", func(ctx context.Context) (interface{}, error) {
+		return nil, b.client(ctx).CreateNotifications(user, noteReq)
+	})
+	return err
+}
+
+func (b *hugsBackend) CreateNotificationsDefault(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	_, err := b.do(b.requestContext(), "CreateNotificationsDefault", func(ctx context.Context) (interface{}, error) {
+		return nil, b.client(ctx).CreateNotificationsDefault(user, noteReq)
+	})
+	return err
+}
+
+func (b *hugsBackend) CreateNotificationsMulti(user *schema.User, noteReq []*hugs.NotificationRequest) error {
+	_, err := b.do(b.requestContext(), "CreateNotificationsMulti", func(ctx context.Context) (interface{}, error) {
+		return nil, b.client(ctx).CreateNotificationsMulti(user, noteReq)
+	})
+	return err
+}
+
+type spanxBackend struct {
+	opsee.SpanxClient
+	backend
+}
+
+func (b *spanxBackend) EnhancedCombatMode(ctx context.Context, in *opsee.EnhancedCombatModeRequest, opts ...grpc.CallOption) (*opsee.EnhancedCombatModeResponse, error) {
+	resp, err := b.do(ctx, "EnhancedCombatMode", func(ctx context.Context) (interface{}, error) {
+		return b.SpanxClient.EnhancedCombatMode(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.EnhancedCombatModeResponse), nil
+}
+
+func (b *spanxBackend) GetRoleStack(ctx context.Context, in *opsee.GetRoleStackRequest, opts ...grpc.CallOption) (*opsee.GetRoleStackResponse, error) {
+	resp, err := b.do(ctx, "GetRoleStack", func(ctx context.Context) (interface{}, error) {
+		return b.SpanxClient.GetRoleStack(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetRoleStackResponse), nil
+}
+
+func (b *spanxBackend) GetCredentials(ctx context.Context, in *opsee.GetCredentialsRequest, opts ...grpc.CallOption) (*opsee.GetCredentialsResponse, error) {
+	resp, err := b.do(ctx, "GetCredentials", func(ctx context.Context) (interface{}, error) {
+		return b.SpanxClient.GetCredentials(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetCredentialsResponse), nil
+}
+
+type catsBackend struct {
+	opsee.CatsClient
+	backend
+}
+
+func (b *catsBackend) GetCheckCount(ctx context.Context, in *opsee.GetCheckCountRequest, opts ...grpc.CallOption) (*opsee.GetCheckCountResponse, error) {
+	resp, err := b.do(ctx, "GetCheckCount", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetCheckCount(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetCheckCountResponse), nil
+}
+
+func (b *catsBackend) GetUser(ctx context.Context, in *opsee.GetUserRequest, opts ...grpc.CallOption) (*opsee.GetUserResponse, error) {
+	resp, err := b.do(ctx, "GetUser", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetUser(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetUserResponse), nil
+}
+
+func (b *catsBackend) UpdateUser(ctx context.Context, in *opsee.UpdateUserRequest, opts ...grpc.CallOption) (*opsee.UserTokenResponse, error) {
+	resp, err := b.do(ctx, "UpdateUser", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.UpdateUser(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.UserTokenResponse), nil
+}
+
+func (b *catsBackend) ListUsers(ctx context.Context, in *opsee.ListUsersRequest, opts ...grpc.CallOption) (*opsee.ListUsersResponse, error) {
+	resp, err := b.do(ctx, "ListUsers", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.ListUsers(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.ListUsersResponse), nil
+}
+
+func (b *catsBackend) InviteUser(ctx context.Context, in *opsee.InviteUserRequest, opts ...grpc.CallOption) (*opsee.InviteUserResponse, error) {
+	resp, err := b.do(ctx, "InviteUser", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.InviteUser(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.InviteUserResponse), nil
+}
+
+func (b *catsBackend) DeleteUser(ctx context.Context, in *opsee.DeleteUserRequest, opts ...grpc.CallOption) (*opsee.DeleteUserResponse, error) {
+	resp, err := b.do(ctx, "DeleteUser", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.DeleteUser(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.DeleteUserResponse), nil
+}
+
+func (b *catsBackend) GetTeam(ctx context.Context, in *opsee.GetTeamRequest, opts ...grpc.CallOption) (*opsee.GetTeamResponse, error) {
+	resp, err := b.do(ctx, "GetTeam", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetTeam(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetTeamResponse), nil
+}
+
+func (b *catsBackend) CreateTeam(ctx context.Context, in *opsee.CreateTeamRequest, opts ...grpc.CallOption) (*opsee.CreateTeamResponse, error) {
+	resp, err := b.do(ctx, "CreateTeam", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.CreateTeam(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.CreateTeamResponse), nil
+}
+
+func (b *catsBackend) UpdateTeam(ctx context.Context, in *opsee.UpdateTeamRequest, opts ...grpc.CallOption) (*opsee.UpdateTeamResponse, error) {
+	resp, err := b.do(ctx, "UpdateTeam", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.UpdateTeam(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.UpdateTeamResponse), nil
+}
+
+func (b *catsBackend) DeleteTeam(ctx context.Context, in *opsee.DeleteTeamRequest, opts ...grpc.CallOption) (*opsee.DeleteTeamResponse, error) {
+	resp, err := b.do(ctx, "DeleteTeam", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.DeleteTeam(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.DeleteTeamResponse), nil
+}
+
+func (b *catsBackend) GetCheckResults(ctx context.Context, in *opsee.GetCheckResultsRequest, opts ...grpc.CallOption) (*opsee.GetCheckResultsResponse, error) {
+	resp, err := b.do(ctx, "GetCheckResults", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetCheckResults(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetCheckResultsResponse), nil
+}
+
+func (b *catsBackend) GetCheckStateTransitions(ctx context.Context, in *opsee.GetCheckStateTransitionsRequest, opts ...grpc.CallOption) (*opsee.GetCheckStateTransitionsResponse, error) {
+	resp, err := b.do(ctx, "GetCheckStateTransitions", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetCheckStateTransitions(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetCheckStateTransitionsResponse), nil
+}
+
+func (b *catsBackend) GetChecks(ctx context.Context, in *opsee.GetChecksRequest, opts ...grpc.CallOption) (*opsee.GetChecksResponse, error) {
+	resp, err := b.do(ctx, "GetChecks", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetChecks(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetChecksResponse), nil
+}
+
+func (b *catsBackend) GetCheckSnapshot(ctx context.Context, in *opsee.GetCheckSnapshotRequest, opts ...grpc.CallOption) (*opsee.GetCheckSnapshotResponse, error) {
+	resp, err := b.do(ctx, "GetCheckSnapshot", func(ctx context.Context) (interface{}, error) {
+		return b.CatsClient.GetCheckSnapshot(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetCheckSnapshotResponse), nil
+}
+
+type keelhaulBackend struct {
+	opsee.KeelhaulClient
+	backend
+}
+
+func (b *keelhaulBackend) ListBastionStates(ctx context.Context, in *opsee.ListBastionStatesRequest, opts ...grpc.CallOption) (*opsee.ListBastionStatesResponse, error) {
+	resp, err := b.do(ctx, "ListBastionStates", func(ctx context.Context) (interface{}, error) {
+		return b.KeelhaulClient.ListBastionStates(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.ListBastionStatesResponse), nil
+}
+
+func (b *keelhaulBackend) ScanVpcs(ctx context.Context, in *opsee.ScanVpcsRequest, opts ...grpc.CallOption) (*opsee.ScanVpcsResponse, error) {
+	resp, err := b.do(ctx, "ScanVpcs", func(ctx context.Context) (interface{}, error) {
+		return b.KeelhaulClient.ScanVpcs(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.ScanVpcsResponse), nil
+}
+
+func (b *keelhaulBackend) LaunchStack(ctx context.Context, in *opsee.LaunchStackRequest, opts ...grpc.CallOption) (*opsee.LaunchStackResponse, error) {
+	resp, err := b.do(ctx, "LaunchStack", func(ctx context.Context) (interface{}, error) {
+		return b.KeelhaulClient.LaunchStack(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.LaunchStackResponse), nil
+}
+
+func (b *keelhaulBackend) AuthenticateBastion(ctx context.Context, in *opsee.AuthenticateBastionRequest, opts ...grpc.CallOption) (*opsee.AuthenticateBastionResponse, error) {
+	resp, err := b.do(ctx, "AuthenticateBastion", func(ctx context.Context) (interface{}, error) {
+		return b.KeelhaulClient.AuthenticateBastion(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.AuthenticateBastionResponse), nil
+}
+
+type bezosBackend struct {
+	opsee.BezosClient
+	backend
+}
+
+func (b *bezosBackend) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	resp, err := b.do(ctx, "Get", func(ctx context.Context) (interface{}, error) {
+		return b.BezosClient.Get(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.BezosResponse), nil
+}
+
+type marktricksBackend struct {
+	opsee.MarktricksClient
+	backend
+}
+
+func (b *marktricksBackend) GetMetrics(ctx context.Context, in *opsee.GetMetricsRequest, opts ...grpc.CallOption) (*opsee.GetMetricsResponse, error) {
+	resp, err := b.do(ctx, "GetMetrics", func(ctx context.Context) (interface{}, error) {
+		return b.MarktricksClient.GetMetrics(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.GetMetricsResponse), nil
+}
+
+func (b *marktricksBackend) QueryMetrics(ctx context.Context, in *opsee.QueryMetricsRequest, opts ...grpc.CallOption) (*opsee.QueryMetricsResponse, error) {
+	resp, err := b.do(ctx, "QueryMetrics", func(ctx context.Context) (interface{}, error) {
+		return b.MarktricksClient.QueryMetrics(ctx, in, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*opsee.QueryMetricsResponse), nil
+}
+
+type etcdBackend struct {
+	etcd.KeysAPI
+	backend
+}
+
+func (b *etcdBackend) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "Get", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.Get(ctx, key, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
+
+func (b *etcdBackend) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "Set", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.Set(ctx, key, value, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
+
+func (b *etcdBackend) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "Delete", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.Delete(ctx, key, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
+
+func (b *etcdBackend) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "Create", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.Create(ctx, key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
+
+func (b *etcdBackend) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "CreateInOrder", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.CreateInOrder(ctx, dir, value, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
+
+func (b *etcdBackend) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	resp, err := b.do(ctx, "Update", func(ctx context.Context) (interface{}, error) {
+		return b.KeysAPI.Update(ctx, key, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*etcd.Response), nil
+}
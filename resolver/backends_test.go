@@ -0,0 +1,163 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/tracing"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type slowBezos struct {
+	delay time.Duration
+}
+
+func (b *slowBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	time.Sleep(b.delay)
+	return &opsee.BezosResponse{}, nil
+}
+
+func TestBackendTimeout(t *testing.T) {
+	bezos := &bezosBackend{&slowBezos{50 * time.Millisecond}, backend{name: BackendBezos, timeout: 10 * time.Millisecond}}
+
+	_, err := bezos.Get(context.Background(), &opsee.BezosRequest{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bezos Get")
+		assert.Equal(t, ErrorUpstreamUnavailable, ErrorOf(err).Code)
+	}
+
+	bezos.timeout = time.Second
+	resp, err := bezos.Get(context.Background(), &opsee.BezosRequest{})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	var timedOut, ok dto.Metric
+	backendCalls.WithLabelValues(BackendBezos, "Get", string(ErrorUpstreamUnavailable)).Write(&timedOut)
+	backendCalls.WithLabelValues(BackendBezos, "Get", backendCallOK).Write(&ok)
+	assert.True(t, timedOut.GetCounter().GetValue() >= 1)
+	assert.True(t, ok.GetCounter().GetValue() >= 1)
+}
+
+type panickingBezos struct{}
+
+func (b *panickingBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	var resp *opsee.BezosResponse
+	return resp, resp.Output.(error)
+}
+
+func TestBackendPanic(t *testing.T) {
+	// a panicking client fails the call instead of the process
+	bezos := &bezosBackend{&panickingBezos{}, backend{name: BackendBezos}}
+
+	_, err := bezos.Get(context.Background(), &opsee.BezosRequest{})
+	if assert.Error(t, err) {
+		typed := ErrorOf(err)
+		assert.Equal(t, ErrorInternal, typed.Code)
+		assert.Equal(t, BackendBezos, typed.Backend)
+	}
+
+	// and backends that aren't given stay nil rather than wrapping nil
+	backends := WrapBackends(Backends{Bezos: &panickingBezos{}}, nil)
+	assert.NotNil(t, backends.Bezos)
+	assert.Nil(t, backends.Bartnet)
+	assert.Nil(t, backends.Cats)
+	assert.Nil(t, backends.EtcdKeys)
+}
+
+type failingBezos struct {
+	err error
+}
+
+func (b *failingBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	return nil, b.err
+}
+
+func TestBackendErrorCodes(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		code      ErrorCode
+		retryable bool
+	}{
+		{grpc.Errorf(codes.Unavailable, "connection refused"), ErrorUpstreamUnavailable, true},
+		{grpc.Errorf(codes.PermissionDenied, "no"), ErrorForbidden, false},
+		{grpc.Errorf(codes.NotFound, "no such vpc"), ErrorNotFound, false},
+		{grpc.Errorf(codes.InvalidArgument, "bad region"), ErrorInvalidInput, false},
+		{grpc.Errorf(codes.Internal, "oops"), ErrorInternal, false},
+		{NewError(ErrorNotFound, "already typed"), ErrorNotFound, false},
+	} {
+		bezos := &bezosBackend{&failingBezos{test.err}, backend{name: BackendBezos}}
+
+		_, err := bezos.Get(context.Background(), &opsee.BezosRequest{})
+		typed := ErrorOf(err)
+		assert.Equal(t, test.code, typed.Code, test.err.Error())
+		assert.Equal(t, test.retryable, typed.Retryable, test.err.Error())
+		assert.Equal(t, test.err.Error(), typed.Message)
+	}
+}
+
+func TestHTTPBackendTraceparent(t *testing.T) {
+	spans := tracing.NewMemoryExporter()
+	tracing.SetTracer(tracing.NewTracer(spans))
+	defer tracing.SetTracer(nil)
+
+	var (
+		mut          sync.Mutex
+		traceparents = make(map[string]string)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		traceparents[r.URL.Path] = r.Header.Get(tracing.TraceparentHeader)
+		mut.Unlock()
+
+		switch r.URL.Path {
+		case "/gql/checks":
+			body, _ := proto.Marshal(&opsee.CheckResourceRequest{Checks: []*schema.Check{{Id: "check-1"}}})
+			w.Write(body)
+		case "/notifications":
+			w.Write([]byte(`{"notifications": [{"check_id": "check-1", "type": "email", "value": "x@example.com"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	backends := WrapBackends(Backends{
+		Bartnet: newBartnetClient(server.URL),
+		Hugs:    newHugsClient(server.URL),
+	}, nil)
+	client := &Client{Bartnet: backends.Bartnet, Hugs: backends.Hugs}
+
+	root, ctx := tracing.StartSpan(context.Background(), "root")
+	user := &schema.User{CustomerId: "customer-1"}
+
+	checks, err := client.bartnetFor(ctx).ListChecks(user)
+	assert.NoError(t, err)
+	assert.Len(t, checks, 1)
+
+	notifs, err := client.hugsFor(ctx).ListNotifications(user)
+	assert.NoError(t, err)
+	assert.Len(t, notifs, 1)
+	root.Finish()
+
+	byName := make(map[string]*tracing.SpanData)
+	for _, span := range spans.Spans() {
+		byName[span.Name] = span
+	}
+
+	for path, name := range map[string]string{"/gql/checks": "bartnet ListChecks", "/notifications": "hugs ListNotifications"} {
+		sc, ok := tracing.ParseTraceparent(traceparents[path])
+		if assert.True(t, ok, path) && assert.NotNil(t, byName[name], name) {
+			assert.Equal(t, root.Context().TraceId, sc.TraceId)
+			assert.Equal(t, byName[name].SpanId, sc.SpanId)
+		}
+	}
+}
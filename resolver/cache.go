@@ -0,0 +1,280 @@
+package resolver
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// The kinds of Bezos describe calls a Cache holds, each with its own TTL.
+const (
+	CacheKindInstances       = "instances"
+	CacheKindGroups          = "groups"
+	CacheKindTaskDefinitions = "task_definitions"
+
+	// DefaultCacheMaxEntries bounds a Cache whose config doesn't.
+	DefaultCacheMaxEntries = 10000
+)
+
+// CacheKinds lists every cache kind.
+var CacheKinds = []string{CacheKindInstances, CacheKindGroups, CacheKindTaskDefinitions}
+
+// CacheConfig configures a Cache. A kind with no TTL is not cached.
+type CacheConfig struct {
+	TTLs       map[string]time.Duration
+	MaxEntries int
+}
+
+// Cache holds Bezos describe responses per customer, region, VPC and request,
+// evicting the least recently used entry once it is full.
+type Cache struct {
+	mut        sync.Mutex
+	ttls       map[string]time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	stats      map[string]*CacheKindStats
+	evictions  int64
+}
+
+// CacheEntry describes one cached response.
+type CacheEntry struct {
+	CustomerId string
+	Region     string
+	VpcId      string
+	Kind       string
+	Request    string
+	Expires    time.Time
+
+	key      string
+	response *opsee.BezosResponse
+}
+
+// CacheKindStats counts cache activity for one kind.
+type CacheKindStats struct {
+	Kind    string
+	TTL     time.Duration
+	Entries int
+	Hits    int64
+	Misses  int64
+}
+
+// CacheStats is a snapshot of a Cache.
+type CacheStats struct {
+	MaxEntries int
+	Entries    int
+	Evictions  int64
+	Kinds      []CacheKindStats
+}
+
+func NewCache(config CacheConfig) *Cache {
+	maxEntries := config.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+
+	ttls := make(map[string]time.Duration)
+	stats := make(map[string]*CacheKindStats)
+	for _, kind := range CacheKinds {
+		ttls[kind] = config.TTLs[kind]
+		stats[kind] = &CacheKindStats{Kind: kind, TTL: ttls[kind]}
+	}
+
+	return &Cache{
+		ttls:       ttls,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		stats:      stats,
+	}
+}
+
+// bezosCacheKind returns the cache kind of a Bezos request, or "" if its
+// response should not be cached.
+func bezosCacheKind(req *opsee.BezosRequest) string {
+	switch req.Input.(type) {
+	case *opsee.BezosRequest_Ec2_DescribeInstancesInput,
+		*opsee.BezosRequest_Rds_DescribeDBInstancesInput:
+		return CacheKindInstances
+	case *opsee.BezosRequest_Ec2_DescribeSecurityGroupsInput,
+		*opsee.BezosRequest_Elb_DescribeLoadBalancersInput,
+		*opsee.BezosRequest_Autoscaling_DescribeAutoScalingGroupsInput,
+		*opsee.BezosRequest_Ecs_ListClustersInput,
+		*opsee.BezosRequest_Ecs_ListContainerInstancesInput,
+		*opsee.BezosRequest_Ecs_DescribeContainerInstancesInput,
+		*opsee.BezosRequest_Ecs_ListServicesInput,
+		*opsee.BezosRequest_Ecs_DescribeServicesInput:
+		return CacheKindGroups
+	case *opsee.BezosRequest_Ecs_DescribeTaskDefinitionInput:
+		return CacheKindTaskDefinitions
+	}
+
+	return ""
+}
+
+// bezosCacheRequest serializes a Bezos request without its user, so that
+// every user of a customer shares the customer's entries.
+func bezosCacheRequest(req *opsee.BezosRequest) string {
+	anon := *req
+	anon.User = nil
+	anon.MaxAge = nil
+	return anon.String()
+}
+
+func (c *Cache) get(kind, key string) (*opsee.BezosResponse, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok && time.Now().After(elem.Value.(*CacheEntry).Expires) {
+		c.remove(elem)
+		ok = false
+	}
+
+	if !ok {
+		c.stats[kind].Misses++
+		return nil, false
+	}
+
+	c.stats[kind].Hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*CacheEntry).response, true
+}
+
+func (c *Cache) put(entry *CacheEntry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.stats[entry.Kind].Entries++
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+// remove must be called with the mutex held.
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*CacheEntry)
+	delete(c.entries, entry.key)
+	c.stats[entry.Kind].Entries--
+}
+
+// Invalidate drops every entry for a customer's region. It is safe to call on
+// a nil Cache.
+func (c *Cache) Invalidate(customerId, region string) {
+	if c == nil {
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*CacheEntry)
+		if entry.CustomerId == customerId && entry.Region == region {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Stats returns a snapshot of the cache's counters. A nil Cache has none.
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	stats := CacheStats{
+		MaxEntries: c.maxEntries,
+		Entries:    c.lru.Len(),
+		Evictions:  c.evictions,
+	}
+
+	for _, kind := range CacheKinds {
+		stats.Kinds = append(stats.Kinds, *c.stats[kind])
+	}
+
+	return stats
+}
+
+// Entries returns the unexpired entries for a customer, or for every
+// customer if customerId is empty, most recently used first.
+func (c *Cache) Entries(customerId string) []CacheEntry {
+	if c == nil {
+		return nil
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		entries []CacheEntry
+		now     = time.Now()
+	)
+
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*CacheEntry)
+		if now.After(entry.Expires) || (customerId != "" && entry.CustomerId != customerId) {
+			continue
+		}
+
+		entries = append(entries, *entry)
+	}
+
+	return entries
+}
+
+// cachingBezos serves Bezos describe calls from a Cache.
+type cachingBezos struct {
+	opsee.BezosClient
+	cache *Cache
+}
+
+func (b *cachingBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	kind := bezosCacheKind(in)
+	if kind == "" || b.cache.ttls[kind] <= 0 || in.User == nil {
+		return b.BezosClient.Get(ctx, in, opts...)
+	}
+
+	var (
+		request = bezosCacheRequest(in)
+		key     = fmt.Sprintf("%s|%s|%s|%s", in.User.CustomerId, in.Region, in.VpcId, request)
+	)
+
+	if resp, ok := b.cache.get(kind, key); ok {
+		return resp, nil
+	}
+
+	resp, err := b.BezosClient.Get(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.cache.put(&CacheEntry{
+		CustomerId: in.User.CustomerId,
+		Region:     in.Region,
+		VpcId:      in.VpcId,
+		Kind:       kind,
+		Request:    request,
+		Expires:    time.Now().Add(b.cache.ttls[kind]),
+		key:        key,
+		response:   resp,
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee "github.com/opsee/basic/service"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+type countingBezos struct {
+	requests int
+}
+
+func (b *countingBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	b.requests++
+	return &opsee.BezosResponse{}, nil
+}
+
+func TestCacheBezos(t *testing.T) {
+	var (
+		bezos = &countingBezos{}
+		user  = &schema.User{CustomerId: "customer"}
+		cache = NewCache(CacheConfig{
+			TTLs:       map[string]time.Duration{CacheKindInstances: time.Minute},
+			MaxEntries: 2,
+		})
+		cached = &cachingBezos{bezos, cache}
+	)
+
+	describe := func(user *schema.User, vpc string) {
+		_, err := cached.Get(context.Background(), &opsee.BezosRequest{
+			User:   user,
+			Region: "us-west-2",
+			VpcId:  vpc,
+			Input:  &opsee.BezosRequest_Ec2_DescribeInstancesInput{&opsee_aws_ec2.DescribeInstancesInput{}},
+		})
+		assert.NoError(t, err)
+	}
+
+	describe(user, "vpc-1")
+	describe(&schema.User{CustomerId: "customer", Email: "other@example.com"}, "vpc-1")
+	assert.Equal(t, 1, bezos.requests)
+
+	describe(user, "vpc-2")
+	describe(user, "vpc-3")
+	assert.Equal(t, 3, bezos.requests)
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.EqualValues(t, 1, stats.Evictions)
+	assert.EqualValues(t, 1, stats.Kinds[0].Hits)
+
+	cache.Invalidate("customer", "us-west-2")
+	assert.Empty(t, cache.Entries(""))
+
+	describe(user, "vpc-1")
+	assert.Equal(t, 4, bezos.requests)
+}
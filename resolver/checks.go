@@ -28,9 +28,10 @@ const (
 	MagicExecutionGroup = "127a7354-290e-11e6-b178-2bc1f6aefc14"
 )
 
-// ListChecks fetches Checks from Bartnet and CheckResults from Beavis
-// concurrently, then zips them together. If the request to Beavis fails,
-// then checks are returned without results.
+// ListChecks fetches Checks from Bartnet and their notifications from Hugs
+// concurrently, then zips them together. The checks' current results aren't
+// fetched, so that they can be loaded with CheckResults for just the checks a
+// request needs; a snapshot's are the ones it was taken with.
 func (c *Client) ListChecks(ctx context.Context, user *schema.User, checkId string, transitionId int) ([]*schema.Check, error) {
 	var (
 		responseChan = make(chan *checkCompostResponse, 2)
@@ -46,9 +47,9 @@ func (c *Client) ListChecks(ctx context.Context, user *schema.User, checkId stri
 		)
 
 		if checkId != "" {
-			notifs, err = c.Hugs.ListNotificationsCheck(user, checkId)
+			notifs, err = c.hugsFor(ctx).ListNotificationsCheck(user, checkId)
 		} else {
-			notifs, err = c.Hugs.ListNotifications(user)
+			notifs, err = c.hugsFor(ctx).ListNotifications(user)
 		}
 
 		if err != nil {
@@ -77,11 +78,15 @@ func (c *Client) ListChecks(ctx context.Context, user *schema.User, checkId stri
 			return nil, err
 		}
 
+		// a snapshot's results are the ones it was taken with, even if none
 		check := resp.Check
+		if check.Results == nil {
+			check.Results = []*schema.CheckResult{}
+		}
 		checks = append(checks, check)
 	} else {
 		if checkId != "" {
-			check, err := c.Bartnet.GetCheck(user, checkId)
+			check, err := c.bartnetFor(ctx).GetCheck(user, checkId)
 			if err != nil {
 				log.WithError(err).Error("couldn't list checks from bartnet")
 				return nil, err
@@ -89,7 +94,7 @@ func (c *Client) ListChecks(ctx context.Context, user *schema.User, checkId stri
 
 			checks = append(checks, check)
 		} else {
-			checks, err = c.Bartnet.ListChecks(user)
+			checks, err = c.bartnetFor(ctx).ListChecks(user)
 			if err != nil {
 				log.WithError(err).Error("couldn't list checks from bartnet")
 				return nil, err
@@ -114,14 +119,6 @@ func (c *Client) ListChecks(ctx context.Context, user *schema.User, checkId stri
 
 	for _, check := range checks {
 		if transitionId == 0 {
-			results, err := c.CheckResults(ctx, user, check.Id)
-			if err != nil {
-				log.WithError(err).Error("Error getting check results.")
-				return nil, err
-			}
-
-			check.Results = results
-
 			if check.Spec == nil {
 				if check.CheckSpec == nil {
 					continue
@@ -162,29 +159,21 @@ func (c *Client) UpsertChecks(ctx context.Context, user *schema.User, checksInpu
 		notifList, _ := check["notifications"].([]interface{})
 		delete(check, "notifications")
 
-		checkJson, err := json.Marshal(check)
+		checkProto, err := decodeCheckInput(check)
 		if err != nil {
-			log.WithError(err).Error("Error marshalling check from request.")
-			return nil, err
-		}
-
-		checkProto := &schema.Check{}
-		err = jsonpb.Unmarshal(bytes.NewBuffer(checkJson), checkProto)
-		if err != nil {
-			log.WithError(err).Error("Error unmarshalling check protobuf.")
 			return nil, err
 		}
 
 		var checkResponse *schema.Check
 
 		if checkProto.Id == "" {
-			checkResponse, err = c.Bartnet.CreateCheck(user, checkProto)
+			checkResponse, err = c.bartnetFor(ctx).CreateCheck(user, checkProto)
 			if err != nil {
 				log.WithError(err).Error("Error creating check.")
 				return nil, err
 			}
 		} else {
-			checkResponse, err = c.Bartnet.UpdateCheck(user, checkProto)
+			checkResponse, err = c.bartnetFor(ctx).UpdateCheck(user, checkProto)
 			if err != nil {
 				log.WithError(err).Error("Error updating check.")
 				return nil, err
@@ -219,7 +208,7 @@ func (c *Client) UpsertChecks(ctx context.Context, user *schema.User, checksInpu
 			notifs = append(notifs, notif)
 		}
 
-		err = c.Hugs.CreateNotificationsMulti(user, notifs)
+		err = c.hugsFor(ctx).CreateNotificationsMulti(user, notifs)
 		if err != nil {
 			log.WithError(err).Error("Error creating notification")
 			return nil, err
@@ -231,6 +220,24 @@ func (c *Client) UpsertChecks(ctx context.Context, user *schema.User, checksInpu
 	return checksResponse, nil
 }
 
+// decodeCheckInput decodes a check from its input, less its notifications.
+func decodeCheckInput(check map[string]interface{}) (*schema.Check, error) {
+	checkJson, err := json.Marshal(check)
+	if err != nil {
+		log.WithError(err).Error("Error marshalling check from request.")
+		return nil, err
+	}
+
+	checkProto := &schema.Check{}
+	err = jsonpb.Unmarshal(bytes.NewBuffer(checkJson), checkProto)
+	if err != nil {
+		log.WithError(err).Error("Error unmarshalling check protobuf.")
+		return nil, err
+	}
+
+	return checkProto, nil
+}
+
 func (c *Client) DeleteChecks(ctx context.Context, user *schema.User, checksInput []interface{}) ([]string, error) {
 	deleted := make([]string, 0, len(checksInput))
 	for _, ci := range checksInput {
@@ -241,7 +248,7 @@ func (c *Client) DeleteChecks(ctx context.Context, user *schema.User, checksInpu
 			return nil, err
 		}
 
-		err := c.Bartnet.DeleteCheck(user, id)
+		err := c.bartnetFor(ctx).DeleteCheck(user, id)
 		if err != nil {
 			log.WithError(err).Error("Error deleting check: %d", id)
 			continue
@@ -303,7 +310,7 @@ func (c *Client) TestCheck(ctx context.Context, user *schema.User, checkInput ma
 		Quorum:    true,
 	})
 
-	if len(response.Node.Nodes) == 0 {
+	if err != nil || len(response.Node.Nodes) == 0 {
 		return nil, fmt.Errorf("no bastions found")
 	}
 
@@ -313,17 +320,20 @@ func (c *Client) TestCheck(ctx context.Context, user *schema.User, checkInput ma
 	deadline.Scan(time.Now().Add(time.Minute))
 
 	node := response.Node.Nodes[0]
-	responseChan := make(chan *opsee.TestCheckResponse)
-	errChan := make(chan error)
+	// buffered so that the bastion call can finish after we've given up on it
+	responseChan := make(chan *opsee.TestCheckResponse, 1)
+	errChan := make(chan error, 1)
 
 	// going to set a timeout for our grpc context that's a bit bigger than the
 	// TestCheckRequest deadline
-	ctx, _ = context.WithTimeout(ctx, time.Minute)
+	requestCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
 
 	go func(node *etcd.Node) {
 		services := make(map[string]interface{})
 
-		err = json.Unmarshal([]byte(node.Value), &services)
+		err := json.Unmarshal([]byte(node.Value), &services)
 		if err != nil {
 			log.WithError(err).Errorf("error unmarshaling portmapper: %#v", node.Value)
 			errChan <- err
@@ -366,13 +376,54 @@ func (c *Client) TestCheck(ctx context.Context, user *schema.User, checkInput ma
 	case <-errChan:
 		// idk what to do with errors here
 	case <-ctx.Done():
-		// idk what to do with errors here
+		// the request was cancelled, say by compost shutting down, rather
+		// than the bastion timing out
+		if requestCtx.Err() != nil {
+			return nil, &Error{
+				Code:      ErrorUpstreamUnavailable,
+				Retryable: true,
+				Message:   fmt.Sprintf("test check cancelled: %s", requestCtx.Err()),
+			}
+		}
 	}
 
 	return &opsee.TestCheckResponse{Responses: responses}, nil
 }
 
 func (c *Client) CheckResults(ctx context.Context, user *schema.User, checkId string) (results []*schema.CheckResult, err error) {
+	resp, err := loaderFromContext(ctx).Load(checkResultsKey(user, checkId), func() (interface{}, error) {
+		return c.checkResults(ctx, user, checkId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.([]*schema.CheckResult), nil
+}
+
+// PrefetchCheckResults loads the results of each check concurrently into the
+// request's Loader, so that subsequent calls to CheckResults for the checks
+// don't wait on Cats. It does nothing if the context has no Loader.
+func (c *Client) PrefetchCheckResults(ctx context.Context, user *schema.User, checks []*schema.Check) {
+	requests := make([]LoaderRequest, len(checks))
+	for i, check := range checks {
+		checkId := check.Id
+		requests[i] = LoaderRequest{
+			Key: checkResultsKey(user, checkId),
+			Load: func() (interface{}, error) {
+				return c.checkResults(ctx, user, checkId)
+			},
+		}
+	}
+
+	loaderFromContext(ctx).LoadBatch(requests)
+}
+
+func checkResultsKey(user *schema.User, checkId string) string {
+	return loaderKeyFor("cats.GetCheckResults", user.CustomerId, "", checkId)
+}
+
+func (c *Client) checkResults(ctx context.Context, user *schema.User, checkId string) ([]*schema.CheckResult, error) {
 	resp, err := c.Cats.GetCheckResults(ctx, &opsee.GetCheckResultsRequest{
 		CustomerId: user.CustomerId,
 		CheckId:    checkId,
@@ -3,6 +3,9 @@ package resolver
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -19,6 +22,21 @@ import (
 
 type ClientConfig struct {
 	SkipVerify bool
+	// CAFile is an optional PEM file of CAs used to verify gRPC backends
+	// instead of the system roots.
+	CAFile string
+	// Timeouts bounds each call to a backend, keyed by backend name. A
+	// missing or zero timeout leaves calls bounded only by the request.
+	Timeouts map[string]time.Duration
+	// Cache configures the cache of Bezos describe calls.
+	Cache CacheConfig
+	// FanOut bounds the backend calls made in parallel for a single field.
+	FanOut FanOutConfig
+	// PollInterval is how often subscriptions poll cats for changes.
+	PollInterval time.Duration
+	// RateLimits bound the AWS calls made for each customer.
+	RateLimits RateLimitConfig
+
 	Bartnet    string
 	Beavis     string
 	Spanx      string
@@ -30,6 +48,30 @@ type ClientConfig struct {
 	Etcd       string
 }
 
+// DynamoClient is the part of the DynamoDB API a Client uses, which
+// *dynamodb.DynamoDB implements.
+type DynamoClient interface {
+	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+}
+
+// Backends is the set of service clients a Client resolves against. Each field
+// may be any implementation of the backend's interface, such as the in-memory
+// fakes in resolver/fake.
+type Backends struct {
+	Bartnet    bartnet.Client
+	Beavis     beavis.Client
+	Spanx      opsee.SpanxClient
+	Cats       opsee.CatsClient
+	Keelhaul   opsee.KeelhaulClient
+	Hugs       hugs.Client
+	Bezos      opsee.BezosClient
+	Marktricks opsee.MarktricksClient
+	Dynamo     DynamoClient
+	EtcdKeys   etcd.KeysAPI
+}
+
 type Client struct {
 	Bartnet    bartnet.Client
 	Beavis     beavis.Client
@@ -39,32 +81,64 @@ type Client struct {
 	Hugs       hugs.Client
 	Bezos      opsee.BezosClient
 	Marktricks opsee.MarktricksClient
-	Dynamo     *dynamodb.DynamoDB
+	Dynamo     DynamoClient
 	EtcdKeys   etcd.KeysAPI
+	// Cache holds Bezos describe responses, if UseCache has been called.
+	Cache *Cache
+	// RateLimiter limits each customer's AWS calls, if UseRateLimiter has
+	// been called.
+	RateLimiter *RateLimiter
+	// FanOut bounds the backend calls made in parallel for a single field.
+	FanOut FanOutConfig
+	// Events feeds subscriptions. It polls cats unless replaced.
+	Events EventSource
+	// Health checks the backends the client was dialed to, if it was built
+	// by NewClient.
+	Health *Health
+	// conns are the gRPC connections dialed by NewClient, closed by Close.
+	conns []*grpc.ClientConn
 }
 
 func NewClient(config ClientConfig) (*Client, error) {
-	spanxConn, err := grpcConn(config.Spanx, config.SkipVerify)
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.SkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+
+	health := NewHealth(DefaultHealthTimeout, tlsConfig)
+
+	spanxConn, err := grpcConn(health, BackendSpanx, config.Spanx, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	catsConn, err := grpcConn(config.Cats, config.SkipVerify)
+	catsConn, err := grpcConn(health, BackendCats, config.Cats, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	keelhaulConn, err := grpcConn(config.Keelhaul, config.SkipVerify)
+	keelhaulConn, err := grpcConn(health, BackendKeelhaul, config.Keelhaul, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	bezosConn, err := grpcConn(config.Bezos, config.SkipVerify)
+	bezosConn, err := grpcConn(health, BackendBezos, config.Bezos, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	marktricksConn, err := grpcConn(config.Marktricks, config.SkipVerify)
+	marktricksConn, err := grpcConn(health, BackendMarktricks, config.Marktricks, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -78,27 +152,85 @@ func NewClient(config ClientConfig) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
-		Bartnet:    bartnet.New(config.Bartnet),
-		Beavis:     beavis.New(config.Beavis),
+	health.AddHTTP(BackendBartnet, config.Bartnet)
+	health.AddHTTP(BackendBeavis, config.Beavis)
+	health.AddHTTP(BackendHugs, config.Hugs)
+	health.AddEtcd(BackendEtcd, config.Etcd, etcd.NewKeysAPI(etcdClient))
+
+	client := NewClientWithBackends(WrapBackends(Backends{
+		Bartnet:    newBartnetClient(config.Bartnet),
+		Beavis:     newBeavisClient(config.Beavis),
 		Spanx:      opsee.NewSpanxClient(spanxConn),
 		Cats:       opsee.NewCatsClient(catsConn),
 		Keelhaul:   opsee.NewKeelhaulClient(keelhaulConn),
-		Hugs:       hugs.New(config.Hugs),
+		Hugs:       newHugsClient(config.Hugs),
 		Bezos:      opsee.NewBezosClient(bezosConn),
 		Marktricks: opsee.NewMarktricksClient(marktricksConn),
 		Dynamo:     dynamodb.New(session.New(aws.NewConfig().WithRegion("us-west-2"))),
 		EtcdKeys:   etcd.NewKeysAPI(etcdClient),
-	}, nil
+	}, config.Timeouts))
+	client.UseRateLimiter(NewRateLimiter(config.RateLimits))
+	client.UseCache(NewCache(config.Cache))
+	client.FanOut = config.FanOut
+	client.Events = NewPollingEventSource(client.Cats, config.PollInterval)
+	client.Health = health
+	client.conns = []*grpc.ClientConn{spanxConn, catsConn, keelhaulConn, bezosConn, marktricksConn}
+
+	return client, nil
+}
+
+// Close closes the gRPC connections dialed by NewClient. Calls made through
+// them afterwards fail.
+func (c *Client) Close() error {
+	var firstErr error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NewClientWithBackends builds a Client from caller-supplied backends instead of
+// dialing them from a ClientConfig.
+func NewClientWithBackends(backends Backends) *Client {
+	return &Client{
+		Bartnet:    backends.Bartnet,
+		Beavis:     backends.Beavis,
+		Spanx:      backends.Spanx,
+		Cats:       backends.Cats,
+		Keelhaul:   backends.Keelhaul,
+		Hugs:       backends.Hugs,
+		Bezos:      backends.Bezos,
+		Marktricks: backends.Marktricks,
+		Dynamo:     backends.Dynamo,
+		EtcdKeys:   backends.EtcdKeys,
+		Events:     NewPollingEventSource(backends.Cats, 0),
+	}
+}
+
+// UseCache serves the client's Bezos describe calls from cache.
+func (c *Client) UseCache(cache *Cache) {
+	c.Cache = cache
+	c.Bezos = &cachingBezos{c.Bezos, cache}
+}
+
+// UseRateLimiter limits the client's AWS calls. Calls served by the cache
+// aren't limited, whichever of UseCache and UseRateLimiter is called first.
+func (c *Client) UseRateLimiter(limiter *RateLimiter) {
+	c.RateLimiter = limiter
+
+	if cached, ok := c.Bezos.(*cachingBezos); ok {
+		cached.BezosClient = &limitedBezos{cached.BezosClient, limiter}
+		return
+	}
+
+	c.Bezos = &limitedBezos{c.Bezos, limiter}
 }
 
-func grpcConn(addr string, skipVerify bool) (*grpc.ClientConn, error) {
+func grpcConn(health *Health, name, addr string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
 	return grpc.Dial(
 		addr,
-		grpc.WithTransportCredentials(
-			credentials.NewTLS(&tls.Config{
-				InsecureSkipVerify: skipVerify,
-			}),
-		),
+		health.DialOptions(name, addr, credentials.NewTLS(tlsConfig))...,
 	)
 }
@@ -0,0 +1,320 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/opsee/basic/schema"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee "github.com/opsee/basic/service"
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// the most items ECS accepts in a single describe call
+	ecsDescribeServicesLimit           = 10
+	ecsDescribeContainerInstancesLimit = 100
+)
+
+// ecsDiscovery crawls a customer's ECS clusters for the services running in a
+// VPC. Clusters are crawled concurrently, as are the DescribeServices batches
+// of each cluster. Every Bezos call of the crawl is made through one fanOut, so
+// the whole crawl makes at most FanOut.Concurrency calls at once, each given
+// FanOut.Timeout.
+type ecsDiscovery struct {
+	client *Client
+	fan    *fanOut
+	user   *schema.User
+	region string
+	vpc    string
+	logger *log.Entry
+}
+
+func (c *Client) discoverEcsServices(ctx context.Context, fan *fanOut, user *schema.User, region, vpc string) ([]*opsee_aws_ecs.Service, error) {
+	d := &ecsDiscovery{
+		client: c,
+		fan:    fan,
+		user:   user,
+		region: region,
+		vpc:    vpc,
+		logger: log.WithFields(log.Fields{
+			"customer_id": user.CustomerId,
+			"region":      region,
+			"vpc":         vpc,
+		}),
+	}
+
+	clusterArns, err := d.listClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clusterArns) == 0 {
+		d.logger.Info("no clusters found")
+		return nil, nil
+	}
+
+	return d.each(ctx, len(clusterArns), func(ctx context.Context, i int) ([]*opsee_aws_ecs.Service, error) {
+		svcs, err := d.clusterServices(ctx, clusterArns[i])
+		if err != nil {
+			return nil, wrapError(err, "cluster %s", clusterArns[i])
+		}
+
+		return svcs, nil
+	})
+}
+
+// each calls fn for 0 through n-1 concurrently and returns their services in
+// order, or the first error.
+func (d *ecsDiscovery) each(ctx context.Context, n int, fn func(context.Context, int) ([]*opsee_aws_ecs.Service, error)) ([]*opsee_aws_ecs.Service, error) {
+	tasks := make([]fanOutTask, n)
+	for i := range tasks {
+		i := i
+		tasks[i] = func(ctx context.Context) (interface{}, error) {
+			return fn(ctx, i)
+		}
+	}
+
+	var svcs []*opsee_aws_ecs.Service
+	for _, result := range runTasks(ctx, tasks) {
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		svcs = append(svcs, result.response.([]*opsee_aws_ecs.Service)...)
+	}
+
+	return svcs, nil
+}
+
+func (d *ecsDiscovery) get(ctx context.Context, req *opsee.BezosRequest) (*opsee.BezosResponse, error) {
+	req.User = d.user
+	req.Region = d.region
+	req.VpcId = d.vpc
+
+	result := d.fan.call(ctx, func(ctx context.Context) (interface{}, error) {
+		return d.client.Bezos.Get(ctx, req)
+	})
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	return result.response.(*opsee.BezosResponse), nil
+}
+
+func (d *ecsDiscovery) listClusters(ctx context.Context) ([]string, error) {
+	var (
+		arns      []string
+		nextToken *string
+	)
+
+	for {
+		resp, err := d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ecs_ListClustersInput{
+			&opsee_aws_ecs.ListClustersInput{NextToken: nextToken},
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		output := resp.GetEcs_ListClustersOutput()
+		if output == nil {
+			return nil, fmt.Errorf("error decoding aws response")
+		}
+
+		arns = append(arns, output.ClusterArns...)
+
+		if nextToken = output.NextToken; nextToken == nil {
+			return arns, nil
+		}
+	}
+}
+
+// clusterServices returns the cluster's services, or none if the cluster has
+// no container instances in the VPC.
+func (d *ecsDiscovery) clusterServices(ctx context.Context, clusterArn string) ([]*opsee_aws_ecs.Service, error) {
+	inVpc, err := d.clusterInVpc(ctx, clusterArn)
+	if err != nil {
+		return nil, err
+	}
+
+	if !inVpc {
+		return nil, nil
+	}
+
+	serviceArns, err := d.listServices(ctx, clusterArn)
+	if err != nil {
+		return nil, err
+	}
+
+	batches := batchStrings(serviceArns, ecsDescribeServicesLimit)
+	svcs, err := d.each(ctx, len(batches), func(ctx context.Context, i int) ([]*opsee_aws_ecs.Service, error) {
+		return d.describeServices(ctx, clusterArn, batches[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(svcs) == 0 {
+		d.logger.WithField("cluster", clusterArn).Info("no services found")
+	}
+
+	return svcs, nil
+}
+
+// clusterInVpc reports whether any of the cluster's container instances runs
+// on an EC2 instance in the VPC. It pages through the container instances,
+// stopping at the first page that has one.
+func (d *ecsDiscovery) clusterInVpc(ctx context.Context, clusterArn string) (bool, error) {
+	var nextToken *string
+
+	for {
+		resp, err := d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ecs_ListContainerInstancesInput{
+			&opsee_aws_ecs.ListContainerInstancesInput{
+				Cluster:    aws.String(clusterArn),
+				NextToken:  nextToken,
+				MaxResults: aws.Int64(ecsDescribeContainerInstancesLimit),
+			},
+		}})
+		if err != nil {
+			return false, err
+		}
+
+		output := resp.GetEcs_ListContainerInstancesOutput()
+		if output == nil {
+			return false, fmt.Errorf("error decoding aws response")
+		}
+
+		for _, batch := range batchStrings(output.ContainerInstanceArns, ecsDescribeContainerInstancesLimit) {
+			inVpc, err := d.containerInstancesInVpc(ctx, clusterArn, batch)
+			if err != nil || inVpc {
+				return inVpc, err
+			}
+		}
+
+		if nextToken = output.NextToken; nextToken == nil {
+			return false, nil
+		}
+	}
+}
+
+func (d *ecsDiscovery) containerInstancesInVpc(ctx context.Context, clusterArn string, containerInstanceArns []string) (bool, error) {
+	resp, err := d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ecs_DescribeContainerInstancesInput{
+		&opsee_aws_ecs.DescribeContainerInstancesInput{
+			Cluster:            aws.String(clusterArn),
+			ContainerInstances: containerInstanceArns,
+		},
+	}})
+	if err != nil {
+		return false, err
+	}
+
+	output := resp.GetEcs_DescribeContainerInstancesOutput()
+	if output == nil {
+		return false, fmt.Errorf("error decoding aws response")
+	}
+
+	var instanceIds []string
+	for _, ci := range output.ContainerInstances {
+		if ci.Ec2InstanceId != nil {
+			instanceIds = append(instanceIds, aws.StringValue(ci.Ec2InstanceId))
+		}
+	}
+
+	if len(instanceIds) == 0 {
+		return false, nil
+	}
+
+	resp, err = d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ec2_DescribeInstancesInput{
+		&opsee_aws_ec2.DescribeInstancesInput{
+			InstanceIds: instanceIds,
+			Filters: []*opsee_aws_ec2.Filter{
+				{
+					Name:   aws.String("vpc-id"),
+					Values: []string{d.vpc},
+				},
+			},
+		},
+	}})
+	if err != nil {
+		return false, err
+	}
+
+	instancesOutput := resp.GetEc2_DescribeInstancesOutput()
+	if instancesOutput == nil {
+		return false, fmt.Errorf("error decoding aws response")
+	}
+
+	for _, res := range instancesOutput.Reservations {
+		if len(res.Instances) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (d *ecsDiscovery) listServices(ctx context.Context, clusterArn string) ([]string, error) {
+	var (
+		arns      []string
+		nextToken *string
+	)
+
+	for {
+		resp, err := d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ecs_ListServicesInput{
+			&opsee_aws_ecs.ListServicesInput{
+				Cluster:   aws.String(clusterArn),
+				NextToken: nextToken,
+			},
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		output := resp.GetEcs_ListServicesOutput()
+		if output == nil {
+			return nil, fmt.Errorf("error decoding aws response")
+		}
+
+		arns = append(arns, output.ServiceArns...)
+
+		if nextToken = output.NextToken; nextToken == nil {
+			return arns, nil
+		}
+	}
+}
+
+func (d *ecsDiscovery) describeServices(ctx context.Context, clusterArn string, serviceArns []string) ([]*opsee_aws_ecs.Service, error) {
+	resp, err := d.get(ctx, &opsee.BezosRequest{Input: &opsee.BezosRequest_Ecs_DescribeServicesInput{
+		&opsee_aws_ecs.DescribeServicesInput{
+			Cluster:  aws.String(clusterArn),
+			Services: serviceArns,
+		},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	output := resp.GetEcs_DescribeServicesOutput()
+	if output == nil {
+		return nil, fmt.Errorf("error decoding aws response")
+	}
+
+	return output.Services, nil
+}
+
+// batchStrings splits items into batches of at most size items.
+func batchStrings(items []string, size int) [][]string {
+	var batches [][]string
+	for len(items) > size {
+		batches = append(batches, items[:size])
+		items = items[size:]
+	}
+
+	if len(items) > 0 {
+		batches = append(batches, items)
+	}
+
+	return batches
+}
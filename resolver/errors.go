@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorCode classifies an Error so that clients can act on it without
+// matching its message.
+type ErrorCode string
+
+const (
+	ErrorUnauthenticated     ErrorCode = "UNAUTHENTICATED"
+	ErrorForbidden           ErrorCode = "FORBIDDEN"
+	ErrorNotFound            ErrorCode = "NOT_FOUND"
+	ErrorInvalidInput        ErrorCode = "INVALID_INPUT"
+	ErrorUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	ErrorInternal            ErrorCode = "INTERNAL"
+	// ErrorPersistedQueryNotFound asks the client to send the query it
+	// sent the hash of again, in full.
+	ErrorPersistedQueryNotFound ErrorCode = "PERSISTED_QUERY_NOT_FOUND"
+	// ErrorRateLimited means the customer has made too many AWS calls, and
+	// may retry after the error's RetryAfter.
+	ErrorRateLimited ErrorCode = "RATE_LIMITED"
+)
+
+// Error is an error with a code. Backend names the backend that failed, if
+// one did, and Retryable reports whether the same request may succeed later,
+// no sooner than RetryAfter if it is set.
+type Error struct {
+	Code       ErrorCode
+	Backend    string
+	Retryable  bool
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError returns an error with code and message.
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Errorf returns an error with code and a formatted message.
+func Errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return NewError(code, fmt.Sprintf(format, args...))
+}
+
+// ErrorOf returns err as an *Error. Errors that don't carry a code are
+// INTERNAL.
+func ErrorOf(err error) *Error {
+	switch e := err.(type) {
+	case *Error:
+		return e
+	case *GroupTypeError:
+		typed := *ErrorOf(e.Err)
+		typed.Message = e.Error()
+		return &typed
+	}
+
+	return &Error{Code: ErrorInternal, Message: err.Error()}
+}
+
+// wrapError prefixes err's message, keeping its code.
+func wrapError(err error, format string, args ...interface{}) error {
+	typed := *ErrorOf(err)
+	typed.Message = fmt.Sprintf(format, args...) + ": " + typed.Message
+	return &typed
+}
+
+// backendError classifies an error returned by the named backend by its gRPC
+// status code. Backends that don't speak gRPC fail with INTERNAL errors,
+// unless they timed out.
+func backendError(backend string, err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	typed := &Error{Code: ErrorInternal, Backend: backend, Message: err.Error()}
+
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		typed.Code = ErrorUpstreamUnavailable
+		typed.Retryable = true
+		return typed
+	}
+
+	switch grpc.Code(err) {
+	case codes.Unauthenticated:
+		typed.Code = ErrorUnauthenticated
+	case codes.PermissionDenied:
+		typed.Code = ErrorForbidden
+	case codes.NotFound:
+		typed.Code = ErrorNotFound
+	case codes.InvalidArgument, codes.OutOfRange, codes.AlreadyExists, codes.FailedPrecondition:
+		typed.Code = ErrorInvalidInput
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Canceled:
+		typed.Code = ErrorUpstreamUnavailable
+		typed.Retryable = true
+	}
+
+	return typed
+}
@@ -0,0 +1,185 @@
+package resolver
+
+import (
+	"sort"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	log "github.com/opsee/logrus"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"golang.org/x/net/context"
+)
+
+// DefaultPollInterval is how often a PollingEventSource without an interval
+// polls cats.
+const DefaultPollInterval = 30 * time.Second
+
+// Event is sent to a subscriber each time what it subscribed to changes. An
+// event with Err is the last one sent.
+type Event struct {
+	Data interface{}
+	Err  error
+}
+
+// EventSource feeds subscriptions to changes in a customer's checks. Each
+// subscription sends events until ctx is done or it fails, then closes its
+// channel.
+type EventSource interface {
+	// CheckStateTransitions sends each of the check's state transitions, as
+	// a *schema.CheckStateTransition, once it occurs.
+	CheckStateTransitions(ctx context.Context, user *schema.User, checkId string) (<-chan Event, error)
+	// CheckResults sends the check's current results, as a
+	// []*schema.CheckResult, and then its results each time they change.
+	CheckResults(ctx context.Context, user *schema.User, checkId string) (<-chan Event, error)
+}
+
+// PollingEventSource is an EventSource that polls cats every Interval.
+type PollingEventSource struct {
+	Cats     opsee.CatsClient
+	Interval time.Duration
+}
+
+// NewPollingEventSource returns an EventSource polling cats every interval,
+// or every DefaultPollInterval if interval is zero.
+func NewPollingEventSource(cats opsee.CatsClient, interval time.Duration) *PollingEventSource {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &PollingEventSource{Cats: cats, Interval: interval}
+}
+
+// CheckStateTransitions polls for transitions since the last one sent,
+// starting from when the subscription was made.
+func (s *PollingEventSource) CheckStateTransitions(ctx context.Context, user *schema.User, checkId string) (<-chan Event, error) {
+	var (
+		since  = opsee_types.NewTimestamp(time.Now())
+		lastId int64
+	)
+
+	return s.poll(ctx, user, checkId, func(ctx context.Context) ([]interface{}, error) {
+		resp, err := s.Cats.GetCheckStateTransitions(ctx, &opsee.GetCheckStateTransitionsRequest{
+			CheckId:           checkId,
+			CustomerId:        user.CustomerId,
+			AbsoluteStartTime: since,
+			AbsoluteEndTime:   opsee_types.NewTimestamp(time.Now()),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		transitions := resp.Transitions
+		sort.Sort(byTransitionId(transitions))
+
+		var events []interface{}
+		for _, t := range transitions {
+			if t.Id <= lastId {
+				continue
+			}
+
+			events = append(events, t)
+			lastId = t.Id
+			if t.OccurredAt != nil {
+				since = t.OccurredAt
+			}
+		}
+
+		return events, nil
+	})
+}
+
+// CheckResults polls the check's results, sending them when any result has a
+// new timestamp.
+func (s *PollingEventSource) CheckResults(ctx context.Context, user *schema.User, checkId string) (<-chan Event, error) {
+	var last map[string]opsee_types.Timestamp
+
+	return s.poll(ctx, user, checkId, func(ctx context.Context) ([]interface{}, error) {
+		resp, err := s.Cats.GetCheckResults(ctx, &opsee.GetCheckResultsRequest{
+			CheckId:    checkId,
+			CustomerId: user.CustomerId,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		current := make(map[string]opsee_types.Timestamp, len(resp.Results))
+		for _, result := range resp.Results {
+			if result.Timestamp != nil {
+				current[result.BastionId] = *result.Timestamp
+			}
+		}
+
+		if last != nil && sameResults(last, current) {
+			return nil, nil
+		}
+
+		last = current
+		return []interface{}{resp.Results}, nil
+	})
+}
+
+// poll calls fetch immediately and then every interval, sending the events it
+// returns, until ctx is done or fetch fails.
+func (s *PollingEventSource) poll(ctx context.Context, user *schema.User, checkId string, fetch func(context.Context) ([]interface{}, error)) (<-chan Event, error) {
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		for {
+			data, err := fetch(ctx)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"customer_id": user.CustomerId,
+					"check_id":    checkId,
+				}).Error("error polling cats")
+
+				select {
+				case events <- Event{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			for _, d := range data {
+				select {
+				case events <- Event{Data: d}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func sameResults(a, b map[string]opsee_types.Timestamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+type byTransitionId []*schema.CheckStateTransition
+
+func (t byTransitionId) Len() int           { return len(t) }
+func (t byTransitionId) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byTransitionId) Less(i, j int) bool { return t[i].Id < t[j].Id }
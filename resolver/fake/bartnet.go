@@ -0,0 +1,104 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+)
+
+// Bartnet stores checks per customer.
+type Bartnet struct {
+	mut    sync.Mutex
+	checks map[string]map[string]*schema.Check
+	ids    idSequence
+}
+
+func NewBartnet() *Bartnet {
+	return &Bartnet{
+		checks: make(map[string]map[string]*schema.Check),
+	}
+}
+
+// AddCheck stores a check for the given customer, assigning an id if needed.
+func (b *Bartnet) AddCheck(customerId string, check *schema.Check) *schema.Check {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if check.Id == "" {
+		check.Id = b.ids.id("check-")
+	}
+	check.CustomerId = customerId
+
+	if b.checks[customerId] == nil {
+		b.checks[customerId] = make(map[string]*schema.Check)
+	}
+	b.checks[customerId][check.Id] = check
+
+	return check
+}
+
+func (b *Bartnet) GetCheck(user *schema.User, id string) (*schema.Check, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	check, ok := b.checks[customerId(user)][id]
+	if !ok {
+		return nil, fmt.Errorf("check not found: %s", id)
+	}
+
+	return check, nil
+}
+
+func (b *Bartnet) ListChecks(user *schema.User) ([]*schema.Check, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	var (
+		checks   = b.checks[customerId(user)]
+		checkIds = make([]string, 0, len(checks))
+		result   = make([]*schema.Check, 0, len(checks))
+	)
+
+	for id := range checks {
+		checkIds = append(checkIds, id)
+	}
+	sort.Strings(checkIds)
+
+	for _, id := range checkIds {
+		result = append(result, checks[id])
+	}
+
+	return result, nil
+}
+
+func (b *Bartnet) CreateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	check.Id = ""
+	return b.AddCheck(customerId(user), check), nil
+}
+
+func (b *Bartnet) UpdateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	if _, err := b.GetCheck(user, check.Id); err != nil {
+		return nil, err
+	}
+
+	return b.AddCheck(customerId(user), check), nil
+}
+
+func (b *Bartnet) DeleteCheck(user *schema.User, id string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if _, ok := b.checks[customerId(user)][id]; !ok {
+		return fmt.Errorf("check not found: %s", id)
+	}
+	delete(b.checks[customerId(user)], id)
+
+	return nil
+}
+
+func (b *Bartnet) TestCheck(user *schema.User, check *schema.Check) (*opsee.TestCheckResponse, error) {
+	return &opsee.TestCheckResponse{}, nil
+}
@@ -0,0 +1,52 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/opsee/basic/schema"
+)
+
+// Beavis stores check results per customer.
+type Beavis struct {
+	mut     sync.Mutex
+	results map[string][]*schema.CheckResult
+}
+
+func NewBeavis() *Beavis {
+	return &Beavis{
+		results: make(map[string][]*schema.CheckResult),
+	}
+}
+
+// AddResult stores a check result for the result's customer.
+func (b *Beavis) AddResult(result *schema.CheckResult) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.results[result.CustomerId] = append(b.results[result.CustomerId], result)
+}
+
+func (b *Beavis) ListResults(user *schema.User) ([]*schema.CheckResult, error) {
+	return b.filter(user, func(r *schema.CheckResult) bool { return true }), nil
+}
+
+func (b *Beavis) ListResultsCheck(user *schema.User, checkId string) ([]*schema.CheckResult, error) {
+	return b.filter(user, func(r *schema.CheckResult) bool { return r.CheckId == checkId }), nil
+}
+
+func (b *Beavis) ListResultsTarget(user *schema.User, targetId string) ([]*schema.CheckResult, error) {
+	return b.filter(user, func(r *schema.CheckResult) bool { return r.Target != nil && r.Target.Id == targetId }), nil
+}
+
+func (b *Beavis) filter(user *schema.User, match func(*schema.CheckResult) bool) []*schema.CheckResult {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	var results []*schema.CheckResult
+	for _, r := range b.results[customerId(user)] {
+		if match(r) {
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,636 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
+	opsee_aws_rds "github.com/opsee/basic/schema/aws/rds"
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Bezos stores AWS resources, cloudwatch datapoints and alarms per customer
+// and region, and answers describe requests from them.
+type Bezos struct {
+	mut     sync.Mutex
+	regions map[string]*BezosRegion
+
+	// PageSize limits the number of items returned by paginated ECS list
+	// calls. Zero disables paging.
+	PageSize int
+
+	// Fail, if set, is called with every request; a non-nil error is
+	// returned instead of a response.
+	Fail func(*opsee.BezosRequest) error
+
+	// Requests records every request received.
+	Requests []*opsee.BezosRequest
+}
+
+// BezosRegion holds the resources of one customer in one region.
+type BezosRegion struct {
+	Instances         []*opsee_aws_ec2.Instance                `json:"instances,omitempty"`
+	SecurityGroups    []*opsee_aws_ec2.SecurityGroup           `json:"security_groups,omitempty"`
+	LoadBalancers     []*opsee_aws_elb.LoadBalancerDescription `json:"load_balancers,omitempty"`
+	AutoScalingGroups []*opsee_aws_autoscaling.Group           `json:"autoscaling_groups,omitempty"`
+	DBInstances       []*opsee_aws_rds.DBInstance              `json:"db_instances,omitempty"`
+	Clusters          []*BezosCluster                          `json:"clusters,omitempty"`
+	TaskDefinitions   []*opsee_aws_ecs.TaskDefinition          `json:"task_definitions,omitempty"`
+	Alarms            []*opsee_aws_cloudwatch.MetricAlarm      `json:"alarms,omitempty"`
+	Metrics           []*BezosMetric                           `json:"metrics,omitempty"`
+}
+
+// BezosCluster is an ECS cluster with its container instances and services.
+type BezosCluster struct {
+	Arn                string                             `json:"arn"`
+	ContainerInstances []*opsee_aws_ecs.ContainerInstance `json:"container_instances,omitempty"`
+	Services           []*opsee_aws_ecs.Service           `json:"services,omitempty"`
+}
+
+// BezosMetric is a cloudwatch metric with its datapoints.
+type BezosMetric struct {
+	Namespace  string                            `json:"namespace"`
+	Name       string                            `json:"name"`
+	Dimensions []*opsee_aws_cloudwatch.Dimension `json:"dimensions,omitempty"`
+	Datapoints []*opsee_aws_cloudwatch.Datapoint `json:"datapoints,omitempty"`
+}
+
+func NewBezos() *Bezos {
+	return &Bezos{
+		regions: make(map[string]*BezosRegion),
+	}
+}
+
+// Region returns the resources for a customer's region, creating them if
+// necessary. Callers may append to the returned region to seed data.
+func (b *Bezos) Region(customerId, region string) *BezosRegion {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.region(customerId, region)
+}
+
+func (b *Bezos) region(customerId, region string) *BezosRegion {
+	key := customerId + "/" + region
+	r, ok := b.regions[key]
+	if !ok {
+		r = &BezosRegion{}
+		b.regions[key] = r
+	}
+	return r
+}
+
+// AddRegion appends the resources in add to a customer's region.
+func (b *Bezos) AddRegion(customerId, region string, add *BezosRegion) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	r := b.region(customerId, region)
+	r.Instances = append(r.Instances, add.Instances...)
+	r.SecurityGroups = append(r.SecurityGroups, add.SecurityGroups...)
+	r.LoadBalancers = append(r.LoadBalancers, add.LoadBalancers...)
+	r.AutoScalingGroups = append(r.AutoScalingGroups, add.AutoScalingGroups...)
+	r.DBInstances = append(r.DBInstances, add.DBInstances...)
+	r.Clusters = append(r.Clusters, add.Clusters...)
+	r.TaskDefinitions = append(r.TaskDefinitions, add.TaskDefinitions...)
+	r.Alarms = append(r.Alarms, add.Alarms...)
+	r.Metrics = append(r.Metrics, add.Metrics...)
+}
+
+// RequestCount returns the number of requests received so far.
+func (b *Bezos) RequestCount() int {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return len(b.Requests)
+}
+
+func (b *Bezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.Requests = append(b.Requests, in)
+
+	if b.Fail != nil {
+		if err := b.Fail(in); err != nil {
+			return nil, err
+		}
+	}
+
+	r := b.region(customerId(in.User), in.Region)
+
+	switch t := in.Input.(type) {
+	case *opsee.BezosRequest_Ec2_DescribeInstancesInput:
+		return b.describeInstances(r, t.Ec2_DescribeInstancesInput), nil
+	case *opsee.BezosRequest_Ec2_DescribeSecurityGroupsInput:
+		return b.describeSecurityGroups(r, t.Ec2_DescribeSecurityGroupsInput), nil
+	case *opsee.BezosRequest_Ec2_DescribeVpcsInput:
+		return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ec2_DescribeVpcsOutput{&opsee_aws_ec2.DescribeVpcsOutput{}}}, nil
+	case *opsee.BezosRequest_Ec2_DescribeSubnetsInput:
+		return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ec2_DescribeSubnetsOutput{&opsee_aws_ec2.DescribeSubnetsOutput{}}}, nil
+	case *opsee.BezosRequest_Ec2_DescribeRouteTablesInput:
+		return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ec2_DescribeRouteTablesOutput{&opsee_aws_ec2.DescribeRouteTablesOutput{}}}, nil
+	case *opsee.BezosRequest_Elb_DescribeLoadBalancersInput:
+		return b.describeLoadBalancers(r, t.Elb_DescribeLoadBalancersInput), nil
+	case *opsee.BezosRequest_Autoscaling_DescribeAutoScalingGroupsInput:
+		return b.describeAutoScalingGroups(r, t.Autoscaling_DescribeAutoScalingGroupsInput), nil
+	case *opsee.BezosRequest_Rds_DescribeDBInstancesInput:
+		return b.describeDBInstances(r, t.Rds_DescribeDBInstancesInput), nil
+	case *opsee.BezosRequest_Ecs_ListClustersInput:
+		return b.listClusters(r, t.Ecs_ListClustersInput)
+	case *opsee.BezosRequest_Ecs_ListContainerInstancesInput:
+		return b.listContainerInstances(r, t.Ecs_ListContainerInstancesInput)
+	case *opsee.BezosRequest_Ecs_DescribeContainerInstancesInput:
+		return b.describeContainerInstances(r, t.Ecs_DescribeContainerInstancesInput)
+	case *opsee.BezosRequest_Ecs_ListServicesInput:
+		return b.listServices(r, t.Ecs_ListServicesInput)
+	case *opsee.BezosRequest_Ecs_DescribeServicesInput:
+		return b.describeServices(r, t.Ecs_DescribeServicesInput)
+	case *opsee.BezosRequest_Ecs_DescribeTaskDefinitionInput:
+		return b.describeTaskDefinition(r, t.Ecs_DescribeTaskDefinitionInput)
+	case *opsee.BezosRequest_Ecs_ListTasksInput:
+		return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_ListTasksOutput{&opsee_aws_ecs.ListTasksOutput{}}}, nil
+	case *opsee.BezosRequest_Ecs_DescribeTasksInput:
+		return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_DescribeTasksOutput{&opsee_aws_ecs.DescribeTasksOutput{}}}, nil
+	case *opsee.BezosRequest_Cloudwatch_GetMetricStatisticsInput:
+		return b.getMetricStatistics(r, t.Cloudwatch_GetMetricStatisticsInput), nil
+	case *opsee.BezosRequest_Cloudwatch_ListMetricsInput:
+		return b.listMetrics(r, t.Cloudwatch_ListMetricsInput), nil
+	case *opsee.BezosRequest_Cloudwatch_DescribeAlarmsInput:
+		return b.describeAlarms(r, t.Cloudwatch_DescribeAlarmsInput), nil
+	case *opsee.BezosRequest_Cloudwatch_DescribeAlarmsForMetricInput:
+		return b.describeAlarmsForMetric(r, t.Cloudwatch_DescribeAlarmsForMetricInput), nil
+	}
+
+	return nil, fmt.Errorf("bezos input not supported: %T", in.Input)
+}
+
+func (b *Bezos) describeInstances(r *BezosRegion, input *opsee_aws_ec2.DescribeInstancesInput) *opsee.BezosResponse {
+	var instances []*opsee_aws_ec2.Instance
+	for _, inst := range r.Instances {
+		if len(input.InstanceIds) > 0 && !contains(input.InstanceIds, aws.StringValue(inst.InstanceId)) {
+			continue
+		}
+
+		if !matchInstanceFilters(inst, input.Filters) {
+			continue
+		}
+
+		instances = append(instances, inst)
+	}
+
+	output := &opsee_aws_ec2.DescribeInstancesOutput{}
+	if len(instances) > 0 {
+		output.Reservations = []*opsee_aws_ec2.Reservation{
+			{Instances: instances},
+		}
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ec2_DescribeInstancesOutput{output}}
+}
+
+func (b *Bezos) describeSecurityGroups(r *BezosRegion, input *opsee_aws_ec2.DescribeSecurityGroupsInput) *opsee.BezosResponse {
+	output := &opsee_aws_ec2.DescribeSecurityGroupsOutput{}
+	for _, sg := range r.SecurityGroups {
+		if len(input.GroupIds) > 0 && !contains(input.GroupIds, aws.StringValue(sg.GroupId)) {
+			continue
+		}
+
+		if len(input.GroupNames) > 0 && !contains(input.GroupNames, aws.StringValue(sg.GroupName)) {
+			continue
+		}
+
+		if !matchFilters(input.Filters, func(name string) []string {
+			switch name {
+			case "vpc-id":
+				return []string{aws.StringValue(sg.VpcId)}
+			case "group-id":
+				return []string{aws.StringValue(sg.GroupId)}
+			case "group-name":
+				return []string{aws.StringValue(sg.GroupName)}
+			}
+			return tagFilterValues(sg.Tags, name)
+		}) {
+			continue
+		}
+
+		output.SecurityGroups = append(output.SecurityGroups, sg)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ec2_DescribeSecurityGroupsOutput{output}}
+}
+
+func (b *Bezos) describeLoadBalancers(r *BezosRegion, input *opsee_aws_elb.DescribeLoadBalancersInput) *opsee.BezosResponse {
+	output := &opsee_aws_elb.DescribeLoadBalancersOutput{}
+	for _, lb := range r.LoadBalancers {
+		if len(input.LoadBalancerNames) > 0 && !contains(input.LoadBalancerNames, aws.StringValue(lb.LoadBalancerName)) {
+			continue
+		}
+
+		output.LoadBalancerDescriptions = append(output.LoadBalancerDescriptions, lb)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Elb_DescribeLoadBalancersOutput{output}}
+}
+
+func (b *Bezos) describeAutoScalingGroups(r *BezosRegion, input *opsee_aws_autoscaling.DescribeAutoScalingGroupsInput) *opsee.BezosResponse {
+	output := &opsee_aws_autoscaling.DescribeAutoScalingGroupsOutput{}
+	for _, g := range r.AutoScalingGroups {
+		if len(input.AutoScalingGroupNames) > 0 && !contains(input.AutoScalingGroupNames, aws.StringValue(g.AutoScalingGroupName)) {
+			continue
+		}
+
+		output.AutoScalingGroups = append(output.AutoScalingGroups, g)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Autoscaling_DescribeAutoScalingGroupsOutput{output}}
+}
+
+func (b *Bezos) describeDBInstances(r *BezosRegion, input *opsee_aws_rds.DescribeDBInstancesInput) *opsee.BezosResponse {
+	output := &opsee_aws_rds.DescribeDBInstancesOutput{}
+	for _, db := range r.DBInstances {
+		if input.DBInstanceIdentifier != nil && aws.StringValue(input.DBInstanceIdentifier) != aws.StringValue(db.DBInstanceIdentifier) {
+			continue
+		}
+
+		output.DBInstances = append(output.DBInstances, db)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Rds_DescribeDBInstancesOutput{output}}
+}
+
+func (b *Bezos) listClusters(r *BezosRegion, input *opsee_aws_ecs.ListClustersInput) (*opsee.BezosResponse, error) {
+	arns := make([]string, 0, len(r.Clusters))
+	for _, c := range r.Clusters {
+		arns = append(arns, c.Arn)
+	}
+
+	page, next, err := b.page(arns, input.NextToken, input.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_ListClustersOutput{&opsee_aws_ecs.ListClustersOutput{
+		ClusterArns: page,
+		NextToken:   next,
+	}}}, nil
+}
+
+func (b *Bezos) listContainerInstances(r *BezosRegion, input *opsee_aws_ecs.ListContainerInstancesInput) (*opsee.BezosResponse, error) {
+	cluster, err := r.cluster(aws.StringValue(input.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	arns := make([]string, 0, len(cluster.ContainerInstances))
+	for _, ci := range cluster.ContainerInstances {
+		arns = append(arns, aws.StringValue(ci.ContainerInstanceArn))
+	}
+
+	page, next, err := b.page(arns, input.NextToken, input.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_ListContainerInstancesOutput{&opsee_aws_ecs.ListContainerInstancesOutput{
+		ContainerInstanceArns: page,
+		NextToken:             next,
+	}}}, nil
+}
+
+func (b *Bezos) describeContainerInstances(r *BezosRegion, input *opsee_aws_ecs.DescribeContainerInstancesInput) (*opsee.BezosResponse, error) {
+	cluster, err := r.cluster(aws.StringValue(input.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &opsee_aws_ecs.DescribeContainerInstancesOutput{}
+	for _, ci := range cluster.ContainerInstances {
+		if contains(input.ContainerInstances, aws.StringValue(ci.ContainerInstanceArn)) {
+			output.ContainerInstances = append(output.ContainerInstances, ci)
+		}
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_DescribeContainerInstancesOutput{output}}, nil
+}
+
+func (b *Bezos) listServices(r *BezosRegion, input *opsee_aws_ecs.ListServicesInput) (*opsee.BezosResponse, error) {
+	cluster, err := r.cluster(aws.StringValue(input.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	arns := make([]string, 0, len(cluster.Services))
+	for _, s := range cluster.Services {
+		arns = append(arns, aws.StringValue(s.ServiceArn))
+	}
+
+	page, next, err := b.page(arns, input.NextToken, input.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_ListServicesOutput{&opsee_aws_ecs.ListServicesOutput{
+		ServiceArns: page,
+		NextToken:   next,
+	}}}, nil
+}
+
+// describeServices matches services by arn or name and, like ECS, rejects
+// requests for more than ten services.
+func (b *Bezos) describeServices(r *BezosRegion, input *opsee_aws_ecs.DescribeServicesInput) (*opsee.BezosResponse, error) {
+	if len(input.Services) > 10 {
+		return nil, fmt.Errorf("InvalidParameterException: services can have at most 10 items")
+	}
+
+	cluster, err := r.cluster(aws.StringValue(input.Cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &opsee_aws_ecs.DescribeServicesOutput{}
+	for _, s := range cluster.Services {
+		if contains(input.Services, aws.StringValue(s.ServiceArn)) || contains(input.Services, aws.StringValue(s.ServiceName)) {
+			output.Services = append(output.Services, s)
+		}
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_DescribeServicesOutput{output}}, nil
+}
+
+func (b *Bezos) describeTaskDefinition(r *BezosRegion, input *opsee_aws_ecs.DescribeTaskDefinitionInput) (*opsee.BezosResponse, error) {
+	id := aws.StringValue(input.TaskDefinition)
+	for _, td := range r.TaskDefinitions {
+		family := fmt.Sprintf("%s:%d", aws.StringValue(td.Family), aws.Int64Value(td.Revision))
+		if id == aws.StringValue(td.TaskDefinitionArn) || id == family || id == aws.StringValue(td.Family) {
+			return &opsee.BezosResponse{Output: &opsee.BezosResponse_Ecs_DescribeTaskDefinitionOutput{&opsee_aws_ecs.DescribeTaskDefinitionOutput{
+				TaskDefinition: td,
+			}}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("ClientException: Unable to describe task definition: %s", id)
+}
+
+// getMetricStatistics returns the datapoints of the first metric matching the
+// namespace, name and dimensions that fall within the requested time range.
+func (b *Bezos) getMetricStatistics(r *BezosRegion, input *opsee_aws_cloudwatch.GetMetricStatisticsInput) *opsee.BezosResponse {
+	output := &opsee_aws_cloudwatch.GetMetricStatisticsOutput{
+		Label: input.MetricName,
+	}
+
+	var start, end int64
+	if input.StartTime != nil {
+		start = input.StartTime.Millis()
+	}
+	if input.EndTime != nil {
+		end = input.EndTime.Millis()
+	}
+
+	for _, m := range r.Metrics {
+		if m.Namespace != aws.StringValue(input.Namespace) || m.Name != aws.StringValue(input.MetricName) {
+			continue
+		}
+
+		if !sameDimensions(m.Dimensions, input.Dimensions) {
+			continue
+		}
+
+		for _, dp := range m.Datapoints {
+			if dp.Timestamp != nil {
+				ms := dp.Timestamp.Millis()
+				if (start > 0 && ms < start) || (end > 0 && ms > end) {
+					continue
+				}
+			}
+
+			output.Datapoints = append(output.Datapoints, dp)
+		}
+
+		break
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Cloudwatch_GetMetricStatisticsOutput{output}}
+}
+
+func (b *Bezos) listMetrics(r *BezosRegion, input *opsee_aws_cloudwatch.ListMetricsInput) *opsee.BezosResponse {
+	output := &opsee_aws_cloudwatch.ListMetricsOutput{}
+	for _, m := range r.Metrics {
+		if input.Namespace != nil && m.Namespace != aws.StringValue(input.Namespace) {
+			continue
+		}
+
+		if input.MetricName != nil && m.Name != aws.StringValue(input.MetricName) {
+			continue
+		}
+
+		matched := true
+		for _, f := range input.Dimensions {
+			found := false
+			for _, d := range m.Dimensions {
+				if aws.StringValue(d.Name) == aws.StringValue(f.Name) && (f.Value == nil || aws.StringValue(d.Value) == aws.StringValue(f.Value)) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				matched = false
+				break
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		output.Metrics = append(output.Metrics, &opsee_aws_cloudwatch.Metric{
+			Namespace:  aws.String(m.Namespace),
+			MetricName: aws.String(m.Name),
+			Dimensions: m.Dimensions,
+		})
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Cloudwatch_ListMetricsOutput{output}}
+}
+
+func (b *Bezos) describeAlarms(r *BezosRegion, input *opsee_aws_cloudwatch.DescribeAlarmsInput) *opsee.BezosResponse {
+	output := &opsee_aws_cloudwatch.DescribeAlarmsOutput{}
+	for _, a := range r.Alarms {
+		name := aws.StringValue(a.AlarmName)
+		if len(input.AlarmNames) > 0 && !contains(input.AlarmNames, name) {
+			continue
+		}
+
+		if input.AlarmNamePrefix != nil && !strings.HasPrefix(name, aws.StringValue(input.AlarmNamePrefix)) {
+			continue
+		}
+
+		if input.StateValue != nil && aws.StringValue(a.StateValue) != aws.StringValue(input.StateValue) {
+			continue
+		}
+
+		output.MetricAlarms = append(output.MetricAlarms, a)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Cloudwatch_DescribeAlarmsOutput{output}}
+}
+
+func (b *Bezos) describeAlarmsForMetric(r *BezosRegion, input *opsee_aws_cloudwatch.DescribeAlarmsForMetricInput) *opsee.BezosResponse {
+	output := &opsee_aws_cloudwatch.DescribeAlarmsForMetricOutput{}
+	for _, a := range r.Alarms {
+		if aws.StringValue(a.Namespace) != aws.StringValue(input.Namespace) || aws.StringValue(a.MetricName) != aws.StringValue(input.MetricName) {
+			continue
+		}
+
+		if len(input.Dimensions) > 0 && !sameDimensions(a.Dimensions, input.Dimensions) {
+			continue
+		}
+
+		output.MetricAlarms = append(output.MetricAlarms, a)
+	}
+
+	return &opsee.BezosResponse{Output: &opsee.BezosResponse_Cloudwatch_DescribeAlarmsForMetricOutput{output}}
+}
+
+// page returns one page of items starting at the offset encoded in token,
+// along with the token for the next page.
+func (b *Bezos) page(items []string, token *string, maxResults *int64) ([]string, *string, error) {
+	var (
+		start int
+		size  = b.PageSize
+		err   error
+	)
+
+	if token != nil {
+		start, err = strconv.Atoi(aws.StringValue(token))
+		if err != nil || start < 0 || start > len(items) {
+			return nil, nil, fmt.Errorf("InvalidParameterException: invalid next token")
+		}
+	}
+
+	if maxResults != nil && (size == 0 || int(*maxResults) < size) {
+		size = int(*maxResults)
+	}
+
+	if size <= 0 || start+size >= len(items) {
+		return items[start:], nil, nil
+	}
+
+	return items[start : start+size], aws.String(strconv.Itoa(start + size)), nil
+}
+
+func (r *BezosRegion) cluster(arn string) (*BezosCluster, error) {
+	for _, c := range r.Clusters {
+		if c.Arn == arn || strings.HasSuffix(c.Arn, "/"+arn) {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("ClusterNotFoundException: Cluster not found: %s", arn)
+}
+
+// matchInstanceFilters supports the subset of DescribeInstances filters that
+// compost uses.
+func matchInstanceFilters(inst *opsee_aws_ec2.Instance, filters []*opsee_aws_ec2.Filter) bool {
+	return matchFilters(filters, func(name string) []string {
+		switch {
+		case name == "vpc-id":
+			return []string{aws.StringValue(inst.VpcId)}
+		case name == "instance-id":
+			return []string{aws.StringValue(inst.InstanceId)}
+		case name == "instance-type":
+			return []string{aws.StringValue(inst.InstanceType)}
+		case name == "instance-state-name":
+			if inst.State != nil {
+				return []string{aws.StringValue(inst.State.Name)}
+			}
+		case name == "availability-zone":
+			if inst.Placement != nil {
+				return []string{aws.StringValue(inst.Placement.AvailabilityZone)}
+			}
+		}
+		return tagFilterValues(inst.Tags, name)
+	})
+}
+
+// tagFilterValues returns the values of the tag-key, tag-value and tag:<key>
+// filters for a resource's tags.
+func tagFilterValues(tags []*opsee_aws_ec2.Tag, name string) []string {
+	var values []string
+	for _, t := range tags {
+		switch {
+		case name == "tag-key":
+			values = append(values, aws.StringValue(t.Key))
+		case name == "tag-value":
+			values = append(values, aws.StringValue(t.Value))
+		case name == "tag:"+aws.StringValue(t.Key):
+			values = append(values, aws.StringValue(t.Value))
+		}
+	}
+	return values
+}
+
+// matchFilters reports whether, for every filter, one of the values returned
+// by attr for the filter's name is among the filter's values. Filter values
+// may use the * wildcard as a suffix.
+func matchFilters(filters []*opsee_aws_ec2.Filter, attr func(string) []string) bool {
+	for _, f := range filters {
+		var (
+			values  = attr(aws.StringValue(f.Name))
+			matched bool
+		)
+
+		for _, v := range values {
+			for _, want := range f.Values {
+				if v == want || (strings.HasSuffix(want, "*") && strings.HasPrefix(v, strings.TrimSuffix(want, "*"))) {
+					matched = true
+				}
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameDimensions(a, b []*opsee_aws_cloudwatch.Dimension) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, da := range a {
+		found := false
+		for _, db := range b {
+			if aws.StringValue(da.Name) == aws.StringValue(db.Name) && aws.StringValue(da.Value) == aws.StringValue(db.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
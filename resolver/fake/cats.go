@@ -0,0 +1,387 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Cats stores users, teams, check results, state transitions and check
+// snapshots.
+type Cats struct {
+	mut         sync.Mutex
+	users       map[int32]*schema.User
+	invites     []*schema.Invite
+	teams       map[string]*schema.Team
+	checks      map[string][]*schema.Check
+	results     map[string][]*schema.CheckResult
+	transitions map[string][]*schema.CheckStateTransition
+	snapshots   map[int64]*schema.Check
+}
+
+func NewCats() *Cats {
+	return &Cats{
+		users:       make(map[int32]*schema.User),
+		teams:       make(map[string]*schema.Team),
+		checks:      make(map[string][]*schema.Check),
+		results:     make(map[string][]*schema.CheckResult),
+		transitions: make(map[string][]*schema.CheckStateTransition),
+		snapshots:   make(map[int64]*schema.Check),
+	}
+}
+
+// AddUser stores a user, replacing any user with the same id.
+func (c *Cats) AddUser(user *schema.User) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.users[user.Id] = user
+}
+
+// AddTeam stores a team, replacing any team with the same id.
+func (c *Cats) AddTeam(team *schema.Team) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.teams[team.Id] = team
+}
+
+// AddCheck stores a check as cats sees it, for GetChecks and GetCheckCount.
+func (c *Cats) AddCheck(check *schema.Check) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.checks[check.CustomerId] = append(c.checks[check.CustomerId], check)
+}
+
+// AddResult stores a check result.
+func (c *Cats) AddResult(result *schema.CheckResult) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.results[result.CheckId] = append(c.results[result.CheckId], result)
+}
+
+// AddTransition stores a check state transition, assigning an id if needed.
+func (c *Cats) AddTransition(transition *schema.CheckStateTransition) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if transition.Id == 0 {
+		var n int
+		for _, t := range c.transitions {
+			n += len(t)
+		}
+		transition.Id = int64(n + 1)
+	}
+	c.transitions[transition.CheckId] = append(c.transitions[transition.CheckId], transition)
+}
+
+// AddSnapshot stores the state of a check at a state transition.
+func (c *Cats) AddSnapshot(transitionId int64, check *schema.Check) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.snapshots[transitionId] = check
+}
+
+func (c *Cats) GetCheckCount(ctx context.Context, in *opsee.GetCheckCountRequest, opts ...grpc.CallOption) (*opsee.GetCheckCountResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return &opsee.GetCheckCountResponse{Count: int32(len(c.checks[customerId(in.User)]))}, nil
+}
+
+func (c *Cats) GetUser(ctx context.Context, in *opsee.GetUserRequest, opts ...grpc.CallOption) (*opsee.GetUserResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, id := range c.userIds() {
+		u := c.users[id]
+		switch {
+		case in.Id != 0 && u.Id == in.Id,
+			in.Email != "" && u.Email == in.Email,
+			in.Id == 0 && in.Email == "" && in.CustomerId != "" && u.CustomerId == in.CustomerId:
+			return &opsee.GetUserResponse{User: u}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user not found")
+}
+
+func (c *Cats) UpdateUser(ctx context.Context, in *opsee.UpdateUserRequest, opts ...grpc.CallOption) (*opsee.UserTokenResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.User == nil {
+		return nil, fmt.Errorf("missing user")
+	}
+
+	u, ok := c.users[in.User.Id]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %d", in.User.Id)
+	}
+
+	if in.Email != "" {
+		u.Email = in.Email
+	}
+	if in.Name != "" {
+		u.Name = in.Name
+	}
+	if in.Status != "" {
+		u.Status = in.Status
+	}
+	if in.Perms != nil {
+		u.Perms = in.Perms
+	}
+	if in.Password != "" {
+		u.HasPassword = true
+	}
+
+	return &opsee.UserTokenResponse{User: u}, nil
+}
+
+func (c *Cats) ListUsers(ctx context.Context, in *opsee.ListUsersRequest, opts ...grpc.CallOption) (*opsee.ListUsersResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		ids     = c.userIds()
+		page    = int(in.Page)
+		perPage = int(in.PerPage)
+		users   []*schema.User
+	)
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = len(ids)
+	}
+
+	for i := (page - 1) * perPage; i < len(ids) && i < page*perPage; i++ {
+		users = append(users, c.users[ids[i]])
+	}
+
+	return &opsee.ListUsersResponse{
+		Users:   users,
+		Page:    int32(page),
+		PerPage: int32(perPage),
+		Total:   int32(len(ids)),
+	}, nil
+}
+
+func (c *Cats) InviteUser(ctx context.Context, in *opsee.InviteUserRequest, opts ...grpc.CallOption) (*opsee.InviteUserResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	invite := &schema.Invite{
+		Id:         int32(len(c.invites) + 1),
+		Email:      in.Email,
+		Name:       in.Name,
+		CustomerId: customerId(in.Requestor),
+		Perms:      in.Perms,
+	}
+	c.invites = append(c.invites, invite)
+
+	return &opsee.InviteUserResponse{Invite: invite}, nil
+}
+
+func (c *Cats) DeleteUser(ctx context.Context, in *opsee.DeleteUserRequest, opts ...grpc.CallOption) (*opsee.DeleteUserResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.User == nil {
+		return nil, fmt.Errorf("missing user")
+	}
+
+	u, ok := c.users[in.User.Id]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %d", in.User.Id)
+	}
+	delete(c.users, in.User.Id)
+
+	return &opsee.DeleteUserResponse{User: u}, nil
+}
+
+// GetTeam returns the team along with every user belonging to it.
+func (c *Cats) GetTeam(ctx context.Context, in *opsee.GetTeamRequest, opts ...grpc.CallOption) (*opsee.GetTeamResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.Team == nil {
+		return nil, fmt.Errorf("missing team")
+	}
+
+	team, ok := c.teams[in.Team.Id]
+	if !ok {
+		return nil, fmt.Errorf("team not found: %s", in.Team.Id)
+	}
+
+	// copy the team so callers may modify the response without touching the store
+	resp := *team
+	resp.Users = nil
+	for _, id := range c.userIds() {
+		if c.users[id].CustomerId == team.Id {
+			resp.Users = append(resp.Users, c.users[id])
+		}
+	}
+
+	return &opsee.GetTeamResponse{Team: &resp}, nil
+}
+
+func (c *Cats) CreateTeam(ctx context.Context, in *opsee.CreateTeamRequest, opts ...grpc.CallOption) (*opsee.CreateTeamResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.Team == nil {
+		return nil, fmt.Errorf("missing team")
+	}
+
+	if _, ok := c.teams[in.Team.Id]; ok {
+		return nil, fmt.Errorf("team exists: %s", in.Team.Id)
+	}
+	c.teams[in.Team.Id] = in.Team
+
+	return &opsee.CreateTeamResponse{Team: in.Team}, nil
+}
+
+func (c *Cats) UpdateTeam(ctx context.Context, in *opsee.UpdateTeamRequest, opts ...grpc.CallOption) (*opsee.UpdateTeamResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.Team == nil {
+		return nil, fmt.Errorf("missing team")
+	}
+
+	team, ok := c.teams[in.Team.Id]
+	if !ok {
+		return nil, fmt.Errorf("team not found: %s", in.Team.Id)
+	}
+
+	if in.Team.Name != "" {
+		team.Name = in.Team.Name
+	}
+	if in.Team.SubscriptionPlan != "" {
+		team.SubscriptionPlan = in.Team.SubscriptionPlan
+	}
+
+	return &opsee.UpdateTeamResponse{Team: team}, nil
+}
+
+func (c *Cats) DeleteTeam(ctx context.Context, in *opsee.DeleteTeamRequest, opts ...grpc.CallOption) (*opsee.DeleteTeamResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if in.Team == nil {
+		return nil, fmt.Errorf("missing team")
+	}
+
+	team, ok := c.teams[in.Team.Id]
+	if !ok {
+		return nil, fmt.Errorf("team not found: %s", in.Team.Id)
+	}
+	delete(c.teams, in.Team.Id)
+
+	return &opsee.DeleteTeamResponse{Team: team}, nil
+}
+
+func (c *Cats) GetCheckResults(ctx context.Context, in *opsee.GetCheckResultsRequest, opts ...grpc.CallOption) (*opsee.GetCheckResultsResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var results []*schema.CheckResult
+	for _, r := range c.results[in.CheckId] {
+		if r.CustomerId == in.CustomerId {
+			results = append(results, r)
+		}
+	}
+
+	return &opsee.GetCheckResultsResponse{Results: results}, nil
+}
+
+// GetCheckStateTransitions returns a single transition when StateTransitionId
+// is set, otherwise every transition within the absolute time range. Zero
+// start or end times leave that end of the range open.
+func (c *Cats) GetCheckStateTransitions(ctx context.Context, in *opsee.GetCheckStateTransitionsRequest, opts ...grpc.CallOption) (*opsee.GetCheckStateTransitionsResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		start       int64
+		end         int64
+		transitions []*schema.CheckStateTransition
+	)
+
+	if in.AbsoluteStartTime != nil {
+		start = in.AbsoluteStartTime.Millis()
+	}
+	if in.AbsoluteEndTime != nil {
+		end = in.AbsoluteEndTime.Millis()
+	}
+
+	for _, t := range c.transitions[in.CheckId] {
+		if t.CustomerId != in.CustomerId {
+			continue
+		}
+
+		if in.StateTransitionId != 0 {
+			if t.Id == in.StateTransitionId {
+				transitions = append(transitions, t)
+			}
+			continue
+		}
+
+		if t.OccurredAt != nil {
+			ms := t.OccurredAt.Millis()
+			if (start > 0 && ms < start) || (end > 0 && ms > end) {
+				continue
+			}
+		}
+
+		transitions = append(transitions, t)
+	}
+
+	return &opsee.GetCheckStateTransitionsResponse{Transitions: transitions}, nil
+}
+
+func (c *Cats) GetChecks(ctx context.Context, in *opsee.GetChecksRequest, opts ...grpc.CallOption) (*opsee.GetChecksResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var checks []*schema.Check
+	for _, check := range c.checks[customerId(in.Requestor)] {
+		if in.CheckId == "" || check.Id == in.CheckId {
+			checks = append(checks, check)
+		}
+	}
+
+	return &opsee.GetChecksResponse{Checks: checks}, nil
+}
+
+func (c *Cats) GetCheckSnapshot(ctx context.Context, in *opsee.GetCheckSnapshotRequest, opts ...grpc.CallOption) (*opsee.GetCheckSnapshotResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	check, ok := c.snapshots[in.TransitionId]
+	if !ok || check.Id != in.CheckId {
+		return nil, fmt.Errorf("snapshot not found: %s/%d", in.CheckId, in.TransitionId)
+	}
+
+	return &opsee.GetCheckSnapshotResponse{Check: check}, nil
+}
+
+// userIds returns user ids in ascending order so that listings are stable.
+// The caller must hold c.mut.
+func (c *Cats) userIds() []int32 {
+	sorted := make([]int, 0, len(c.users))
+	for id := range c.users {
+		sorted = append(sorted, int(id))
+	}
+	sort.Ints(sorted)
+
+	ids := make([]int32, len(sorted))
+	for i, id := range sorted {
+		ids[i] = int32(id)
+	}
+	return ids
+}
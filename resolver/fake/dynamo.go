@@ -0,0 +1,119 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Dynamo is an in-memory set of DynamoDB tables implementing
+// resolver.DynamoClient. Tables must be created, with the names of their key
+// attributes, before items are put in them. Condition expressions are
+// ignored.
+type Dynamo struct {
+	mut    sync.Mutex
+	tables map[string]*dynamoTable
+}
+
+type dynamoTable struct {
+	keys  []string
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func NewDynamo() *Dynamo {
+	return &Dynamo{
+		tables: make(map[string]*dynamoTable),
+	}
+}
+
+// CreateTable creates an empty table whose items are keyed by the attributes
+// named keys, replacing any table of the same name.
+func (d *Dynamo) CreateTable(name string, keys ...string) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.tables[name] = &dynamoTable{
+		keys:  keys,
+		items: make(map[string]map[string]*dynamodb.AttributeValue),
+	}
+}
+
+func (d *Dynamo) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	table, key, err := d.itemKey(input.TableName, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.GetItemOutput{Item: table.items[key]}, nil
+}
+
+func (d *Dynamo) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	table, key, err := d.itemKey(input.TableName, input.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.PutItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = table.items[key]
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue, len(input.Item))
+	for name, value := range input.Item {
+		item[name] = value
+	}
+	table.items[key] = item
+
+	return output, nil
+}
+
+func (d *Dynamo) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	table, key, err := d.itemKey(input.TableName, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = table.items[key]
+	}
+	delete(table.items, key)
+
+	return output, nil
+}
+
+// itemKey returns the named table and the key of the item with attrs in it.
+func (d *Dynamo) itemKey(name *string, attrs map[string]*dynamodb.AttributeValue) (*dynamoTable, string, error) {
+	table, ok := d.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, "", awserr.New("ResourceNotFoundException", fmt.Sprintf("table %s not found", aws.StringValue(name)), nil)
+	}
+
+	keys := append([]string(nil), table.keys...)
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		value, ok := attrs[k]
+		if !ok {
+			return nil, "", awserr.New("ValidationException", fmt.Sprintf("missing key attribute %s", k), nil)
+		}
+		parts[i] = k + "=" + value.String()
+	}
+
+	return table, strings.Join(parts, "|"), nil
+}
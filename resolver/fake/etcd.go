@@ -0,0 +1,194 @@
+package fake
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// Etcd is a flat, in-memory key value store implementing etcd.KeysAPI.
+// Directories are implied by the keys beneath them.
+type Etcd struct {
+	mut   sync.Mutex
+	index uint64
+	keys  map[string]*etcd.Node
+}
+
+func NewEtcd() *Etcd {
+	return &Etcd{
+		keys: make(map[string]*etcd.Node),
+	}
+}
+
+func (e *Etcd) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	key = path.Clean("/" + key)
+	if node, ok := e.keys[key]; ok {
+		return &etcd.Response{Action: "get", Node: node, Index: e.index}, nil
+	}
+
+	recursive := opts != nil && opts.Recursive
+	node := e.dir(key, recursive)
+	if node == nil {
+		return nil, e.notFound(key)
+	}
+
+	if opts != nil && opts.Sort {
+		sort.Sort(node.Nodes)
+	}
+
+	return &etcd.Response{Action: "get", Node: node, Index: e.index}, nil
+}
+
+func (e *Etcd) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	return e.set(key, value, opts)
+}
+
+// set is Set with e.mut held.
+func (e *Etcd) set(key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	key = path.Clean("/" + key)
+	prev, exists := e.keys[key]
+
+	if opts != nil {
+		switch {
+		case opts.PrevExist == etcd.PrevExist && !exists:
+			return nil, e.notFound(key)
+		case opts.PrevExist == etcd.PrevNoExist && exists:
+			return nil, etcd.Error{Code: etcd.ErrorCodeNodeExist, Message: "Key already exists", Cause: key, Index: e.index}
+		case opts.PrevValue != "" && (!exists || prev.Value != opts.PrevValue):
+			return nil, etcd.Error{Code: etcd.ErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: e.index}
+		}
+	}
+
+	e.index++
+	node := &etcd.Node{
+		Key:           key,
+		Value:         value,
+		CreatedIndex:  e.index,
+		ModifiedIndex: e.index,
+	}
+	if exists {
+		node.CreatedIndex = prev.CreatedIndex
+	}
+	e.keys[key] = node
+
+	return &etcd.Response{Action: "set", Node: node, PrevNode: prev, Index: e.index}, nil
+}
+
+func (e *Etcd) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	key = path.Clean("/" + key)
+	prev, exists := e.keys[key]
+	if exists {
+		delete(e.keys, key)
+	} else {
+		if opts == nil || !opts.Recursive {
+			return nil, e.notFound(key)
+		}
+
+		var deleted bool
+		for k := range e.keys {
+			if strings.HasPrefix(k, key+"/") {
+				delete(e.keys, k)
+				deleted = true
+			}
+		}
+
+		if !deleted {
+			return nil, e.notFound(key)
+		}
+	}
+
+	e.index++
+	return &etcd.Response{Action: "delete", Node: &etcd.Node{Key: key, ModifiedIndex: e.index}, PrevNode: prev, Index: e.index}, nil
+}
+
+func (e *Etcd) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return e.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (e *Etcd) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	// the key is named for the index the write will have, so the lock is
+	// held through the write for concurrent calls to get different keys
+	return e.set(path.Join(dir, fmt.Sprintf("%020d", e.index+1)), value, nil)
+}
+
+func (e *Etcd) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return e.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+// Watcher returns a watcher that blocks until its context is done; the fake
+// does not deliver change events.
+func (e *Etcd) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	return &etcdWatcher{}
+}
+
+type etcdWatcher struct{}
+
+func (w *etcdWatcher) Next(ctx context.Context) (*etcd.Response, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// dir builds a directory node for key out of the keys beneath it, or returns
+// nil if there are none. The caller must hold e.mut.
+func (e *Etcd) dir(key string, recursive bool) *etcd.Node {
+	var (
+		prefix   = strings.TrimSuffix(key, "/") + "/"
+		children = make(map[string]bool)
+		node     = &etcd.Node{Key: key, Dir: true}
+	)
+
+	for k := range e.keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		child := prefix + strings.SplitN(strings.TrimPrefix(k, prefix), "/", 2)[0]
+		children[child] = true
+	}
+
+	if len(children) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(children))
+	for child := range children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+
+	for _, child := range names {
+		if n, ok := e.keys[child]; ok {
+			node.Nodes = append(node.Nodes, n)
+			continue
+		}
+
+		if recursive {
+			node.Nodes = append(node.Nodes, e.dir(child, true))
+		} else {
+			node.Nodes = append(node.Nodes, &etcd.Node{Key: child, Dir: true})
+		}
+	}
+
+	return node
+}
+
+func (e *Etcd) notFound(key string) error {
+	return etcd.Error{Code: etcd.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: e.index}
+}
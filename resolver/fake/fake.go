@@ -0,0 +1,83 @@
+// Package fake provides stateful, in-memory implementations of every backend
+// used by resolver.Client. It lets tests and local development run full
+// graphql queries without access to the opsee services.
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+)
+
+// Fake holds one in-memory implementation of each backend. The backends are
+// exported so that callers can seed them with data before running queries.
+type Fake struct {
+	Bartnet    *Bartnet
+	Beavis     *Beavis
+	Spanx      *Spanx
+	Cats       *Cats
+	Keelhaul   *Keelhaul
+	Hugs       *Hugs
+	Bezos      *Bezos
+	Marktricks *Marktricks
+	Dynamo     *Dynamo
+	Etcd       *Etcd
+}
+
+func New() *Fake {
+	return &Fake{
+		Bartnet:    NewBartnet(),
+		Beavis:     NewBeavis(),
+		Spanx:      NewSpanx(),
+		Cats:       NewCats(),
+		Keelhaul:   NewKeelhaul(),
+		Hugs:       NewHugs(),
+		Bezos:      NewBezos(),
+		Marktricks: NewMarktricks(),
+		Dynamo:     NewDynamo(),
+		Etcd:       NewEtcd(),
+	}
+}
+
+// Backends returns the fakes as a resolver.Backends.
+func (f *Fake) Backends() resolver.Backends {
+	return resolver.Backends{
+		Bartnet:    f.Bartnet,
+		Beavis:     f.Beavis,
+		Spanx:      f.Spanx,
+		Cats:       f.Cats,
+		Keelhaul:   f.Keelhaul,
+		Hugs:       f.Hugs,
+		Bezos:      f.Bezos,
+		Marktricks: f.Marktricks,
+		Dynamo:     f.Dynamo,
+		EtcdKeys:   f.Etcd,
+	}
+}
+
+// Client returns a resolver.Client backed by the fakes.
+func (f *Fake) Client() *resolver.Client {
+	return resolver.NewClientWithBackends(f.Backends())
+}
+
+// idSequence hands out unique, increasing ids for created objects.
+type idSequence struct {
+	mut  sync.Mutex
+	next int
+}
+
+func (s *idSequence) id(prefix string) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.next++
+	return fmt.Sprintf("%s%d", prefix, s.next)
+}
+
+func customerId(user *schema.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.CustomerId
+}
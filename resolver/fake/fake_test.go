@@ -0,0 +1,392 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/opsee/basic/schema"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestListChecks(t *testing.T) {
+	var (
+		f    = New()
+		user = &schema.User{
+			Id:         int32(7),
+			CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e",
+			Email:      "computer@markmart.in",
+			Active:     true,
+			Verified:   true,
+		}
+	)
+
+	check := f.Bartnet.AddCheck(user.CustomerId, &schema.Check{
+		Name:   "hello",
+		Target: &schema.Target{Id: "sg-123", Type: "security"},
+	})
+	f.Cats.AddResult(&schema.CheckResult{CheckId: check.Id, CustomerId: user.CustomerId, Passing: true})
+	f.Bartnet.AddCheck("someone-else", &schema.Check{Name: "goodbye"})
+
+	client := f.Client()
+	checks, err := client.ListChecks(context.Background(), user, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, checks, 1)
+	assert.Equal(t, "hello", checks[0].Name)
+
+	// results are loaded separately, for the checks that need them
+	assert.Nil(t, checks[0].Results)
+	results, err := client.CheckResults(context.Background(), user, checks[0].Id)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+// TestListChecksRateLimited lists 100 checks and fetches a metric of each
+// one's target, as a check listing does, under the default rate limits: the
+// calls over the burst wait their turn rather than fail.
+func TestListChecksRateLimited(t *testing.T) {
+	var (
+		f    = New()
+		user = &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+	)
+
+	for i := 0; i < 100; i++ {
+		f.Bartnet.AddCheck(user.CustomerId, &schema.Check{
+			Name:   fmt.Sprintf("check-%d", i),
+			Target: &schema.Target{Id: fmt.Sprintf("i-%d", i), Type: "instance"},
+		})
+	}
+
+	client := f.Client()
+	client.UseRateLimiter(resolver.NewRateLimiter(resolver.DefaultRateLimits))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = resolver.WithLoader(ctx, resolver.NewLoader())
+
+	checks, err := client.ListChecks(ctx, user, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, checks, 100)
+
+	inputs := make([]*opsee_aws_cloudwatch.GetMetricStatisticsInput, len(checks))
+	for i, check := range checks {
+		inputs[i] = &opsee_aws_cloudwatch.GetMetricStatisticsInput{
+			Namespace:  aws.String("AWS/EC2"),
+			MetricName: aws.String("CPUUtilization"),
+			Dimensions: []*opsee_aws_cloudwatch.Dimension{{Name: aws.String("InstanceId"), Value: aws.String(check.Target.Id)}},
+			Statistics: []string{resolver.StatisticAverage},
+		}
+	}
+
+	client.PrefetchMetricStatistics(ctx, user, "us-west-2", inputs)
+	for _, input := range inputs {
+		_, err := client.GetMetricStatistics(ctx, user, "us-west-2", input)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 100, f.Bezos.RequestCount())
+	assert.EqualValues(t, 0, client.RateLimiter.Usage(user.CustomerId)[0].Limited)
+}
+
+func TestGetGroupsPartial(t *testing.T) {
+	var (
+		f    = New()
+		user = &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+	)
+
+	f.Bezos.AddRegion(user.CustomerId, "us-west-2", &BezosRegion{
+		SecurityGroups: []*opsee_aws_ec2.SecurityGroup{{GroupId: aws.String("sg-123"), VpcId: aws.String("vpc-123")}},
+	})
+	f.Bezos.Fail = func(req *opsee.BezosRequest) error {
+		if req.GetElb_DescribeLoadBalancersInput() != nil {
+			return fmt.Errorf("throttled")
+		}
+		return nil
+	}
+
+	groups, err := f.Client().GetGroups(context.Background(), user, "us-west-2", "vpc-123", "", "", resolver.ResourceFilter{})
+	if assert.IsType(t, resolver.GroupsError{}, err) {
+		groupsErr := err.(resolver.GroupsError)
+		assert.Len(t, groupsErr, 1)
+		assert.Equal(t, "elb", groupsErr[0].GroupType)
+	}
+
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, "sg-123", aws.StringValue(groups.([]interface{})[0].(*opsee_aws_ec2.SecurityGroup).GroupId))
+	}
+}
+
+func TestGetGroupsUnknownType(t *testing.T) {
+	user := &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+
+	groups, err := New().Client().GetGroups(context.Background(), user, "us-west-2", "vpc-123", "bogus", "", resolver.ResourceFilter{})
+	assert.Nil(t, groups)
+	if assert.Error(t, err) {
+		assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(err).Code)
+	}
+}
+
+func TestGetGroupsEcsService(t *testing.T) {
+	var (
+		f    = New()
+		user = &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+	)
+
+	cluster := func(name, instanceId string, services int) *BezosCluster {
+		c := &BezosCluster{Arn: "arn:aws:ecs:us-west-2:1:cluster/" + name}
+		if instanceId != "" {
+			c.ContainerInstances = []*opsee_aws_ecs.ContainerInstance{{
+				ContainerInstanceArn: aws.String(c.Arn + "/ci"),
+				Ec2InstanceId:        aws.String(instanceId),
+			}}
+		}
+		for i := 0; i < services; i++ {
+			c.Services = append(c.Services, &opsee_aws_ecs.Service{
+				ServiceArn:  aws.String(fmt.Sprintf("arn:aws:ecs:us-west-2:1:service/%s-%d", name, i)),
+				ServiceName: aws.String(fmt.Sprintf("%s-%d", name, i)),
+			})
+		}
+		return c
+	}
+
+	f.Bezos.PageSize = 4
+	f.Bezos.AddRegion(user.CustomerId, "us-west-2", &BezosRegion{
+		Instances: []*opsee_aws_ec2.Instance{
+			{InstanceId: aws.String("i-in"), VpcId: aws.String("vpc-123")},
+			{InstanceId: aws.String("i-out"), VpcId: aws.String("vpc-456")},
+		},
+		Clusters: []*BezosCluster{
+			cluster("empty", "", 3),
+			cluster("big", "i-in", 25),
+			cluster("elsewhere", "i-out", 2),
+			cluster("small", "i-in", 1),
+		},
+	})
+
+	groups, err := f.Client().GetGroups(context.Background(), user, "us-west-2", "vpc-123", "ecs_service", "", resolver.ResourceFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services := groups.([]*opsee_aws_ecs.Service)
+	if assert.Len(t, services, 26) {
+		assert.Equal(t, "big-0", aws.StringValue(services[0].ServiceName))
+		assert.Equal(t, "small-0", aws.StringValue(services[25].ServiceName))
+	}
+}
+
+// concurrentBezos records the most calls it has had in flight at once.
+type concurrentBezos struct {
+	*Bezos
+	mut      sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (b *concurrentBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	b.mut.Lock()
+	b.inFlight++
+	if b.inFlight > b.max {
+		b.max = b.inFlight
+	}
+	b.mut.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	b.mut.Lock()
+	b.inFlight--
+	b.mut.Unlock()
+
+	return b.Bezos.Get(ctx, in, opts...)
+}
+
+func TestGetGroupsEcsServiceConcurrency(t *testing.T) {
+	var (
+		f       = New()
+		user    = &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+		region  = &BezosRegion{Instances: []*opsee_aws_ec2.Instance{{InstanceId: aws.String("i-in"), VpcId: aws.String("vpc-123")}}}
+		bezos   = &concurrentBezos{Bezos: f.Bezos}
+		backend = f.Backends()
+	)
+
+	for c := 0; c < 4; c++ {
+		cluster := &BezosCluster{
+			Arn: fmt.Sprintf("arn:aws:ecs:us-west-2:1:cluster/c%d", c),
+			ContainerInstances: []*opsee_aws_ecs.ContainerInstance{{
+				ContainerInstanceArn: aws.String(fmt.Sprintf("arn:aws:ecs:us-west-2:1:container-instance/c%d", c)),
+				Ec2InstanceId:        aws.String("i-in"),
+			}},
+		}
+		for i := 0; i < 40; i++ {
+			cluster.Services = append(cluster.Services, &opsee_aws_ecs.Service{
+				ServiceArn:  aws.String(fmt.Sprintf("arn:aws:ecs:us-west-2:1:service/c%d-%d", c, i)),
+				ServiceName: aws.String(fmt.Sprintf("c%d-%d", c, i)),
+			})
+		}
+		region.Clusters = append(region.Clusters, cluster)
+	}
+	f.Bezos.AddRegion(user.CustomerId, "us-west-2", region)
+
+	backend.Bezos = bezos
+	client := resolver.NewClientWithBackends(backend)
+	client.FanOut = resolver.FanOutConfig{Concurrency: 2}
+
+	groups, err := client.GetGroups(context.Background(), user, "us-west-2", "vpc-123", "", "", resolver.ResourceFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, groups, 160)
+	assert.Equal(t, 2, bezos.max)
+}
+
+func TestGetInstancesUnknownType(t *testing.T) {
+	user := &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+
+	instances, err := New().Client().GetInstances(context.Background(), user, "us-west-2", "vpc-123", "bogus", "", resolver.ResourceFilter{})
+	assert.Nil(t, instances)
+	if assert.Error(t, err) {
+		assert.Equal(t, resolver.ErrorInvalidInput, resolver.ErrorOf(err).Code)
+	}
+}
+
+func TestGetInstancesFilter(t *testing.T) {
+	var (
+		f    = New()
+		user = &schema.User{Id: int32(7), CustomerId: "140c5346-5d57-11e5-9947-9f9fcf62725e"}
+	)
+
+	instance := func(id, name, state, zone string) *opsee_aws_ec2.Instance {
+		return &opsee_aws_ec2.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: aws.String("t2.micro"),
+			VpcId:        aws.String("vpc-123"),
+			State:        &opsee_aws_ec2.InstanceState{Name: aws.String(state)},
+			Placement:    &opsee_aws_ec2.Placement{AvailabilityZone: aws.String(zone)},
+			Tags:         []*opsee_aws_ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+		}
+	}
+
+	f.Bezos.AddRegion(user.CustomerId, "us-west-2", &BezosRegion{
+		Instances: []*opsee_aws_ec2.Instance{
+			instance("i-1", "web-b", "running", "us-west-2a"),
+			instance("i-2", "web-a", "running", "us-west-2b"),
+			instance("i-3", "web-c", "stopped", "us-west-2a"),
+			instance("i-4", "db", "running", "us-west-2a"),
+		},
+	})
+
+	var filters []*opsee_aws_ec2.Filter
+	f.Bezos.Fail = func(req *opsee.BezosRequest) error {
+		if input := req.GetEc2_DescribeInstancesInput(); input != nil {
+			filters = input.Filters
+		}
+		return nil
+	}
+
+	instances, err := f.Client().GetInstances(context.Background(), user, "us-west-2", "vpc-123", "ec2", "", resolver.ResourceFilter{
+		State:      "RUNNING",
+		NamePrefix: "web",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		names = append(names, aws.StringValue(f.Name))
+	}
+	assert.Contains(t, names, "instance-state-name")
+	assert.Contains(t, names, "tag:Name")
+
+	items := []interface{}{}
+	for _, inst := range instances.([]*opsee_aws_ec2.Instance) {
+		items = append(items, inst)
+	}
+	resolver.SortResources(items, resolver.ResourceOrder{Field: resolver.OrderByName})
+
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "i-2", aws.StringValue(items[0].(*opsee_aws_ec2.Instance).InstanceId))
+		assert.Equal(t, "i-1", aws.StringValue(items[1].(*opsee_aws_ec2.Instance).InstanceId))
+	}
+}
+
+func TestEtcdCreateInOrderConcurrent(t *testing.T) {
+	var (
+		e  = NewEtcd()
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := e.CreateInOrder(context.Background(), "/queue", fmt.Sprintf("item-%d", i), nil)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	resp, err := e.Get(context.Background(), "/queue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, resp.Node.Nodes, 50)
+}
+
+func TestDynamo(t *testing.T) {
+	var (
+		f      = New()
+		client = f.Client()
+		key    = map[string]*dynamodb.AttributeValue{"customer_id": {S: aws.String("customer-1")}}
+	)
+
+	_, err := client.Dynamo.GetItem(&dynamodb.GetItemInput{TableName: aws.String("settings"), Key: key})
+	assert.Error(t, err)
+
+	f.Dynamo.CreateTable("settings", "customer_id")
+
+	_, err = client.Dynamo.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("settings"),
+		Item: map[string]*dynamodb.AttributeValue{
+			"customer_id": {S: aws.String("customer-1")},
+			"theme":       {S: aws.String("dark")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.Dynamo.GetItem(&dynamodb.GetItemInput{TableName: aws.String("settings"), Key: key})
+	if assert.NoError(t, err) && assert.NotNil(t, got.Item["theme"]) {
+		assert.Equal(t, "dark", aws.StringValue(got.Item["theme"].S))
+	}
+
+	deleted, err := client.Dynamo.DeleteItem(&dynamodb.DeleteItemInput{TableName: aws.String("settings"), Key: key, ReturnValues: aws.String(dynamodb.ReturnValueAllOld)})
+	if assert.NoError(t, err) {
+		assert.Len(t, deleted.Attributes, 2)
+	}
+
+	got, err = client.Dynamo.GetItem(&dynamodb.GetItemInput{TableName: aws.String("settings"), Key: key})
+	if assert.NoError(t, err) {
+		assert.Nil(t, got.Item)
+	}
+
+	_, err = client.Dynamo.PutItem(&dynamodb.PutItemInput{TableName: aws.String("settings"), Item: map[string]*dynamodb.AttributeValue{}})
+	assert.Error(t, err)
+}
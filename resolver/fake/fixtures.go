@@ -0,0 +1,215 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opsee/basic/clients/hugs"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"gopkg.in/yaml.v2"
+)
+
+// Fixture is the contents of one fixture file. Every section is optional, and
+// the sections of all files in a fixture directory are loaded together.
+// Objects use the same field names as their JSON encoding, so a response
+// captured from a real service can be pasted in as-is.
+type Fixture struct {
+	Users            []*schema.User                 `json:"users,omitempty"`
+	Teams            []*schema.Team                 `json:"teams,omitempty"`
+	Checks           []*schema.Check                `json:"checks,omitempty"`
+	CheckResults     []*schema.CheckResult          `json:"check_results,omitempty"`
+	StateTransitions []*schema.CheckStateTransition `json:"state_transitions,omitempty"`
+	Notifications    []*FixtureNotification         `json:"notifications,omitempty"`
+	BastionStates    []*schema.BastionState         `json:"bastion_states,omitempty"`
+	Regions          []*schema.Region               `json:"regions,omitempty"`
+	RoleStacks       []*schema.RoleStack            `json:"role_stacks,omitempty"`
+	AWS              []*FixtureRegion               `json:"aws,omitempty"`
+	CheckMetrics     []*FixtureCheckMetric          `json:"check_metrics,omitempty"`
+}
+
+// FixtureNotification is a notification for a customer's check, or a default
+// notification if CheckId is empty.
+type FixtureNotification struct {
+	CustomerId string `json:"customer_id"`
+	CheckId    string `json:"check_id,omitempty"`
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+}
+
+// FixtureRegion is the AWS resources, metrics and alarms of a customer's region.
+type FixtureRegion struct {
+	CustomerId string `json:"customer_id"`
+	Region     string `json:"region"`
+	*BezosRegion
+}
+
+// FixtureCheckMetric is a series of datapoints for one of a check's metrics.
+type FixtureCheckMetric struct {
+	CheckId    string             `json:"check_id"`
+	Name       string             `json:"name"`
+	Datapoints []*opsee.Datapoint `json:"datapoints"`
+}
+
+// LoadFixtures reads every .json, .yaml and .yml file in dir, in name order,
+// and seeds the backends with their contents.
+func (f *Fake) LoadFixtures(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, fi := range files {
+		if !fi.IsDir() {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var fixture *Fixture
+
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".json":
+			fixture, err = readFixture(filepath.Join(dir, name), false)
+		case ".yaml", ".yml":
+			fixture, err = readFixture(filepath.Join(dir, name), true)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		f.Load(fixture)
+	}
+
+	return nil
+}
+
+// Load seeds the backends with the contents of a fixture.
+func (f *Fake) Load(fixture *Fixture) {
+	for _, user := range fixture.Users {
+		f.Cats.AddUser(user)
+	}
+
+	for _, team := range fixture.Teams {
+		f.Cats.AddTeam(team)
+	}
+
+	for _, check := range fixture.Checks {
+		f.Bartnet.AddCheck(check.CustomerId, check)
+		f.Cats.AddCheck(check)
+	}
+
+	for _, result := range fixture.CheckResults {
+		f.Cats.AddResult(result)
+		f.Beavis.AddResult(result)
+	}
+
+	for _, transition := range fixture.StateTransitions {
+		f.Cats.AddTransition(transition)
+	}
+
+	for _, n := range fixture.Notifications {
+		var (
+			user  = &schema.User{CustomerId: n.CustomerId}
+			notif = &hugs.Notification{CheckId: n.CheckId, Type: n.Type, Value: n.Value}
+		)
+
+		if n.CheckId == "" {
+			defaults, _ := f.Hugs.ListNotificationsDefault(user)
+			f.Hugs.CreateNotificationsDefault(user, &hugs.NotificationRequest{
+				Notifications: append(defaults, notif),
+			})
+			continue
+		}
+
+		existing, _ := f.Hugs.ListNotificationsCheck(user, n.CheckId)
+		f.Hugs.CreateNotifications(user, &hugs.NotificationRequest{
+			CheckId:       n.CheckId,
+			Notifications: append(existing, notif),
+		})
+	}
+
+	for _, state := range fixture.BastionStates {
+		f.Keelhaul.AddBastionState(state)
+	}
+
+	for _, region := range fixture.Regions {
+		f.Keelhaul.AddRegion(region)
+	}
+
+	for _, stack := range fixture.RoleStacks {
+		f.Spanx.AddRoleStack(stack)
+	}
+
+	for _, r := range fixture.AWS {
+		if r.BezosRegion != nil {
+			f.Bezos.AddRegion(r.CustomerId, r.Region, r.BezosRegion)
+		}
+	}
+
+	for _, m := range fixture.CheckMetrics {
+		f.Marktricks.AddDatapoints(m.CheckId, m.Name, m.Datapoints...)
+	}
+}
+
+func readFixture(path string, isYAML bool) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if isYAML {
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fixture := &Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, err
+	}
+
+	return fixture, nil
+}
+
+// yamlToJSON re-encodes a YAML document as JSON so that fixtures are decoded
+// with the objects' json tags either way.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonValue(doc))
+}
+
+// jsonValue converts the map[interface{}]interface{} values produced by the
+// yaml decoder into map[string]interface{} values that json can encode.
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+
+	case []interface{}:
+		for i, val := range t {
+			t[i] = jsonValue(val)
+		}
+		return t
+	}
+
+	return v
+}
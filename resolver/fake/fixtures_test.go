@@ -0,0 +1,39 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/opsee/basic/schema"
+	"github.com/opsee/compost/resolver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	f := New()
+	if err := f.LoadFixtures("../../fixtures"); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &schema.User{Id: 1, CustomerId: "5963d7bc-6ba2-11e5-8603-6ba085b2f5b5"}
+	client := f.Client()
+
+	checks, err := client.ListChecks(context.Background(), user, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, checks, 1)
+	assert.IsType(t, &schema.Check_HttpCheck{}, checks[0].Spec)
+	assert.Len(t, checks[0].Notifications, 1)
+
+	results, err := client.CheckResults(context.Background(), user, checks[0].Id)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	instances, err := client.GetInstances(context.Background(), user, "us-west-2", "vpc-11111111", "ec2", "i-11111111", resolver.ResourceFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, instances, 1)
+}
@@ -0,0 +1,82 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/opsee/basic/clients/hugs"
+	"github.com/opsee/basic/schema"
+)
+
+// Hugs stores check and default notifications per customer.
+type Hugs struct {
+	mut           sync.Mutex
+	notifications map[string]map[string][]*hugs.Notification
+	defaults      map[string][]*hugs.Notification
+}
+
+func NewHugs() *Hugs {
+	return &Hugs{
+		notifications: make(map[string]map[string][]*hugs.Notification),
+		defaults:      make(map[string][]*hugs.Notification),
+	}
+}
+
+func (h *Hugs) ListNotifications(user *schema.User) ([]*hugs.Notification, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	var notifs []*hugs.Notification
+	for _, n := range h.notifications[customerId(user)] {
+		notifs = append(notifs, n...)
+	}
+
+	return notifs, nil
+}
+
+func (h *Hugs) ListNotificationsDefault(user *schema.User) ([]*hugs.Notification, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	return h.defaults[customerId(user)], nil
+}
+
+func (h *Hugs) ListNotificationsCheck(user *schema.User, checkId string) ([]*hugs.Notification, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	return h.notifications[customerId(user)][checkId], nil
+}
+
+// CreateNotifications replaces the notifications for the request's check.
+func (h *Hugs) CreateNotifications(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	cid := customerId(user)
+	if h.notifications[cid] == nil {
+		h.notifications[cid] = make(map[string][]*hugs.Notification)
+	}
+
+	notifs := make([]*hugs.Notification, 0, len(noteReq.Notifications))
+	for _, n := range noteReq.Notifications {
+		notifs = append(notifs, &hugs.Notification{CheckId: noteReq.CheckId, Type: n.Type, Value: n.Value})
+	}
+	h.notifications[cid][noteReq.CheckId] = notifs
+
+	return nil
+}
+
+func (h *Hugs) CreateNotificationsDefault(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	h.defaults[customerId(user)] = noteReq.Notifications
+	return nil
+}
+
+func (h *Hugs) CreateNotificationsMulti(user *schema.User, noteReq []*hugs.NotificationRequest) error {
+	for _, req := range noteReq {
+		if err := h.CreateNotifications(user, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
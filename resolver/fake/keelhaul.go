@@ -0,0 +1,101 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Keelhaul stores bastion states, scanned regions and launched stacks.
+type Keelhaul struct {
+	mut           sync.Mutex
+	bastionStates []*schema.BastionState
+	regions       map[string]*schema.Region
+	ids           idSequence
+
+	// Launched records every successful LaunchStack request.
+	Launched []*opsee.LaunchStackRequest
+}
+
+func NewKeelhaul() *Keelhaul {
+	return &Keelhaul{
+		regions: make(map[string]*schema.Region),
+	}
+}
+
+// AddBastionState stores a bastion state.
+func (k *Keelhaul) AddBastionState(state *schema.BastionState) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+	k.bastionStates = append(k.bastionStates, state)
+}
+
+// AddRegion stores the result of scanning a region for the region's customer.
+func (k *Keelhaul) AddRegion(region *schema.Region) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+	k.regions[region.CustomerId+"/"+region.Region] = region
+}
+
+func (k *Keelhaul) ListBastionStates(ctx context.Context, in *opsee.ListBastionStatesRequest, opts ...grpc.CallOption) (*opsee.ListBastionStatesResponse, error) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	customers := make(map[string]bool)
+	for _, cid := range in.CustomerIds {
+		customers[cid] = true
+	}
+
+	var states []*schema.BastionState
+	for _, s := range k.bastionStates {
+		if len(customers) == 0 || customers[s.CustomerId] {
+			states = append(states, s)
+		}
+	}
+
+	return &opsee.ListBastionStatesResponse{BastionStates: states}, nil
+}
+
+// ScanVpcs returns the stored region, or an empty region if none was added.
+func (k *Keelhaul) ScanVpcs(ctx context.Context, in *opsee.ScanVpcsRequest, opts ...grpc.CallOption) (*opsee.ScanVpcsResponse, error) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	cid := customerId(in.User)
+	region, ok := k.regions[cid+"/"+in.Region]
+	if !ok {
+		region = &schema.Region{Region: in.Region, CustomerId: cid}
+	}
+
+	return &opsee.ScanVpcsResponse{Region: region}, nil
+}
+
+// LaunchStack records the request and marks a new bastion as launching.
+func (k *Keelhaul) LaunchStack(ctx context.Context, in *opsee.LaunchStackRequest, opts ...grpc.CallOption) (*opsee.LaunchStackResponse, error) {
+	if in.VpcId == "" || in.SubnetId == "" {
+		return nil, fmt.Errorf("missing vpc or subnet")
+	}
+
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	stackId := k.ids.id("stack-")
+	k.Launched = append(k.Launched, in)
+	k.bastionStates = append(k.bastionStates, &schema.BastionState{
+		Id:         stackId,
+		CustomerId: customerId(in.User),
+		Status:     "launching",
+		Region:     in.Region,
+		VpcId:      in.VpcId,
+	})
+
+	return &opsee.LaunchStackResponse{StackId: stackId}, nil
+}
+
+func (k *Keelhaul) AuthenticateBastion(ctx context.Context, in *opsee.AuthenticateBastionRequest, opts ...grpc.CallOption) (*opsee.AuthenticateBastionResponse, error) {
+	return &opsee.AuthenticateBastionResponse{Success: true}, nil
+}
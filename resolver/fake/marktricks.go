@@ -0,0 +1,89 @@
+package fake
+
+import (
+	"sync"
+
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Marktricks stores check metric datapoints per check and metric name. It
+// ignores aggregation and returns the stored datapoints as a single result.
+type Marktricks struct {
+	mut        sync.Mutex
+	datapoints map[string]map[string][]*opsee.Datapoint
+}
+
+func NewMarktricks() *Marktricks {
+	return &Marktricks{
+		datapoints: make(map[string]map[string][]*opsee.Datapoint),
+	}
+}
+
+// AddDatapoints stores datapoints for a check's metric.
+func (m *Marktricks) AddDatapoints(checkId, metricName string, datapoints ...*opsee.Datapoint) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.datapoints[checkId] == nil {
+		m.datapoints[checkId] = make(map[string][]*opsee.Datapoint)
+	}
+	m.datapoints[checkId][metricName] = append(m.datapoints[checkId][metricName], datapoints...)
+}
+
+func (m *Marktricks) GetMetrics(ctx context.Context, in *opsee.GetMetricsRequest, opts ...grpc.CallOption) (*opsee.GetMetricsResponse, error) {
+	return &opsee.GetMetricsResponse{}, nil
+}
+
+func (m *Marktricks) QueryMetrics(ctx context.Context, in *opsee.QueryMetricsRequest, opts ...grpc.CallOption) (*opsee.QueryMetricsResponse, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var (
+		start int64
+		end   int64
+		resp  = &opsee.QueryMetricsResponse{}
+	)
+
+	if in.StartAbsolute != nil {
+		start = in.StartAbsolute.Millis()
+	}
+	if in.EndAbsolute != nil {
+		end = in.EndAbsolute.Millis()
+	}
+
+	for _, qm := range in.Metrics {
+		query := &opsee.Query{}
+
+		var checkIds []string
+		if check, ok := qm.Tags["check"]; ok {
+			checkIds = check.Values
+		}
+
+		for _, checkId := range checkIds {
+			var values []*opsee.Datapoint
+			for _, dp := range m.datapoints[checkId][qm.Name] {
+				if dp.Timestamp != nil {
+					ms := dp.Timestamp.Millis()
+					if (start > 0 && ms < start) || (end > 0 && ms > end) {
+						continue
+					}
+				}
+				values = append(values, dp)
+			}
+
+			query.Results = append(query.Results, &opsee.Result{
+				Name: qm.Name,
+				Tags: map[string]*opsee.StringList{
+					"check": &opsee.StringList{Values: []string{checkId}},
+				},
+				Values: values,
+			})
+		}
+
+		resp.Queries = append(resp.Queries, query)
+	}
+
+	return resp, nil
+}
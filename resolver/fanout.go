@@ -0,0 +1,115 @@
+package resolver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultFanOutConcurrency bounds a fan-out whose config doesn't.
+const DefaultFanOutConcurrency = 4
+
+// FanOutConfig bounds the backend calls a Client makes in parallel to resolve
+// a single field, such as the describe calls behind an untyped groups query or
+// the Bezos calls of an ECS crawl.
+// Each call is given Timeout to complete; zero leaves calls bounded only by
+// the request.
+type FanOutConfig struct {
+	Concurrency int
+	Timeout     time.Duration
+}
+
+// fanOutTask is one call of a fan-out.
+type fanOutTask func(context.Context) (interface{}, error)
+
+// fanOut bounds the backend calls made to resolve a single field, however
+// deeply they're nested: every call made through it waits on one semaphore of
+// config.Concurrency, and is given config.Timeout.
+type fanOut struct {
+	config FanOutConfig
+	sem    chan struct{}
+}
+
+func (config FanOutConfig) fanOut() *fanOut {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	return &fanOut{
+		config: config,
+		sem:    make(chan struct{}, concurrency),
+	}
+}
+
+// bounded returns task made through f.call.
+func (f *fanOut) bounded(task fanOutTask) fanOutTask {
+	return func(ctx context.Context) (interface{}, error) {
+		result := f.call(ctx, task)
+		return result.response, result.err
+	}
+}
+
+// call runs task once the semaphore admits it. A task still running once
+// config.Timeout elapses is abandoned, and its result is a retryable
+// UPSTREAM_UNAVAILABLE error, as from a backend that timed out.
+func (f *fanOut) call(ctx context.Context, task fanOutTask) backendResponse {
+	select {
+	case f.sem <- struct{}{}:
+		defer func() { <-f.sem }()
+	case <-ctx.Done():
+		return backendResponse{nil, abandonedError(ctx.Err())}
+	}
+
+	if f.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.config.Timeout)
+		defer cancel()
+	}
+
+	respChan := make(chan backendResponse, 1)
+	go func() {
+		resp, err := task(ctx)
+		respChan <- backendResponse{resp, err}
+	}()
+
+	select {
+	case resp := <-respChan:
+		return resp
+	case <-ctx.Done():
+		return backendResponse{nil, abandonedError(ctx.Err())}
+	}
+}
+
+func abandonedError(err error) *Error {
+	return &Error{
+		Code:      ErrorUpstreamUnavailable,
+		Retryable: true,
+		Message:   fmt.Sprintf("call abandoned: %s", err),
+	}
+}
+
+// runTasks runs the tasks concurrently and returns their results in task
+// order. The tasks aren't bounded themselves; the calls they make through a
+// fanOut are.
+func runTasks(ctx context.Context, tasks []fanOutTask) []backendResponse {
+	var (
+		wg      sync.WaitGroup
+		results = make([]backendResponse, len(tasks))
+	)
+
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task fanOutTask) {
+			defer wg.Done()
+			resp, err := task(ctx)
+			results[i] = backendResponse{resp, err}
+		}(i, task)
+	}
+
+	wg.Wait()
+
+	return results
+}
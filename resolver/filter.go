@@ -0,0 +1,332 @@
+package resolver
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	opsee_aws_autoscaling "github.com/opsee/basic/schema/aws/autoscaling"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee_aws_ecs "github.com/opsee/basic/schema/aws/ecs"
+	opsee_aws_elb "github.com/opsee/basic/schema/aws/elb"
+	opsee_aws_rds "github.com/opsee/basic/schema/aws/rds"
+)
+
+// ResourceFilter narrows the instances and groups returned by GetInstances and
+// GetGroups. Empty fields match everything; a resource without the attribute
+// a field filters on, like a load balancer's tags, never matches it. Where
+// AWS supports it the filter is sent with the describe call, and it is always
+// applied to the results.
+type ResourceFilter struct {
+	TagKey           string
+	TagValue         string
+	State            string
+	InstanceType     string
+	NamePrefix       string
+	AvailabilityZone string
+}
+
+// The fields instances and groups can be ordered by.
+const (
+	OrderById               = "id"
+	OrderByName             = "name"
+	OrderByState            = "state"
+	OrderByInstanceType     = "instance_type"
+	OrderByAvailabilityZone = "availability_zone"
+)
+
+// resourceAttributes are the attributes of an instance or group that can be
+// filtered and ordered on. Those a resource doesn't have are nil.
+type resourceAttributes struct {
+	id           string
+	name         *string
+	state        *string
+	instanceType *string
+	zones        []string
+	tags         map[string]string
+}
+
+func attributesOf(resource interface{}) *resourceAttributes {
+	switch r := resource.(type) {
+	case *opsee_aws_ec2.Instance:
+		attrs := &resourceAttributes{
+			id:           aws.StringValue(r.InstanceId),
+			instanceType: r.InstanceType,
+			tags:         make(map[string]string),
+		}
+		for _, t := range r.Tags {
+			attrs.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		if name, ok := attrs.tags["Name"]; ok {
+			attrs.name = aws.String(name)
+		}
+		if r.State != nil {
+			attrs.state = r.State.Name
+		}
+		if r.Placement != nil && r.Placement.AvailabilityZone != nil {
+			attrs.zones = []string{aws.StringValue(r.Placement.AvailabilityZone)}
+		}
+		return attrs
+	case *opsee_aws_rds.DBInstance:
+		attrs := &resourceAttributes{
+			id:           aws.StringValue(r.DBInstanceIdentifier),
+			name:         r.DBInstanceIdentifier,
+			state:        r.DBInstanceStatus,
+			instanceType: r.DBInstanceClass,
+		}
+		if r.AvailabilityZone != nil {
+			attrs.zones = []string{aws.StringValue(r.AvailabilityZone)}
+		}
+		return attrs
+	case *opsee_aws_ec2.SecurityGroup:
+		attrs := &resourceAttributes{
+			id:   aws.StringValue(r.GroupId),
+			name: r.GroupName,
+			tags: make(map[string]string),
+		}
+		for _, t := range r.Tags {
+			attrs.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		return attrs
+	case *opsee_aws_elb.LoadBalancerDescription:
+		return &resourceAttributes{
+			id:    aws.StringValue(r.LoadBalancerName),
+			name:  r.LoadBalancerName,
+			zones: r.AvailabilityZones,
+		}
+	case *opsee_aws_autoscaling.Group:
+		attrs := &resourceAttributes{
+			id:    aws.StringValue(r.AutoScalingGroupName),
+			name:  r.AutoScalingGroupName,
+			state: r.Status,
+			zones: r.AvailabilityZones,
+			tags:  make(map[string]string),
+		}
+		for _, t := range r.Tags {
+			attrs.tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		return attrs
+	case *opsee_aws_ecs.Service:
+		return &resourceAttributes{
+			id:    aws.StringValue(r.ServiceArn),
+			name:  r.ServiceName,
+			state: r.Status,
+		}
+	}
+
+	return &resourceAttributes{}
+}
+
+func (f ResourceFilter) empty() bool {
+	return f == ResourceFilter{}
+}
+
+// Matches reports whether resource, an instance or group, passes the filter.
+func (f ResourceFilter) Matches(resource interface{}) bool {
+	if f.empty() {
+		return true
+	}
+
+	attrs := attributesOf(resource)
+
+	if f.TagKey != "" || f.TagValue != "" {
+		if !attrs.hasTag(f.TagKey, f.TagValue) {
+			return false
+		}
+	}
+
+	if f.State != "" && (attrs.state == nil || !strings.EqualFold(*attrs.state, f.State)) {
+		return false
+	}
+
+	if f.InstanceType != "" && (attrs.instanceType == nil || *attrs.instanceType != f.InstanceType) {
+		return false
+	}
+
+	if f.NamePrefix != "" && (attrs.name == nil || !strings.HasPrefix(*attrs.name, f.NamePrefix)) {
+		return false
+	}
+
+	if f.AvailabilityZone != "" && !containsString(attrs.zones, f.AvailabilityZone) {
+		return false
+	}
+
+	return true
+}
+
+// filterResources returns the elements of resources, a slice of instances or
+// groups, that match the filter, in a slice of the same type.
+func filterResources(resources interface{}, filter ResourceFilter) interface{} {
+	v := reflect.ValueOf(resources)
+	if filter.empty() || v.Kind() != reflect.Slice {
+		return resources
+	}
+
+	filtered := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if filter.Matches(v.Index(i).Interface()) {
+			filtered = reflect.Append(filtered, v.Index(i))
+		}
+	}
+
+	return filtered.Interface()
+}
+
+func (a *resourceAttributes) hasTag(key, value string) bool {
+	for k, v := range a.tags {
+		if (key == "" || k == key) && (value == "" || v == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ec2InstanceFilters returns the filter as DescribeInstances filters.
+func (f ResourceFilter) ec2InstanceFilters() []*opsee_aws_ec2.Filter {
+	filters := f.ec2TagFilters()
+
+	if f.State != "" {
+		filters = append(filters, ec2Filter("instance-state-name", strings.ToLower(f.State)))
+	}
+
+	if f.InstanceType != "" {
+		filters = append(filters, ec2Filter("instance-type", f.InstanceType))
+	}
+
+	if f.AvailabilityZone != "" {
+		filters = append(filters, ec2Filter("availability-zone", f.AvailabilityZone))
+	}
+
+	if prefix, ok := ec2Prefix(f.NamePrefix); ok {
+		filters = append(filters, ec2Filter("tag:Name", prefix))
+	}
+
+	return filters
+}
+
+// securityGroupFilters returns the parts of the filter DescribeSecurityGroups
+// supports as its filters.
+func (f ResourceFilter) securityGroupFilters() []*opsee_aws_ec2.Filter {
+	filters := f.ec2TagFilters()
+
+	if prefix, ok := ec2Prefix(f.NamePrefix); ok {
+		filters = append(filters, ec2Filter("group-name", prefix))
+	}
+
+	return filters
+}
+
+func (f ResourceFilter) ec2TagFilters() []*opsee_aws_ec2.Filter {
+	switch {
+	case f.TagKey != "" && f.TagValue != "":
+		return []*opsee_aws_ec2.Filter{ec2Filter("tag:"+f.TagKey, f.TagValue)}
+	case f.TagKey != "":
+		return []*opsee_aws_ec2.Filter{ec2Filter("tag-key", f.TagKey)}
+	case f.TagValue != "":
+		return []*opsee_aws_ec2.Filter{ec2Filter("tag-value", f.TagValue)}
+	}
+	return nil
+}
+
+// ec2Prefix returns an EC2 filter value matching names starting with prefix,
+// unless prefix has characters EC2 treats as wildcards.
+func ec2Prefix(prefix string) (string, bool) {
+	if prefix == "" || strings.ContainsAny(prefix, `*?\`) {
+		return "", false
+	}
+	return prefix + "*", true
+}
+
+func ec2Filter(name, value string) *opsee_aws_ec2.Filter {
+	return &opsee_aws_ec2.Filter{
+		Name:   aws.String(name),
+		Values: []string{value},
+	}
+}
+
+// ResourceOrder orders instances or groups by one of the OrderBy fields.
+// Resources without the field sort last, and ties keep their order.
+type ResourceOrder struct {
+	Field      string
+	Descending bool
+}
+
+// SortResources sorts instances or groups in place.
+func SortResources(resources []interface{}, order ResourceOrder) {
+	values := make(map[interface{}]*string, len(resources))
+	for _, r := range resources {
+		values[r] = attributesOf(r).orderValue(order.Field)
+	}
+
+	sort.Stable(resourceSorter{resources, func(a, b interface{}) bool {
+		va, vb := values[a], values[b]
+		switch {
+		case va == nil || vb == nil:
+			return va != nil && vb == nil
+		case order.Descending:
+			return *va > *vb
+		}
+		return *va < *vb
+	}})
+}
+
+// Position returns a string for resource, whose key is key, that sorts
+// before or after another resource's position just as SortResources orders
+// the two, given resources already sorted by key. A resource's position can
+// be compared with the others' after the resource itself is gone.
+func (o ResourceOrder) Position(resource interface{}, key string) string {
+	value := attributesOf(resource).orderValue(o.Field)
+	if value == nil {
+		return "1" + key
+	}
+
+	if !o.Descending {
+		return "0" + *value + "\x00" + key
+	}
+
+	// Inverting each byte reverses the order of the values, and a
+	// terminator above every inverted byte sorts a value after those it is
+	// a prefix of.
+	inverted := []byte(*value)
+	for i, b := range inverted {
+		inverted[i] = 0xff - b
+	}
+	return "0" + string(inverted) + "\xff" + key
+}
+
+func (a *resourceAttributes) orderValue(field string) *string {
+	switch field {
+	case OrderById:
+		return &a.id
+	case OrderByName:
+		return a.name
+	case OrderByState:
+		return a.state
+	case OrderByInstanceType:
+		return a.instanceType
+	case OrderByAvailabilityZone:
+		if len(a.zones) > 0 {
+			return &a.zones[0]
+		}
+	}
+	return nil
+}
+
+type resourceSorter struct {
+	items []interface{}
+	less  func(a, b interface{}) bool
+}
+
+func (s resourceSorter) Len() int           { return len(s.items) }
+func (s resourceSorter) Swap(i, j int)      { s.items[i], s.items[j] = s.items[j], s.items[i] }
+func (s resourceSorter) Less(i, j int) bool { return s.less(s.items[i], s.items[j]) }
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -46,66 +46,130 @@ func (c *Client) GetTaskDefinition(ctx context.Context, user *schema.User, regio
 	return output.TaskDefinition, nil
 }
 
-func (c *Client) GetGroups(ctx context.Context, user *schema.User, region, vpc, groupType, groupId string) (interface{}, error) {
+func (c *Client) GetGroups(ctx context.Context, user *schema.User, region, vpc, groupType, groupId string, filter ResourceFilter) (interface{}, error) {
 	log.WithFields(log.Fields{
 		"customer_id": user.CustomerId,
 	}).Info("get groups request")
 
+	var (
+		groups interface{}
+		err    error
+	)
+
 	switch groupType {
 	case "security":
-		return c.getGroupsSecurity(ctx, user, region, vpc, groupId)
+		groups, err = c.getGroupsSecurity(ctx, user, region, vpc, groupId, filter)
 	case "ecs_service":
-		return c.getGroupsEcsService(ctx, user, region, vpc, groupId)
+		groups, err = c.getGroupsEcsService(ctx, c.FanOut.fanOut(), user, region, vpc, groupId)
 	case "elb":
-		return c.getGroupsElb(ctx, user, region, vpc, groupId)
+		groups, err = c.getGroupsElb(ctx, user, region, vpc, groupId)
 	case "autoscaling":
-		return c.getGroupsAutoscaling(ctx, user, region, vpc, groupId)
+		groups, err = c.getGroupsAutoscaling(ctx, user, region, vpc, groupId)
 	case "":
-		groupsSec, err := c.getGroupsSecurity(ctx, user, region, vpc, groupId)
-		if err != nil {
-			return nil, err
-		}
+		groups, err = c.getGroupsAll(ctx, user, region, vpc, groupId, filter)
+	default:
+		return nil, Errorf(ErrorInvalidInput, "group type not known: %s", groupType)
+	}
 
-		groupsEcs, err := c.getGroupsEcsService(ctx, user, region, vpc, groupId)
-		if err != nil {
-			return nil, err
-		}
+	// a GroupsError comes with the groups that could be fetched
+	if _, partial := err.(GroupsError); err != nil && !partial {
+		return nil, err
+	}
 
-		groupsElb, err := c.getGroupsElb(ctx, user, region, vpc, groupId)
-		if err != nil {
-			return nil, err
-		}
+	return filterResources(groups, filter), err
+}
 
-		groupsAut, err := c.getGroupsAutoscaling(ctx, user, region, vpc, groupId)
-		if err != nil {
-			return nil, err
-		}
+// GroupTypeError is the error fetching one type of group.
+type GroupTypeError struct {
+	GroupType string
+	Err       error
+}
 
-		// TODO: concurrency
-		var allgroups []interface{}
-		for _, g := range groupsSec {
-			allgroups = append(allgroups, interface{}(g))
-		}
-		for _, g := range groupsEcs {
-			allgroups = append(allgroups, interface{}(g))
-		}
-		for _, g := range groupsElb {
-			allgroups = append(allgroups, interface{}(g))
+func (e *GroupTypeError) Error() string {
+	return fmt.Sprintf("error fetching %s groups: %s", e.GroupType, e.Err)
+}
+
+// GroupsError is returned by GetGroups, alongside the groups that could be
+// fetched, when fetching some types of group failed.
+type GroupsError []*GroupTypeError
+
+func (e GroupsError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// getGroupsAll fetches every type of group in parallel. Types that fail are
+// reported in a GroupsError returned with the groups of the types that didn't.
+// The describe calls of every type, including those of the ECS crawl, share
+// one fanOut.
+func (c *Client) getGroupsAll(ctx context.Context, user *schema.User, region, vpc, groupId string, filter ResourceFilter) ([]interface{}, error) {
+	fan := c.FanOut.fanOut()
+	groupTypes := []string{"security", "ecs_service", "elb", "autoscaling"}
+	tasks := []fanOutTask{
+		fan.bounded(func(ctx context.Context) (interface{}, error) {
+			return c.getGroupsSecurity(ctx, user, region, vpc, groupId, filter)
+		}),
+		func(ctx context.Context) (interface{}, error) {
+			return c.getGroupsEcsService(ctx, fan, user, region, vpc, groupId)
+		},
+		fan.bounded(func(ctx context.Context) (interface{}, error) {
+			return c.getGroupsElb(ctx, user, region, vpc, groupId)
+		}),
+		fan.bounded(func(ctx context.Context) (interface{}, error) {
+			return c.getGroupsAutoscaling(ctx, user, region, vpc, groupId)
+		}),
+	}
+
+	var (
+		allgroups []interface{}
+		groupsErr GroupsError
+	)
+
+	for i, result := range runTasks(ctx, tasks) {
+		if result.err != nil {
+			log.WithError(result.err).WithFields(log.Fields{
+				"customer_id": user.CustomerId,
+				"group_type":  groupTypes[i],
+			}).Error("error fetching groups")
+
+			groupsErr = append(groupsErr, &GroupTypeError{groupTypes[i], result.err})
+			continue
 		}
-		for _, g := range groupsAut {
-			allgroups = append(allgroups, interface{}(g))
+
+		switch groups := result.response.(type) {
+		case []*opsee_aws_ec2.SecurityGroup:
+			for _, g := range groups {
+				allgroups = append(allgroups, g)
+			}
+		case []*opsee_aws_ecs.Service:
+			for _, g := range groups {
+				allgroups = append(allgroups, g)
+			}
+		case []*opsee_aws_elb.LoadBalancerDescription:
+			for _, g := range groups {
+				allgroups = append(allgroups, g)
+			}
+		case []*opsee_aws_autoscaling.Group:
+			for _, g := range groups {
+				allgroups = append(allgroups, g)
+			}
 		}
+	}
 
-		return allgroups, nil
+	if groupsErr != nil {
+		return allgroups, groupsErr
 	}
 
-	return fmt.Errorf("group type not known: %s", groupType), nil
+	return allgroups, nil
 }
 
 // getGroupsEcsService takes the normal arguments, but the groupId argument is actually a tuple of
 // (ecs cluster name/arn, service name/arn). If left blank, we will try our best to find all of the
-// services running only on clusters deployed to this VPC.
-func (c *Client) getGroupsEcsService(ctx context.Context, user *schema.User, region, vpc, groupId string) ([]*opsee_aws_ecs.Service, error) {
+// services running only on clusters deployed to this VPC, making the crawl's calls through fan.
+func (c *Client) getGroupsEcsService(ctx context.Context, fan *fanOut, user *schema.User, region, vpc, groupId string) ([]*opsee_aws_ecs.Service, error) {
 	logger := log.WithFields(log.Fields{
 		"customer_id": user.CustomerId,
 		"endpoint":    "getGroupsEcsService",
@@ -115,7 +179,7 @@ func (c *Client) getGroupsEcsService(ctx context.Context, user *schema.User, reg
 	if groupId != "" {
 		t := strings.Split(groupId, "/")
 		if len(t) < 2 {
-			return nil, fmt.Errorf("Invalid group id for ECS Service")
+			return nil, NewError(ErrorInvalidInput, "Invalid group id for ECS Service")
 		}
 
 		cluster_id := t[0]
@@ -151,185 +215,17 @@ func (c *Client) getGroupsEcsService(ctx context.Context, user *schema.User, reg
 		return output.Services, nil
 	}
 
-	lcInput := &opsee_aws_ecs.ListClustersInput{}
-	resp, err := c.Bezos.Get(
-		ctx,
-		&opsee.BezosRequest{
-			User:   user,
-			Region: region,
-			VpcId:  vpc,
-			Input:  &opsee.BezosRequest_Ecs_ListClustersInput{lcInput},
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// TODO(greg): support paging. god help us.
-	lcOutput := resp.GetEcs_ListClustersOutput()
-	if lcOutput == nil {
-		return nil, fmt.Errorf("error decoding aws response")
-	}
-
-	if len(lcOutput.ClusterArns) == 0 {
-		logger.Info("no clusters found")
-	}
-
-	var svcs []*opsee_aws_ecs.Service
-
-	for _, cArn := range lcOutput.ClusterArns {
-		lciInput := &opsee_aws_ecs.ListContainerInstancesInput{
-			Cluster: aws.String(cArn),
-		}
-		resp, err := c.Bezos.Get(
-			ctx,
-			&opsee.BezosRequest{
-				User:   user,
-				Region: region,
-				VpcId:  vpc,
-				Input:  &opsee.BezosRequest_Ecs_ListContainerInstancesInput{lciInput},
-			},
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// TODO(greg): support paging. god help us.
-		lciOutput := resp.GetEcs_ListContainerInstancesOutput()
-		if lciOutput == nil {
-			return nil, fmt.Errorf("error decoding aws response")
-		}
-
-		if len(lciOutput.ContainerInstanceArns) == 0 {
-			logger.Info("no container instances found")
-			return svcs, nil
-		}
-
-		dciInput := &opsee_aws_ecs.DescribeContainerInstancesInput{
-			Cluster:            aws.String(cArn),
-			ContainerInstances: lciOutput.ContainerInstanceArns,
-		}
-		resp, err = c.Bezos.Get(
-			ctx,
-			&opsee.BezosRequest{
-				User:   user,
-				Region: region,
-				VpcId:  vpc,
-				Input:  &opsee.BezosRequest_Ecs_DescribeContainerInstancesInput{dciInput},
-			},
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// TODO(greg): support paging. god help us.
-		dciOutput := resp.GetEcs_DescribeContainerInstancesOutput()
-		if dciOutput == nil {
-			return nil, fmt.Errorf("error decoding aws response")
-		}
-
-		if len(dciOutput.ContainerInstances) == 0 {
-			logger.Info("no container instances found")
-		}
-
-		if len(dciOutput.ContainerInstances) > 0 {
-			instanceId := dciOutput.ContainerInstances[0].Ec2InstanceId
-			input := &opsee_aws_ec2.DescribeInstancesInput{
-				InstanceIds: []string{aws.StringValue(instanceId)},
-			}
-
-			resp, err := c.Bezos.Get(
-				ctx,
-				&opsee.BezosRequest{
-					User:   user,
-					Region: region,
-					VpcId:  vpc,
-					Input:  &opsee.BezosRequest_Ec2_DescribeInstancesInput{input},
-				},
-			)
-			if err != nil {
-				return nil, err
-			}
-
-			output := resp.GetEc2_DescribeInstancesOutput()
-			if output != nil {
-				var nextToken *string
-				for {
-					// we have a container instance in the vpc we're in... now we can get
-					// services for this cluster.
-					lsInput := &opsee_aws_ecs.ListServicesInput{
-						Cluster:   aws.String(cArn),
-						NextToken: nextToken,
-					}
-
-					resp, err := c.Bezos.Get(
-						ctx,
-						&opsee.BezosRequest{
-							User:   user,
-							Region: region,
-							VpcId:  vpc,
-							Input:  &opsee.BezosRequest_Ecs_ListServicesInput{lsInput},
-						},
-					)
-					if err != nil {
-						return nil, err
-					}
-
-					lsOutput := resp.GetEcs_ListServicesOutput()
-					if lsOutput == nil {
-						break
-					}
-
-					nextToken = lsOutput.NextToken
-
-					dsInput := &opsee_aws_ecs.DescribeServicesInput{
-						Cluster:  aws.String(cArn),
-						Services: lsOutput.ServiceArns,
-					}
-
-					resp, err = c.Bezos.Get(
-						ctx,
-						&opsee.BezosRequest{
-							User:   user,
-							Region: region,
-							VpcId:  vpc,
-							Input:  &opsee.BezosRequest_Ecs_DescribeServicesInput{dsInput},
-						},
-					)
-					if err != nil {
-						return nil, err
-					}
-
-					dsOutput := resp.GetEcs_DescribeServicesOutput()
-					if dsOutput != nil {
-						if len(dsOutput.Services) == 0 {
-							logger.Info("no services found")
-						}
-
-						for _, s := range dsOutput.Services {
-							svcs = append(svcs, s)
-						}
-					}
-
-					if nextToken == nil {
-						break
-					}
-				}
-			}
-		}
-	}
-
-	return svcs, nil
+	return c.discoverEcsServices(ctx, fan, user, region, vpc)
 }
 
-func (c *Client) getGroupsSecurity(ctx context.Context, user *schema.User, region, vpc, groupId string) ([]*opsee_aws_ec2.SecurityGroup, error) {
+func (c *Client) getGroupsSecurity(ctx context.Context, user *schema.User, region, vpc, groupId string, filter ResourceFilter) ([]*opsee_aws_ec2.SecurityGroup, error) {
 	input := &opsee_aws_ec2.DescribeSecurityGroupsInput{
-		Filters: []*opsee_aws_ec2.Filter{
+		Filters: append([]*opsee_aws_ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpc},
 			},
-		},
+		}, filter.securityGroupFilters()...),
 	}
 
 	if groupId != "" {
@@ -0,0 +1,320 @@
+package resolver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// The kinds of dependencies compost checks.
+const (
+	DependencyGRPC = "grpc"
+	DependencyHTTP = "http"
+	DependencyEtcd = "etcd"
+)
+
+// The states of http and etcd dependencies. gRPC dependencies are in the state
+// of their connection: IDLE, CONNECTING, READY, TRANSIENT_FAILURE or SHUTDOWN.
+const (
+	StateReachable   = "REACHABLE"
+	StateUnreachable = "UNREACHABLE"
+)
+
+// DefaultHealthTimeout bounds each probe of an http or etcd dependency.
+const DefaultHealthTimeout = 2 * time.Second
+
+// DependencyStatus is a snapshot of one of compost's dependencies.
+type DependencyStatus struct {
+	Name   string
+	Kind   string
+	Target string
+	State  string
+	// Healthy is whether the dependency is ready for compost's calls.
+	Healthy bool
+	// LastError is the dependency's most recent error, which may be from
+	// before it recovered, and LastErrorTime is when it happened.
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// Health checks the backends compost calls. gRPC connections report the state
+// they're in, and http and etcd backends are probed each time Status is
+// called. It is safe for concurrent use, and a nil Health has no dependencies.
+type Health struct {
+	timeout time.Duration
+	client  *http.Client
+
+	mut          sync.Mutex
+	dependencies []*dependency
+}
+
+// dependency is a backend checked by Health, and its last error.
+type dependency struct {
+	name   string
+	kind   string
+	target string
+	// check returns the dependency's state, whether it's healthy, and the
+	// error that left it unhealthy, if any.
+	check func(ctx context.Context) (string, bool, error)
+
+	mut           sync.Mutex
+	lastError     string
+	lastErrorTime time.Time
+}
+
+func NewHealth(timeout time.Duration, tlsConfig *tls.Config) *Health {
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+
+	return &Health{
+		timeout: timeout,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}
+}
+
+// DialOptions returns the options that make grpc.Dial report the state of
+// the connection to the dependency called name.
+func (h *Health) DialOptions(name, addr string, creds credentials.TransportCredentials) []grpc.DialOption {
+	tracker := &connTracker{state: grpc.Idle}
+	d := h.add(name, DependencyGRPC, addr, func(ctx context.Context) (string, bool, error) {
+		return tracker.status()
+	})
+	tracker.dependency = d
+
+	return []grpc.DialOption{
+		grpc.WithDialer(tracker.dial),
+		grpc.WithTransportCredentials(&trackingCredentials{creds, tracker}),
+	}
+}
+
+// AddHTTP checks the http service at url by requesting its /health endpoint,
+// which every opsee service serves.
+func (h *Health) AddHTTP(name, url string) {
+	healthURL := strings.TrimSuffix(url, "/") + "/health"
+
+	h.add(name, DependencyHTTP, url, func(ctx context.Context) (string, bool, error) {
+		req, err := http.NewRequest("GET", healthURL, nil)
+		if err != nil {
+			return StateUnreachable, false, err
+		}
+
+		resp, err := h.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return StateUnreachable, false, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			return StateReachable, false, fmt.Errorf("GET %s: %s", healthURL, resp.Status)
+		}
+
+		return StateReachable, true, nil
+	})
+}
+
+// AddEtcd checks etcd by getting its root key.
+func (h *Health) AddEtcd(name, url string, keys etcd.KeysAPI) {
+	h.add(name, DependencyEtcd, url, func(ctx context.Context) (string, bool, error) {
+		if _, err := keys.Get(ctx, "/", nil); err != nil {
+			return StateUnreachable, false, err
+		}
+		return StateReachable, true, nil
+	})
+}
+
+func (h *Health) add(name, kind, target string, check func(context.Context) (string, bool, error)) *dependency {
+	d := &dependency{name: name, kind: kind, target: target, check: check}
+
+	h.mut.Lock()
+	h.dependencies = append(h.dependencies, d)
+	h.mut.Unlock()
+
+	return d
+}
+
+// Status checks every dependency at once and returns their statuses, in the
+// order they were added.
+func (h *Health) Status(ctx context.Context) []DependencyStatus {
+	if h == nil {
+		return nil
+	}
+
+	h.mut.Lock()
+	dependencies := append([]*dependency(nil), h.dependencies...)
+	h.mut.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var (
+		statuses = make([]DependencyStatus, len(dependencies))
+		wg       sync.WaitGroup
+	)
+
+	for i, d := range dependencies {
+		wg.Add(1)
+		go func(i int, d *dependency) {
+			defer wg.Done()
+			statuses[i] = d.status(ctx)
+		}(i, d)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// Ready returns whether every dependency in statuses is healthy.
+func Ready(statuses []DependencyStatus) bool {
+	for _, s := range statuses {
+		if !s.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *dependency) status(ctx context.Context) DependencyStatus {
+	state, healthy, err := d.check(ctx)
+	if err != nil {
+		d.recordError(err)
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	return DependencyStatus{
+		Name:          d.name,
+		Kind:          d.kind,
+		Target:        d.target,
+		State:         state,
+		Healthy:       healthy,
+		LastError:     d.lastError,
+		LastErrorTime: d.lastErrorTime,
+	}
+}
+
+func (d *dependency) recordError(err error) {
+	d.mut.Lock()
+	d.lastError = err.Error()
+	d.lastErrorTime = time.Now()
+	d.mut.Unlock()
+}
+
+// connTracker follows the state of a gRPC connection through its dialer,
+// credentials and net.Conn, since this version of grpc doesn't expose it.
+type connTracker struct {
+	dependency *dependency
+
+	mut   sync.Mutex
+	state grpc.ConnectivityState
+}
+
+// status reports the connection's state. Its errors are recorded as they
+// happen, so none is returned.
+func (t *connTracker) status() (string, bool, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return t.state.String(), connStateHealthy(t.state), nil
+}
+
+// connStateHealthy returns whether a connection in state is healthy. Only a
+// failed or shut down connection isn't: an idle one, as connections are
+// before their first call or after a while without calls, connects when it's
+// next used, and a connecting one may yet connect.
+func connStateHealthy(state grpc.ConnectivityState) bool {
+	switch state {
+	case grpc.TransientFailure, grpc.Shutdown:
+		return false
+	}
+	return true
+}
+
+func (t *connTracker) set(state grpc.ConnectivityState, err error) {
+	t.mut.Lock()
+	t.state = state
+	t.mut.Unlock()
+
+	if err != nil {
+		t.dependency.recordError(err)
+	}
+}
+
+func (t *connTracker) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	t.set(grpc.Connecting, nil)
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		t.set(grpc.TransientFailure, err)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// trackingCredentials marks a connection ready once its handshake succeeds.
+type trackingCredentials struct {
+	credentials.TransportCredentials
+	tracker *connTracker
+}
+
+func (c *trackingCredentials) ClientHandshake(addr string, rawConn net.Conn, timeout time.Duration) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ClientHandshake(addr, rawConn, timeout)
+	if err != nil {
+		c.tracker.set(grpc.TransientFailure, err)
+		return conn, info, err
+	}
+
+	c.tracker.set(grpc.Ready, nil)
+	return &trackedConn{Conn: conn, tracker: c.tracker}, info, nil
+}
+
+// trackedConn marks its connection failed if reading from it fails, or idle
+// once grpc closes it.
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+
+	mut    sync.Mutex
+	closed bool
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.mut.Lock()
+		closed := c.closed
+		c.closed = true
+		c.mut.Unlock()
+
+		if !closed {
+			c.tracker.set(grpc.TransientFailure, err)
+		}
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.mut.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mut.Unlock()
+
+	if !closed {
+		c.tracker.set(grpc.Idle, nil)
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,100 @@
+package resolver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type downEtcd struct {
+	etcd.KeysAPI
+}
+
+func (e downEtcd) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	return nil, errors.New("etcd is down")
+}
+
+func TestHealth(t *testing.T) {
+	var nilHealth *Health
+	assert.Empty(t, nilHealth.Status(context.Background()))
+	assert.True(t, Ready(nil))
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// nothing listens on a port just released
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	health := NewHealth(time.Second, &tls.Config{})
+	health.AddHTTP(BackendBartnet, healthy.URL)
+	health.AddHTTP(BackendHugs, failing.URL+"/")
+	health.AddEtcd(BackendEtcd, "http://etcd", downEtcd{})
+
+	conn, err := grpc.Dial(closedAddr, health.DialOptions(BackendCats, closedAddr, credentials.NewTLS(&tls.Config{}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var statuses []DependencyStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		statuses = health.Status(context.Background())
+		if statuses[3].State == grpc.TransientFailure.String() {
+			break
+		}
+	}
+
+	assert.False(t, Ready(statuses))
+	if assert.Len(t, statuses, 4) {
+		assert.Equal(t, DependencyStatus{Name: BackendBartnet, Kind: DependencyHTTP, Target: healthy.URL, State: StateReachable, Healthy: true}, statuses[0])
+
+		assert.Equal(t, StateReachable, statuses[1].State)
+		assert.False(t, statuses[1].Healthy)
+		assert.Contains(t, statuses[1].LastError, "500")
+
+		assert.Equal(t, StateUnreachable, statuses[2].State)
+		assert.Equal(t, "etcd is down", statuses[2].LastError)
+		assert.False(t, statuses[2].LastErrorTime.IsZero())
+
+		assert.Equal(t, DependencyGRPC, statuses[3].Kind)
+		assert.Equal(t, grpc.TransientFailure.String(), statuses[3].State)
+		assert.Contains(t, statuses[3].LastError, "refused")
+	}
+}
+
+func TestConnStateHealthy(t *testing.T) {
+	for state, healthy := range map[grpc.ConnectivityState]bool{
+		grpc.Idle:             true,
+		grpc.Connecting:       true,
+		grpc.Ready:            true,
+		grpc.TransientFailure: false,
+		grpc.Shutdown:         false,
+	} {
+		tracker := &connTracker{dependency: &dependency{}, state: state}
+		_, ok, err := tracker.status()
+		assert.NoError(t, err)
+		assert.Equal(t, healthy, ok, state.String())
+	}
+}
@@ -0,0 +1,319 @@
+package resolver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/opsee/basic/clients/bartnet"
+	"github.com/opsee/basic/clients/beavis"
+	"github.com/opsee/basic/clients/hugs"
+	"github.com/opsee/basic/schema"
+	opsee "github.com/opsee/basic/service"
+	"github.com/opsee/compost/tracing"
+	"golang.org/x/net/context"
+)
+
+// The clients of the HTTP backends speak the same protocol as those in
+// opsee/basic/clients, but make each request with the context of the call, so
+// that tracingTransport can send its span along. The backend wrappers bind
+// them to the call's context with withContext.
+
+// tracingTransport sets the traceparent header of each request to the span in
+// the request's context.
+type tracingTransport struct {
+	http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper mustn't modify the request it's given
+	traced := new(http.Request)
+	*traced = *req
+	traced.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		traced.Header[k] = v
+	}
+
+	tracing.InjectHTTP(req.Context(), traced.Header)
+	return t.RoundTripper.RoundTrip(traced)
+}
+
+func newTracingHTTPClient() *http.Client {
+	return &http.Client{Transport: tracingTransport{http.DefaultTransport}}
+}
+
+// httpClient makes the requests of one HTTP backend, which fails them with a
+// status of errorStatus or more.
+type httpClient struct {
+	name        string
+	client      *http.Client
+	endpoint    string
+	errorStatus int
+	ctx         context.Context
+}
+
+func (c httpClient) do(user *schema.User, method, accept, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+
+	toke, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString(toke)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode >= c.errorStatus {
+		return nil, fmt.Errorf("%s responded with error status: %s", c.name, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+type bartnetClient struct {
+	httpClient
+}
+
+func newBartnetClient(endpoint string) *bartnetClient {
+	return &bartnetClient{httpClient{name: BackendBartnet, client: newTracingHTTPClient(), endpoint: endpoint, errorStatus: 400}}
+}
+
+func (c *bartnetClient) withContext(ctx context.Context) bartnet.Client {
+	bound := *c
+	bound.ctx = ctx
+	return &bound
+}
+
+func (c *bartnetClient) GetCheck(user *schema.User, id string) (*schema.Check, error) {
+	if id == "" {
+		return nil, fmt.Errorf("can't get check without an id")
+	}
+
+	body, err := c.do(user, "GET", "application/x-protobuf", fmt.Sprintf("/gql/checks/%s", id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &schema.Check{}
+	if err := proto.Unmarshal(body, check); err != nil {
+		return nil, err
+	}
+
+	return check, nil
+}
+
+func (c *bartnetClient) ListChecks(user *schema.User) ([]*schema.Check, error) {
+	body, err := c.do(user, "GET", "application/x-protobuf", "/gql/checks", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := &opsee.CheckResourceRequest{}
+	if err := proto.Unmarshal(body, checks); err != nil {
+		return nil, err
+	}
+
+	return checks.Checks, nil
+}
+
+func (c *bartnetClient) CreateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	jsondata, err := check.MarshalCrappyJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.do(user, "POST", "application/x-protobuf", "/checks", bytes.NewBuffer(jsondata))
+	if err != nil {
+		return nil, err
+	}
+
+	checks := &opsee.CheckResourceRequest{}
+	if err := proto.Unmarshal(body, checks); err != nil {
+		return nil, err
+	}
+
+	if len(checks.Checks) < 1 {
+		return nil, fmt.Errorf("no checks returned")
+	}
+
+	return checks.Checks[0], nil
+}
+
+func (c *bartnetClient) UpdateCheck(user *schema.User, check *schema.Check) (*schema.Check, error) {
+	if check.Id == "" {
+		return nil, fmt.Errorf("can't update check without an id")
+	}
+
+	jsondata, err := check.MarshalCrappyJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.do(user, "PUT", "application/x-protobuf", fmt.Sprintf("/checks/%s", check.Id), bytes.NewBuffer(jsondata))
+	if err != nil {
+		return nil, err
+	}
+
+	updated := &schema.Check{}
+	if err := proto.Unmarshal(body, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (c *bartnetClient) DeleteCheck(user *schema.User, id string) error {
+	if id == "" {
+		return fmt.Errorf("can't delete a check without id")
+	}
+
+	_, err := c.do(user, "DELETE", "application/json", fmt.Sprintf("/checks/%s", id), nil)
+	return err
+}
+
+func (c *bartnetClient) TestCheck(user *schema.User, check *schema.Check) (*opsee.TestCheckResponse, error) {
+	checkJson, err := check.MarshalCrappyJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	testCheckJson := fmt.Sprintf(`{"max_hosts": 3, "deadline": "30s", "check": %s}`, string(checkJson))
+
+	body, err := c.do(user, "POST", "application/x-protobuf", "/bastions/test-check", bytes.NewBufferString(testCheckJson))
+	if err != nil {
+		return nil, err
+	}
+
+	testCheckResp := &opsee.TestCheckResponse{}
+	if err := proto.Unmarshal(body, testCheckResp); err != nil {
+		return nil, err
+	}
+
+	return testCheckResp, nil
+}
+
+type beavisClient struct {
+	httpClient
+}
+
+func newBeavisClient(endpoint string) *beavisClient {
+	return &beavisClient{httpClient{name: BackendBeavis, client: newTracingHTTPClient(), endpoint: endpoint, errorStatus: 400}}
+}
+
+func (c *beavisClient) withContext(ctx context.Context) beavis.Client {
+	bound := *c
+	bound.ctx = ctx
+	return &bound
+}
+
+func (c *beavisClient) ListResults(user *schema.User) ([]*schema.CheckResult, error) {
+	return c.listResults(user, fmt.Sprintf("customer_id = \"%s\" and type = \"result\"", user.CustomerId))
+}
+
+func (c *beavisClient) ListResultsCheck(user *schema.User, checkId string) ([]*schema.CheckResult, error) {
+	return c.listResults(user, fmt.Sprintf("customer_id = \"%s\" and type = \"result\" and service = \"%s\"", user.CustomerId, checkId))
+}
+
+func (c *beavisClient) ListResultsTarget(user *schema.User, targetId string) ([]*schema.CheckResult, error) {
+	return c.listResults(user, fmt.Sprintf("customer_id = \"%s\" and type = \"result\" and host = \"%s\"", user.CustomerId, targetId))
+}
+
+func (c *beavisClient) listResults(user *schema.User, query string) ([]*schema.CheckResult, error) {
+	body, err := c.do(user, "GET", "application/x-protobuf", "/gql/results?q="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &opsee.ResultsResource{}
+	if err := proto.Unmarshal(body, results); err != nil {
+		return nil, err
+	}
+
+	return results.Results, nil
+}
+
+type hugsClient struct {
+	httpClient
+}
+
+func newHugsClient(endpoint string) *hugsClient {
+	return &hugsClient{httpClient{name: BackendHugs, client: newTracingHTTPClient(), endpoint: endpoint, errorStatus: 300}}
+}
+
+func (c *hugsClient) withContext(ctx context.Context) hugs.Client {
+	bound := *c
+	bound.ctx = ctx
+	return &bound
+}
+
+func (c *hugsClient) ListNotifications(user *schema.User) ([]*hugs.Notification, error) {
+	return c.listNotifications(user, "/notifications")
+}
+
+func (c *hugsClient) ListNotificationsDefault(user *schema.User) ([]*hugs.Notification, error) {
+	return c.listNotifications(user, "/notifications-default")
+}
+
+func (c *hugsClient) ListNotificationsCheck(user *schema.User, checkId string) ([]*hugs.Notification, error) {
+	return c.listNotifications(user, fmt.Sprintf("/notifications/%s", checkId))
+}
+
+func (c *hugsClient) listNotifications(user *schema.User, path string) ([]*hugs.Notification, error) {
+	body, err := c.do(user, "GET", "application/json", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications *hugs.NotificationResponse
+	if err := json.Unmarshal(body, &notifications); err != nil {
+		return nil, err
+	}
+
+	if notifications == nil {
+		return nil, nil
+	}
+
+	return notifications.Notifications, nil
+}
+
+func (c *hugsClient) CreateNotifications(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	return c.createNotifications(user, "/notifications", noteReq)
+}
+
+func (c *hugsClient) CreateNotificationsDefault(user *schema.User, noteReq *hugs.NotificationRequest) error {
+	return c.createNotifications(user, "/notifications-default", noteReq)
+}
+
+func (c *hugsClient) CreateNotificationsMulti(user *schema.User, noteReq []*hugs.NotificationRequest) error {
+	return c.createNotifications(user, "/notifications-multicheck", noteReq)
+}
+
+func (c *hugsClient) createNotifications(user *schema.User, path string, noteReq interface{}) error {
+	reqBody, err := json.Marshal(noteReq)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(user, "POST", "application/json", path, bytes.NewBuffer(reqBody))
+	return err
+}
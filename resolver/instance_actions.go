@@ -14,6 +14,13 @@ func (c *Client) RebootInstances(ctx context.Context, user *schema.User, region
 	})
 	logger.Info("reboot instances request")
 
+	release, err := c.RateLimiter.acquire(ctx, user.CustomerId, RateLimitMutations)
+	if err != nil {
+		logger.WithError(err).Warn("rate limited")
+		return err
+	}
+	defer release()
+
 	session, err := c.awsSession(ctx, user, region)
 	if err != nil {
 		logger.WithError(err).Error("error acquiring aws session")
@@ -29,6 +36,8 @@ func (c *Client) RebootInstances(ctx context.Context, user *schema.User, region
 		return err
 	}
 
+	c.Cache.Invalidate(user.CustomerId, region)
+
 	return nil
 }
 
@@ -38,6 +47,13 @@ func (c *Client) StartInstances(ctx context.Context, user *schema.User, region s
 	})
 	logger.Info("start instances request")
 
+	release, err := c.RateLimiter.acquire(ctx, user.CustomerId, RateLimitMutations)
+	if err != nil {
+		logger.WithError(err).Warn("rate limited")
+		return err
+	}
+	defer release()
+
 	session, err := c.awsSession(ctx, user, region)
 	if err != nil {
 		logger.WithError(err).Error("error acquiring aws session")
@@ -53,6 +69,8 @@ func (c *Client) StartInstances(ctx context.Context, user *schema.User, region s
 		return err
 	}
 
+	c.Cache.Invalidate(user.CustomerId, region)
+
 	return nil
 }
 
@@ -62,6 +80,13 @@ func (c *Client) StopInstances(ctx context.Context, user *schema.User, region st
 	})
 	logger.Info("stop instances request")
 
+	release, err := c.RateLimiter.acquire(ctx, user.CustomerId, RateLimitMutations)
+	if err != nil {
+		logger.WithError(err).Warn("rate limited")
+		return err
+	}
+	defer release()
+
 	session, err := c.awsSession(ctx, user, region)
 	if err != nil {
 		logger.WithError(err).Error("error acquiring aws session")
@@ -77,5 +102,7 @@ func (c *Client) StopInstances(ctx context.Context, user *schema.User, region st
 		return err
 	}
 
+	c.Cache.Invalidate(user.CustomerId, region)
+
 	return nil
 }
@@ -12,29 +12,40 @@ import (
 	"golang.org/x/net/context"
 )
 
-func (c *Client) GetInstances(ctx context.Context, user *schema.User, region, vpc, instanceType, instanceId string) (interface{}, error) {
+func (c *Client) GetInstances(ctx context.Context, user *schema.User, region, vpc, instanceType, instanceId string, filter ResourceFilter) (interface{}, error) {
 	log.WithFields(log.Fields{
 		"customer_id": user.CustomerId,
 	}).Info("get instances request")
 
+	var (
+		instances interface{}
+		err       error
+	)
+
 	switch instanceType {
 	case "ec2":
-		return c.getInstancesEc2(ctx, user, region, vpc, instanceId)
+		instances, err = c.getInstancesEc2(ctx, user, region, vpc, instanceId, filter)
 	case "rds":
-		return c.getInstancesRds(ctx, user, region, vpc, instanceId)
+		instances, err = c.getInstancesRds(ctx, user, region, vpc, instanceId)
+	default:
+		return nil, Errorf(ErrorInvalidInput, "instance type not known: %s", instanceType)
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("instance type not known: %s", instanceType), nil
+	return filterResources(instances, filter), nil
 }
 
-func (c *Client) getInstancesEc2(ctx context.Context, user *schema.User, region, vpc, instanceId string) ([]*opsee_aws_ec2.Instance, error) {
+func (c *Client) getInstancesEc2(ctx context.Context, user *schema.User, region, vpc, instanceId string, filter ResourceFilter) ([]*opsee_aws_ec2.Instance, error) {
 	input := &opsee_aws_ec2.DescribeInstancesInput{
-		Filters: []*opsee_aws_ec2.Filter{
+		Filters: append([]*opsee_aws_ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpc},
 			},
-		},
+		}, filter.ec2InstanceFilters()...),
 	}
 
 	if instanceId != "" {
@@ -0,0 +1,43 @@
+package resolver
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// backendCallOK is the code label of backend calls that succeeded.
+const backendCallOK = "OK"
+
+var (
+	backendCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "compost",
+		Subsystem: "backend",
+		Name:      "calls_total",
+		Help:      "Calls to compost's backends, by backend, method and the code of the error returned, or OK.",
+	}, []string{"backend", "method", "code"})
+
+	backendCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "compost",
+		Subsystem: "backend",
+		Name:      "call_duration_seconds",
+		Help:      "How long calls to compost's backends took, including those that failed or timed out.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(backendCalls, backendCallDuration)
+}
+
+// observeCall records a call to method of backend that started at start and
+// returned err.
+func observeCall(backend, method string, start time.Time, err error) {
+	code := backendCallOK
+	if err != nil {
+		code = string(ErrorOf(err).Code)
+	}
+
+	backendCalls.WithLabelValues(backend, method, code).Inc()
+	backendCallDuration.WithLabelValues(backend, method).Observe(time.Since(start).Seconds())
+}
@@ -101,6 +101,13 @@ func (c *Client) ScanRegion(ctx context.Context, user *schema.User, region strin
 	})
 	logger.Info("scan region request")
 
+	release, err := c.RateLimiter.acquire(ctx, user.CustomerId, RateLimitMutations)
+	if err != nil {
+		logger.WithError(err).Warn("rate limited")
+		return nil, err
+	}
+	defer release()
+
 	resp, err := c.Keelhaul.ScanVpcs(ctx, &opsee.ScanVpcsRequest{
 		User:   user,
 		Region: region,
@@ -111,6 +118,8 @@ func (c *Client) ScanRegion(ctx context.Context, user *schema.User, region strin
 		return nil, err
 	}
 
+	c.Cache.Invalidate(user.CustomerId, region)
+
 	logger.Infof("scanned region: %s", region)
 	return resp.Region, nil
 }
@@ -122,7 +131,14 @@ func (c *Client) LaunchBastionStack(ctx context.Context, user *schema.User, regi
 	})
 	logger.Info("launch bastion stack request")
 
-	_, err := c.Keelhaul.LaunchStack(ctx, &opsee.LaunchStackRequest{
+	release, err := c.RateLimiter.acquire(ctx, user.CustomerId, RateLimitMutations)
+	if err != nil {
+		logger.WithError(err).Warn("rate limited")
+		return false, err
+	}
+	defer release()
+
+	_, err = c.Keelhaul.LaunchStack(ctx, &opsee.LaunchStackRequest{
 		User:          user,
 		Region:        region,
 		VpcId:         vpcId,
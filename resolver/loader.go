@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	log "github.com/opsee/logrus"
+	"golang.org/x/net/context"
+)
+
+type loaderKeyType int
+
+const (
+	loaderKey loaderKeyType = iota
+
+	// maxLoaderConcurrency bounds the number of backend calls a Loader makes
+	// at once when loading a batch.
+	maxLoaderConcurrency = 10
+)
+
+// Loader collapses the backend calls made while resolving a single request.
+// Calls are keyed by backend method, customer, region and input: the first
+// call for a key hits the backend, and every later or concurrent call for the
+// same key shares its result. LoadBatch issues a batch of calls concurrently,
+// so that resolvers which are run one after another find their data loaded.
+//
+// A Loader lives for one request; a Client only uses one if the request
+// context carries it (see WithLoader).
+type Loader struct {
+	mut   sync.Mutex
+	calls map[string]*loaderCall
+}
+
+type loaderCall struct {
+	done     chan struct{}
+	response interface{}
+	err      error
+}
+
+// LoaderRequest is one call in a batch passed to LoadBatch.
+type LoaderRequest struct {
+	Key  string
+	Load func() (interface{}, error)
+}
+
+func NewLoader() *Loader {
+	return &Loader{
+		calls: make(map[string]*loaderCall),
+	}
+}
+
+// WithLoader returns a context whose backend calls are collapsed by loader.
+func WithLoader(ctx context.Context, loader *Loader) context.Context {
+	return context.WithValue(ctx, loaderKey, loader)
+}
+
+// loaderFromContext returns the context's Loader, or nil.
+func loaderFromContext(ctx context.Context) *Loader {
+	loader, _ := ctx.Value(loaderKey).(*Loader)
+	return loader
+}
+
+// loaderKeyFor builds a Loader key from a backend method, customer, region
+// and input. Protobuf inputs print deterministically, so equal inputs produce
+// equal keys.
+func loaderKeyFor(method, customerId, region string, input interface{}) string {
+	return fmt.Sprintf("%s|%s|%s|%v", method, customerId, region, input)
+}
+
+// Load returns the result of load for key, calling it only if no call for
+// key has been made yet. A panic in load is returned as an error to every
+// caller for key. A nil Loader simply calls load.
+func (l *Loader) Load(key string, load func() (interface{}, error)) (interface{}, error) {
+	if l == nil {
+		return load()
+	}
+
+	l.mut.Lock()
+	call, ok := l.calls[key]
+	if !ok {
+		call = &loaderCall{done: make(chan struct{})}
+		l.calls[key] = call
+	}
+	l.mut.Unlock()
+
+	if ok {
+		<-call.done
+		return call.response, call.err
+	}
+
+	call.run(key, load)
+	return call.response, call.err
+}
+
+// run calls load and closes done, even if load panics, so that callers
+// waiting on the call aren't stuck. A panic fails the call with an INTERNAL
+// error rather than taking down the request, or the process when the call
+// was made by LoadBatch.
+func (call *loaderCall) run(key string, load func() (interface{}, error)) {
+	defer close(call.done)
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("key", key).Errorf("panic loading: %v\n%s", r, debug.Stack())
+			call.response = nil
+			call.err = Errorf(ErrorInternal, "panic loading %s: %v", key, r)
+		}
+	}()
+
+	call.response, call.err = load()
+}
+
+// LoadBatch loads each request concurrently, at most maxLoaderConcurrency at
+// a time, and waits for all of them. Results are retrieved by calling Load
+// with the same keys. A nil Loader loads nothing.
+func (l *Loader) LoadBatch(requests []LoaderRequest) {
+	if l == nil {
+		return
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxLoaderConcurrency)
+	)
+
+	for _, req := range requests {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(req LoaderRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			l.Load(req.Key, req.Load)
+		}(req)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderDedupe(t *testing.T) {
+	var (
+		loader = NewLoader()
+		calls  int32
+		load   = func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return "ok", nil
+		}
+	)
+
+	loader.LoadBatch([]LoaderRequest{
+		{Key: "a", Load: load},
+		{Key: "a", Load: load},
+		{Key: "b", Load: load},
+	})
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	resp, err := loader.Load("a", load)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	var nilLoader *Loader
+	nilLoader.Load("a", load)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestLoaderPanic(t *testing.T) {
+	var (
+		loader = NewLoader()
+		calls  int32
+		load   = func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			panic("boom")
+		}
+	)
+
+	loader.LoadBatch([]LoaderRequest{
+		{Key: "a", Load: load},
+		{Key: "a", Load: load},
+	})
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	resp, err := loader.Load("a", load)
+	assert.Nil(t, resp)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrorInternal, ErrorOf(err).Code)
+		assert.Contains(t, err.Error(), "boom")
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -12,6 +13,31 @@ import (
 	"golang.org/x/net/context"
 )
 
+// The CloudWatch statistics GetMetricStatistics returns. Percentiles, such
+// as p99, are requested as ExtendedStatistics instead.
+const (
+	StatisticAverage     = "Average"
+	StatisticSum         = "Sum"
+	StatisticMinimum     = "Minimum"
+	StatisticMaximum     = "Maximum"
+	StatisticSampleCount = "SampleCount"
+)
+
+var MetricStatistics = []string{StatisticAverage, StatisticSum, StatisticMinimum, StatisticMaximum, StatisticSampleCount}
+
+// MaxPercentiles is the most percentiles GetMetricStatistics returns at once.
+const MaxPercentiles = 10
+
+// percentilePattern matches the percentiles CloudWatch accepts, p0 to p100
+// with at most two decimal places.
+var percentilePattern = regexp.MustCompile(`^p(\d{1,2}(\.\d{1,2})?|100)$`)
+
+// IsPercentile returns whether statistic is a percentile CloudWatch accepts
+// as an extended statistic, such as p99 or p99.9.
+func IsPercentile(statistic string) bool {
+	return percentilePattern.MatchString(statistic)
+}
+
 type metricList []*schema.Metric
 
 func (l metricList) Len() int           { return len(l) }
@@ -19,6 +45,41 @@ func (l metricList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 func (l metricList) Less(i, j int) bool { return l[i].Timestamp.Millis() < l[j].Timestamp.Millis() }
 
 func (c *Client) GetMetricStatistics(ctx context.Context, user *schema.User, region string, input *opsee_aws_cloudwatch.GetMetricStatisticsInput) (*schema.CloudWatchResponse, error) {
+	resp, err := loaderFromContext(ctx).Load(metricStatisticsKey(user, region, input), func() (interface{}, error) {
+		return c.getMetricStatistics(ctx, user, region, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*schema.CloudWatchResponse), nil
+}
+
+// PrefetchMetricStatistics loads the statistics for each of the inputs
+// concurrently into the request's Loader, so that subsequent calls to
+// GetMetricStatistics with the same inputs don't wait on Bezos. It does
+// nothing if the context has no Loader. Errors are returned by those later
+// calls.
+func (c *Client) PrefetchMetricStatistics(ctx context.Context, user *schema.User, region string, inputs []*opsee_aws_cloudwatch.GetMetricStatisticsInput) {
+	requests := make([]LoaderRequest, len(inputs))
+	for i, input := range inputs {
+		input := input
+		requests[i] = LoaderRequest{
+			Key: metricStatisticsKey(user, region, input),
+			Load: func() (interface{}, error) {
+				return c.getMetricStatistics(ctx, user, region, input)
+			},
+		}
+	}
+
+	loaderFromContext(ctx).LoadBatch(requests)
+}
+
+func metricStatisticsKey(user *schema.User, region string, input *opsee_aws_cloudwatch.GetMetricStatisticsInput) string {
+	return loaderKeyFor("cloudwatch.GetMetricStatistics", user.CustomerId, region, input)
+}
+
+func (c *Client) getMetricStatistics(ctx context.Context, user *schema.User, region string, input *opsee_aws_cloudwatch.GetMetricStatisticsInput) (*schema.CloudWatchResponse, error) {
 	resp, err := c.Bezos.Get(ctx, &opsee.BezosRequest{User: user, Region: region, VpcId: "global", Input: &opsee.BezosRequest_Cloudwatch_GetMetricStatisticsInput{input}})
 	if err != nil {
 		return nil, err
@@ -29,24 +90,36 @@ func (c *Client) GetMetricStatistics(ctx context.Context, user *schema.User, reg
 		return nil, fmt.Errorf("error decoding aws response")
 	}
 
-	metrics := make([]*schema.Metric, len(output.Datapoints))
-	for i, d := range output.Datapoints {
-		var statistic string
-		if len(input.Statistics) > 0 {
-			statistic = input.Statistics[0]
-		}
+	// each statistic is its own series, in the order they were requested,
+	// followed by the percentiles
+	statistics := make([]string, 0, len(input.Statistics)+len(input.ExtendedStatistics))
+	statistics = append(statistics, input.Statistics...)
+	statistics = append(statistics, input.ExtendedStatistics...)
+	if len(statistics) == 0 {
+		statistics = []string{StatisticAverage}
+	}
 
-		metrics[i] = &schema.Metric{
-			Name: aws.StringValue(input.MetricName),
-			// we really need support for other things?
-			Value:     aws.Float64Value(d.Average),
-			Timestamp: d.Timestamp,
-			Unit:      aws.StringValue(d.Unit),
-			Statistic: statistic,
+	var metrics []*schema.Metric
+	for _, statistic := range statistics {
+		series := make([]*schema.Metric, 0, len(output.Datapoints))
+		for _, d := range output.Datapoints {
+			value := statisticValue(d, statistic)
+			if value == nil {
+				continue
+			}
+
+			series = append(series, &schema.Metric{
+				Name:      aws.StringValue(input.MetricName),
+				Value:     *value,
+				Timestamp: d.Timestamp,
+				Unit:      aws.StringValue(d.Unit),
+				Statistic: statistic,
+			})
 		}
-	}
 
-	sort.Sort(metricList(metrics))
+		sort.Sort(metricList(series))
+		metrics = append(metrics, series...)
+	}
 
 	return &schema.CloudWatchResponse{
 		Namespace: aws.StringValue(input.Namespace),
@@ -54,6 +127,73 @@ func (c *Client) GetMetricStatistics(ctx context.Context, user *schema.User, reg
 	}, nil
 }
 
+// statisticValue returns the datapoint's value of statistic, or nil if it
+// doesn't have one.
+func statisticValue(d *opsee_aws_cloudwatch.Datapoint, statistic string) *float64 {
+	switch statistic {
+	case StatisticAverage:
+		return d.Average
+	case StatisticSum:
+		return d.Sum
+	case StatisticMinimum:
+		return d.Minimum
+	case StatisticMaximum:
+		return d.Maximum
+	case StatisticSampleCount:
+		return d.SampleCount
+	}
+
+	if value, ok := d.ExtendedStatistics[statistic]; ok {
+		return &value
+	}
+	return nil
+}
+
+// ListMetrics returns the metrics in the namespace that have the dimensions,
+// paging through every result. A dimension without a value matches any
+// value.
+func (c *Client) ListMetrics(ctx context.Context, user *schema.User, region, namespace string, dimensions []*opsee_aws_cloudwatch.DimensionFilter) ([]*opsee_aws_cloudwatch.Metric, error) {
+	input := &opsee_aws_cloudwatch.ListMetricsInput{
+		Namespace:  aws.String(namespace),
+		Dimensions: dimensions,
+	}
+
+	resp, err := loaderFromContext(ctx).Load(loaderKeyFor("cloudwatch.ListMetrics", user.CustomerId, region, input), func() (interface{}, error) {
+		return c.listMetrics(ctx, user, region, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.([]*opsee_aws_cloudwatch.Metric), nil
+}
+
+func (c *Client) listMetrics(ctx context.Context, user *schema.User, region string, input *opsee_aws_cloudwatch.ListMetricsInput) ([]*opsee_aws_cloudwatch.Metric, error) {
+	var metrics []*opsee_aws_cloudwatch.Metric
+
+	for {
+		resp, err := c.Bezos.Get(ctx, &opsee.BezosRequest{User: user, Region: region, VpcId: "global", Input: &opsee.BezosRequest_Cloudwatch_ListMetricsInput{input}})
+		if err != nil {
+			return nil, err
+		}
+
+		output := resp.GetCloudwatch_ListMetricsOutput()
+		if output == nil {
+			return nil, fmt.Errorf("error decoding aws response")
+		}
+
+		metrics = append(metrics, output.Metrics...)
+
+		if output.NextToken == nil {
+			return metrics, nil
+		}
+
+		next := *input
+		next.NextToken = output.NextToken
+		input = &next
+	}
+}
+
 func (c *Client) QueryCheckMetrics(ctx context.Context, user *schema.User, checkId, metricName string, ts0, ts1 *opsee_types.Timestamp, aggregator *opsee.Aggregator) ([]*schema.Metric, error) {
 	req := &opsee.QueryMetricsRequest{
 		Metrics: []*opsee.QueryMetric{
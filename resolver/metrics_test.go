@@ -0,0 +1,115 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/opsee/basic/schema"
+	opsee_aws_cloudwatch "github.com/opsee/basic/schema/aws/cloudwatch"
+	opsee "github.com/opsee/basic/service"
+	opsee_types "github.com/opsee/protobuf/opseeproto/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+type datapointsBezos struct {
+	datapoints []*opsee_aws_cloudwatch.Datapoint
+}
+
+func (b *datapointsBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	return &opsee.BezosResponse{
+		Output: &opsee.BezosResponse_Cloudwatch_GetMetricStatisticsOutput{
+			&opsee_aws_cloudwatch.GetMetricStatisticsOutput{Datapoints: b.datapoints},
+		},
+	}, nil
+}
+
+func TestGetMetricStatistics(t *testing.T) {
+	var datapoints []*opsee_aws_cloudwatch.Datapoint
+	for _, i := range []int{1, 0} {
+		ts := &opsee_types.Timestamp{}
+		ts.Scan(1464782400000 + 60000*i)
+		datapoints = append(datapoints, &opsee_aws_cloudwatch.Datapoint{
+			Average:   aws.Float64(float64(10 + i)),
+			Maximum:   aws.Float64(float64(90 + i)),
+			Timestamp: ts,
+			Unit:      aws.String("Percent"),
+		})
+	}
+
+	client := &Client{Bezos: &datapointsBezos{datapoints}}
+	resp, err := client.GetMetricStatistics(context.Background(), &schema.User{CustomerId: "customer-1"}, "us-west-2", &opsee_aws_cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/EC2"),
+		MetricName: aws.String("CPUUtilization"),
+		Statistics: []string{StatisticMaximum, StatisticAverage, StatisticSum},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the series are in the order requested, each sorted by time, and the
+	// datapoints have no sums
+	var values []float64
+	var statistics []string
+	for _, m := range resp.Metrics {
+		values = append(values, m.Value)
+		statistics = append(statistics, m.Statistic)
+	}
+
+	assert.Equal(t, []float64{90, 91, 10, 11}, values)
+	assert.Equal(t, []string{StatisticMaximum, StatisticMaximum, StatisticAverage, StatisticAverage}, statistics)
+}
+
+func TestGetMetricStatisticsPercentiles(t *testing.T) {
+	ts := &opsee_types.Timestamp{}
+	ts.Scan(1464782400000)
+
+	client := &Client{Bezos: &datapointsBezos{[]*opsee_aws_cloudwatch.Datapoint{{
+		Average:            aws.Float64(10),
+		ExtendedStatistics: map[string]float64{"p99": 95, "p50": 12},
+		Timestamp:          ts,
+	}}}}
+
+	resp, err := client.GetMetricStatistics(context.Background(), &schema.User{CustomerId: "customer-1"}, "us-west-2", &opsee_aws_cloudwatch.GetMetricStatisticsInput{
+		Namespace:          aws.String("AWS/EC2"),
+		MetricName:         aws.String("CPUUtilization"),
+		Statistics:         []string{StatisticAverage},
+		ExtendedStatistics: []string{"p99", "p50", "p90"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// percentiles follow the statistics, and ones without values are left out
+	var values []float64
+	var statistics []string
+	for _, m := range resp.Metrics {
+		values = append(values, m.Value)
+		statistics = append(statistics, m.Statistic)
+	}
+
+	assert.Equal(t, []float64{10, 95, 12}, values)
+	assert.Equal(t, []string{StatisticAverage, "p99", "p50"}, statistics)
+
+	// percentiles survive the trip through bezos
+	data, err := (&opsee_aws_cloudwatch.GetMetricStatisticsInput{ExtendedStatistics: []string{"p99.9"}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := &opsee_aws_cloudwatch.GetMetricStatisticsInput{}
+	if assert.NoError(t, input.Unmarshal(data)) {
+		assert.Equal(t, []string{"p99.9"}, input.ExtendedStatistics)
+	}
+
+	data, err = (&opsee_aws_cloudwatch.Datapoint{ExtendedStatistics: map[string]float64{"p99.9": 1.5}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datapoint := &opsee_aws_cloudwatch.Datapoint{}
+	if assert.NoError(t, datapoint.Unmarshal(data)) {
+		assert.Equal(t, map[string]float64{"p99.9": 1.5}, datapoint.ExtendedStatistics)
+	}
+}
@@ -0,0 +1,354 @@
+package resolver
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	opsee "github.com/opsee/basic/service"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Rate limit kinds. Reads are the AWS calls made through bezos, describes
+// and GetMetricStatistics, and mutations are the calls that change a
+// customer's AWS resources: instance actions, region scans and stack
+// launches.
+const (
+	RateLimitReads     = "reads"
+	RateLimitMutations = "mutations"
+)
+
+// RateLimitKinds are the kinds of AWS calls limited separately.
+var RateLimitKinds = []string{RateLimitReads, RateLimitMutations}
+
+// RateLimit bounds one kind of a customer's AWS calls. Calls are started at
+// Rate per second on average, in bursts of at most Burst, and at most
+// Concurrency of them run at once. Calls over the rate wait for a token and
+// calls over the concurrency wait for one to finish. A zero Rate or
+// Concurrency is not enforced.
+type RateLimit struct {
+	Rate        float64
+	Burst       int
+	Concurrency int
+}
+
+// RateLimitConfig holds the limits of each kind of AWS call, which apply to
+// each customer separately.
+type RateLimitConfig struct {
+	Reads     RateLimit
+	Mutations RateLimit
+}
+
+// DefaultRateLimits are the limits compost runs with unless configured
+// otherwise.
+var DefaultRateLimits = RateLimitConfig{
+	Reads:     RateLimit{Rate: 20, Burst: 40, Concurrency: 10},
+	Mutations: RateLimit{Rate: 1, Burst: 5, Concurrency: 2},
+}
+
+// RateLimitUsage is a snapshot of one kind of a customer's AWS calls.
+type RateLimitUsage struct {
+	CustomerId string
+	Kind       string
+	Limit      RateLimit
+	// Tokens is how many calls may start now without waiting for the
+	// bucket to refill. It's negative while calls are waiting for tokens.
+	Tokens   float64
+	InFlight int
+	// Limited counts the calls rejected for exceeding the rate, because
+	// their request would be done before a token was, or for exceeding the
+	// concurrency until their request was done.
+	Limited int64
+}
+
+// rateBucketSweepInterval is how often the RateLimiter drops the buckets of
+// customers that have stopped making calls.
+const rateBucketSweepInterval = time.Minute
+
+// RateLimiter limits the AWS calls made on each customer's credentials, so
+// that compost can't use up a customer's AWS API quota. It is safe for
+// concurrent use, and a nil RateLimiter limits nothing.
+type RateLimiter struct {
+	limits map[string]RateLimit
+
+	mut     sync.Mutex
+	buckets map[string]*rateBucket
+	swept   time.Time
+}
+
+// rateBucket is a token bucket, plus the calls in flight, for one kind of a
+// customer's calls.
+type rateBucket struct {
+	customerId string
+	kind       string
+	limit      RateLimit
+	tokens     float64
+	updated    time.Time
+	slots      chan struct{}
+	inFlight   int
+	// calls counts the calls in flight or waiting to be.
+	calls   int
+	limited int64
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		limits: map[string]RateLimit{
+			RateLimitReads:     config.Reads,
+			RateLimitMutations: config.Mutations,
+		},
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// acquire takes a call of kind from the customer's quota, returning a func
+// that must be called once the call is done. A call over the rate waits for
+// a token, and fails with a RATE_LIMITED error at once if ctx's deadline
+// would pass first, or once ctx is done while it waits for a token or for a
+// call in flight to finish.
+func (r *RateLimiter) acquire(ctx context.Context, customerId, kind string) (func(), error) {
+	if r == nil {
+		return func() {}, nil
+	}
+
+	now := time.Now()
+
+	r.mut.Lock()
+	r.sweep(now)
+	bucket := r.bucket(customerId, kind)
+	wait := bucket.take(now)
+	if deadline, ok := ctx.Deadline(); ok && wait > 0 && now.Add(wait).After(deadline) {
+		bucket.reject()
+		r.mut.Unlock()
+		return nil, rateLimitedError(kind, wait)
+	}
+	bucket.calls++
+	r.mut.Unlock()
+
+	// reject gives back the call's token and stops counting it.
+	reject := func() {
+		r.mut.Lock()
+		bucket.reject()
+		bucket.calls--
+		r.mut.Unlock()
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			reject()
+			return nil, rateLimitedError(kind, wait)
+		}
+	}
+
+	if bucket.slots == nil {
+		r.mut.Lock()
+		bucket.calls--
+		r.mut.Unlock()
+		return func() {}, nil
+	}
+
+	select {
+	case bucket.slots <- struct{}{}:
+	case <-ctx.Done():
+		reject()
+		return nil, &Error{
+			Code:       ErrorRateLimited,
+			Retryable:  true,
+			RetryAfter: time.Second,
+			Message:    fmt.Sprintf("too many concurrent AWS %s for this customer: %s", kind, ctx.Err()),
+		}
+	}
+
+	r.mut.Lock()
+	bucket.inFlight++
+	r.mut.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mut.Lock()
+			bucket.inFlight--
+			bucket.calls--
+			r.mut.Unlock()
+			<-bucket.slots
+		})
+	}, nil
+}
+
+func rateLimitedError(kind string, wait time.Duration) error {
+	return &Error{
+		Code:       ErrorRateLimited,
+		Retryable:  true,
+		RetryAfter: wait,
+		Message:    fmt.Sprintf("too many AWS %s for this customer, retry in %s", kind, wait),
+	}
+}
+
+// bucket returns the customer's bucket of kind, creating it full.
+func (r *RateLimiter) bucket(customerId, kind string) *rateBucket {
+	key := customerId + "|" + kind
+	if bucket, ok := r.buckets[key]; ok {
+		return bucket
+	}
+
+	limit := r.limits[kind]
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	bucket := &rateBucket{
+		customerId: customerId,
+		kind:       kind,
+		limit:      limit,
+		tokens:     float64(limit.Burst),
+		updated:    time.Now(),
+	}
+
+	if limit.Concurrency > 0 {
+		bucket.slots = make(chan struct{}, limit.Concurrency)
+	}
+
+	r.buckets[key] = bucket
+	return bucket
+}
+
+// sweep drops the buckets with no calls that have refilled, as a new bucket
+// would be no different, so that customers who have stopped making calls
+// don't hold on to theirs. It runs at most once per rateBucketSweepInterval,
+// and must be called with r.mut held.
+func (r *RateLimiter) sweep(now time.Time) {
+	if now.Sub(r.swept) < rateBucketSweepInterval {
+		return
+	}
+	r.swept = now
+
+	for key, bucket := range r.buckets {
+		if bucket.idle(now) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// idle returns whether the bucket has no calls and all its tokens.
+func (b *rateBucket) idle(now time.Time) bool {
+	if b.calls > 0 {
+		return false
+	}
+	if b.limit.Rate <= 0 {
+		return true
+	}
+
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// take takes a token, returning how long to wait for it if there are none.
+// Tokens are taken ahead of time, so calls waiting for them start in turn.
+func (b *rateBucket) take(now time.Time) time.Duration {
+	if b.limit.Rate <= 0 {
+		return 0
+	}
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	wait := -b.tokens / b.limit.Rate
+	return time.Duration(math.Ceil(wait*1000)) * time.Millisecond
+}
+
+// reject returns the token taken by a call that was then rejected, counting
+// the call as limited.
+func (b *rateBucket) reject() {
+	b.limited++
+	if b.limit.Rate <= 0 {
+		return
+	}
+
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+1)
+}
+
+func (b *rateBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.updated = now
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+}
+
+// Usage returns a snapshot of each customer's calls, or of one customer's if
+// customerId is not empty, ordered by customer and kind. Customers who
+// haven't made any calls, or none for a while, have no usage.
+func (r *RateLimiter) Usage(customerId string) []RateLimitUsage {
+	if r == nil {
+		return nil
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var (
+		usage []RateLimitUsage
+		now   = time.Now()
+	)
+
+	for _, bucket := range r.buckets {
+		if customerId != "" && bucket.customerId != customerId {
+			continue
+		}
+
+		if bucket.limit.Rate > 0 {
+			bucket.refill(now)
+		}
+
+		usage = append(usage, RateLimitUsage{
+			CustomerId: bucket.customerId,
+			Kind:       bucket.kind,
+			Limit:      r.limits[bucket.kind],
+			Tokens:     bucket.tokens,
+			InFlight:   bucket.inFlight,
+			Limited:    bucket.limited,
+		})
+	}
+
+	sort.Sort(byCustomerAndKind(usage))
+	return usage
+}
+
+type byCustomerAndKind []RateLimitUsage
+
+func (u byCustomerAndKind) Len() int      { return len(u) }
+func (u byCustomerAndKind) Swap(i, j int) { u[i], u[j] = u[j], u[i] }
+func (u byCustomerAndKind) Less(i, j int) bool {
+	if u[i].CustomerId != u[j].CustomerId {
+		return u[i].CustomerId < u[j].CustomerId
+	}
+	return u[i].Kind < u[j].Kind
+}
+
+// limitedBezos limits the Bezos calls made for each customer.
+type limitedBezos struct {
+	opsee.BezosClient
+	limiter *RateLimiter
+}
+
+func (b *limitedBezos) Get(ctx context.Context, in *opsee.BezosRequest, opts ...grpc.CallOption) (*opsee.BezosResponse, error) {
+	if in.User == nil {
+		return b.BezosClient.Get(ctx, in, opts...)
+	}
+
+	release, err := b.limiter.acquire(ctx, in.User.CustomerId, RateLimitReads)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return b.BezosClient.Get(ctx, in, opts...)
+}
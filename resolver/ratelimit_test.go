@@ -0,0 +1,186 @@
+package resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opsee/basic/schema"
+	opsee_aws_ec2 "github.com/opsee/basic/schema/aws/ec2"
+	opsee "github.com/opsee/basic/service"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRateLimiterBezos(t *testing.T) {
+	var (
+		bezos  = &countingBezos{}
+		client = &Client{Bezos: bezos}
+	)
+
+	client.UseCache(NewCache(CacheConfig{
+		TTLs: map[string]time.Duration{CacheKindInstances: time.Minute},
+	}))
+	client.UseRateLimiter(NewRateLimiter(RateLimitConfig{
+		Reads: RateLimit{Rate: 1, Burst: 2},
+	}))
+
+	// requests that would be done before a token is are rejected at once
+	describe := func(customerId, vpc string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Bezos.Get(ctx, &opsee.BezosRequest{
+			User:   &schema.User{CustomerId: customerId},
+			Region: "us-west-2",
+			VpcId:  vpc,
+			Input:  &opsee.BezosRequest_Ec2_DescribeInstancesInput{&opsee_aws_ec2.DescribeInstancesInput{}},
+		})
+		return err
+	}
+
+	// cached responses don't count against the burst of 2
+	assert.NoError(t, describe("customer", "vpc-1"))
+	assert.NoError(t, describe("customer", "vpc-1"))
+	assert.NoError(t, describe("customer", "vpc-2"))
+
+	err := describe("customer", "vpc-3")
+	if assert.Error(t, err) {
+		typed := ErrorOf(err)
+		assert.Equal(t, ErrorRateLimited, typed.Code)
+		assert.True(t, typed.Retryable)
+		assert.True(t, typed.RetryAfter > 0 && typed.RetryAfter <= time.Second)
+	}
+
+	assert.NoError(t, describe("other-customer", "vpc-3"))
+	assert.Equal(t, 3, bezos.requests)
+
+	usage := client.RateLimiter.Usage("customer")
+	if assert.Len(t, usage, 1) {
+		assert.Equal(t, RateLimitReads, usage[0].Kind)
+		assert.EqualValues(t, 1, usage[0].Limited)
+		assert.True(t, usage[0].Tokens < 1)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		Reads: RateLimit{Rate: 50, Burst: 1},
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := limiter.acquire(context.Background(), "customer", RateLimitReads)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+	assert.EqualValues(t, 0, limiter.Usage("customer")[0].Limited)
+
+	// a call stops waiting once its request is done
+	limiter = NewRateLimiter(RateLimitConfig{
+		Reads: RateLimit{Rate: 1, Burst: 1},
+	})
+
+	release, err := limiter.acquire(context.Background(), "customer", RateLimitReads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err = limiter.acquire(ctx, "customer", RateLimitReads)
+	assert.Equal(t, ErrorRateLimited, ErrorOf(err).Code)
+
+	usage := limiter.Usage("customer")[0]
+	assert.EqualValues(t, 1, usage.Limited)
+	assert.True(t, usage.Tokens < 1)
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		Mutations: RateLimit{Concurrency: 1},
+	})
+
+	release, err := limiter.acquire(context.Background(), "customer", RateLimitMutations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = limiter.acquire(ctx, "customer", RateLimitMutations)
+	assert.Equal(t, ErrorRateLimited, ErrorOf(err).Code)
+
+	assert.Equal(t, 1, limiter.Usage("customer")[0].InFlight)
+	release()
+	release()
+	assert.Equal(t, 0, limiter.Usage("customer")[0].InFlight)
+
+	release, err = limiter.acquire(context.Background(), "customer", RateLimitMutations)
+	assert.NoError(t, err)
+	release()
+
+	// a call that gives up waiting for another to finish gives back its
+	// token
+	limiter = NewRateLimiter(RateLimitConfig{
+		Mutations: RateLimit{Rate: 1, Burst: 2, Concurrency: 1},
+	})
+
+	release, err = limiter.acquire(context.Background(), "customer", RateLimitMutations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = limiter.acquire(ctx, "customer", RateLimitMutations)
+	assert.Equal(t, ErrorRateLimited, ErrorOf(err).Code)
+
+	usage := limiter.Usage("customer")[0]
+	assert.EqualValues(t, 1, usage.Limited)
+	assert.InDelta(t, 1, usage.Tokens, 0.1)
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		Reads: RateLimit{Rate: 1000, Burst: 1, Concurrency: 1},
+	})
+
+	acquire := func(customerId string) func() {
+		release, err := limiter.acquire(context.Background(), customerId, RateLimitReads)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return release
+	}
+
+	acquire("customer-1")()
+	acquire("customer-2")()
+	busy := acquire("customer-3")
+	defer busy()
+	assert.Len(t, limiter.Usage(""), 3)
+
+	// once their buckets refill, customers without calls are dropped
+	time.Sleep(5 * time.Millisecond)
+	limiter.swept = time.Time{}
+	acquire("customer-4")()
+
+	usage := limiter.Usage("")
+	if assert.Len(t, usage, 2) {
+		assert.Equal(t, "customer-3", usage[0].CustomerId)
+		assert.Equal(t, "customer-4", usage[1].CustomerId)
+	}
+
+	// and buckets are swept at most once an interval
+	time.Sleep(5 * time.Millisecond)
+	acquire("customer-5")()
+	assert.Len(t, limiter.Usage(""), 3)
+}
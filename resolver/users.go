@@ -16,9 +16,28 @@ func (c *Client) ListCustomers(ctx context.Context, req *opsee.ListUsersRequest)
 		return nil, err
 	}
 
+	customers, err := c.customers(ctx, resp.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsee.ListCustomersResponse{
+		Customers: customers,
+		Page:      resp.Page,
+		PerPage:   resp.PerPage,
+		Total:     resp.Total,
+	}, nil
+}
+
+// customers returns the customers of users, with their bastion states.
+func (c *Client) customers(ctx context.Context, users []*schema.User) ([]*schema.Customer, error) {
+	if len(users) == 0 {
+		return []*schema.Customer{}, nil
+	}
+
 	// as a shim until we have a cats endpoint for listing customers, unique the customers with a map
 	customerIdMap := make(map[string][]*schema.User)
-	for _, user := range resp.Users {
+	for _, user := range users {
 		_, ok := customerIdMap[user.CustomerId]
 		if !ok {
 			customerIdMap[user.CustomerId] = make([]*schema.User, 0)
@@ -59,12 +78,7 @@ func (c *Client) ListCustomers(ctx context.Context, req *opsee.ListUsersRequest)
 		})
 	}
 
-	return &opsee.ListCustomersResponse{
-		Customers: customers,
-		Page:      resp.Page,
-		PerPage:   resp.PerPage,
-		Total:     resp.Total,
-	}, nil
+	return customers, nil
 }
 
 func (c *Client) GetUser(ctx context.Context, req *opsee.GetUserRequest) (*opsee.GetUserResponse, error) {
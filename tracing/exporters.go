@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	log "github.com/opsee/logrus"
+)
+
+// MemoryExporter keeps every span it's given, for tests.
+type MemoryExporter struct {
+	mut   sync.Mutex
+	spans []*SpanData
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span *SpanData) {
+	e.mut.Lock()
+	e.spans = append(e.spans, span)
+	e.mut.Unlock()
+}
+
+// Spans returns the spans exported so far, in the order they finished.
+func (e *MemoryExporter) Spans() []*SpanData {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	return append([]*SpanData(nil), e.spans...)
+}
+
+// Reset forgets the spans exported so far.
+func (e *MemoryExporter) Reset() {
+	e.mut.Lock()
+	e.spans = nil
+	e.mut.Unlock()
+}
+
+// JSONExporter writes each span as a line of JSON, for local debugging.
+type JSONExporter struct {
+	mut     sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{encoder: json.NewEncoder(w)}
+}
+
+func (e *JSONExporter) Export(span *SpanData) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if err := e.encoder.Encode(span); err != nil {
+		log.WithError(err).WithField("span", span.Name).Error("error exporting span")
+	}
+}
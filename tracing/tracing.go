@@ -0,0 +1,273 @@
+// Package tracing records spans of the work compost does for a request: the
+// HTTP request itself, the GraphQL fields resolved for it, and the calls made
+// to backends. Spans are linked by trace and parent ids, propagated between
+// processes in W3C traceparent headers, and handed to an Exporter once they
+// finish.
+//
+// Tracing is off until SetTracer is called. With no tracer, StartSpan returns
+// a nil *Span, whose methods do nothing.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// TraceparentHeader is the header, and gRPC metadata key, carrying the trace
+// context of a request.
+const TraceparentHeader = "traceparent"
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+var (
+	tracerMut sync.RWMutex
+	tracer    *Tracer
+)
+
+// SpanData is a finished span, as exported. DurationMs is in milliseconds.
+type SpanData struct {
+	TraceId    string                 `json:"trace_id"`
+	SpanId     string                 `json:"span_id"`
+	ParentId   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	DurationMs float64                `json:"duration_ms"`
+	Tags       map[string]interface{} `json:"tags,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Exporter receives spans as they finish. Export may be called concurrently.
+type Exporter interface {
+	Export(span *SpanData)
+}
+
+// Tracer starts spans and exports them when they finish.
+type Tracer struct {
+	exporter Exporter
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// SetTracer sets the tracer that starts every span, or turns tracing off if
+// t is nil.
+func SetTracer(t *Tracer) {
+	tracerMut.Lock()
+	tracer = t
+	tracerMut.Unlock()
+}
+
+func currentTracer() *Tracer {
+	tracerMut.RLock()
+	defer tracerMut.RUnlock()
+	return tracer
+}
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceId string
+	SpanId  string
+}
+
+// Traceparent returns sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceId, sc.SpanId)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, false
+	}
+
+	for _, part := range parts[1:3] {
+		if _, err := hex.DecodeString(part); err != nil || strings.Trim(part, "0") == "" {
+			return SpanContext{}, false
+		}
+	}
+
+	return SpanContext{TraceId: strings.ToLower(parts[1]), SpanId: strings.ToLower(parts[2])}, true
+}
+
+// Span is an operation being traced. It is safe for concurrent use, and a
+// nil Span ignores every call.
+type Span struct {
+	tracer *Tracer
+
+	mut      sync.Mutex
+	data     SpanData
+	finished bool
+}
+
+// StartSpan starts a span named name, the child of the span in ctx or of the
+// remote span ctx was extracted from, if either is there. It returns the span
+// and a context carrying it, or nil and ctx if tracing is off.
+func StartSpan(ctx context.Context, name string) (*Span, context.Context) {
+	t := currentTracer()
+	if t == nil {
+		return nil, ctx
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanId: newId(8),
+			Name:   name,
+			Start:  time.Now(),
+		},
+	}
+
+	if parent, ok := parentContext(ctx); ok {
+		span.data.TraceId = parent.TraceId
+		span.data.ParentId = parent.SpanId
+	} else {
+		span.data.TraceId = newId(16)
+	}
+
+	return span, context.WithValue(ctx, spanKey, span)
+}
+
+func parentContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context(), true
+	}
+
+	remote, ok := ctx.Value(remoteKey).(SpanContext)
+	return remote, ok
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithSpan returns a context carrying span, for handing a span across
+// APIs that don't pass contexts along.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// Context returns the span's ids.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return SpanContext{TraceId: s.data.TraceId, SpanId: s.data.SpanId}
+}
+
+// SetTag records a key and value describing the span.
+func (s *Span) SetTag(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.data.Tags == nil {
+		s.data.Tags = make(map[string]interface{})
+	}
+	s.data.Tags[key] = value
+}
+
+// SetError records that the span's operation failed with err, if err is not
+// nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mut.Lock()
+	s.data.Error = err.Error()
+	s.mut.Unlock()
+}
+
+// Finish ends the span and exports it. Only the first call has any effect.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mut.Lock()
+	if s.finished {
+		s.mut.Unlock()
+		return
+	}
+	s.finished = true
+	s.data.DurationMs = float64(time.Since(s.data.Start)) / float64(time.Millisecond)
+
+	data := s.data
+	data.Tags = make(map[string]interface{}, len(s.data.Tags))
+	for k, v := range s.data.Tags {
+		data.Tags[k] = v
+	}
+	s.mut.Unlock()
+
+	s.tracer.exporter.Export(&data)
+}
+
+// InjectHTTP sets the traceparent header of an outgoing request to the span
+// in ctx.
+func InjectHTTP(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set(TraceparentHeader, span.Context().Traceparent())
+	}
+}
+
+// ExtractHTTP returns a context whose spans continue the trace in an incoming
+// request's traceparent header, if it has a valid one.
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	remote, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey, remote)
+}
+
+// InjectGRPC returns a context whose outgoing gRPC metadata carries the span
+// in ctx as traceparent, along with any metadata ctx already had.
+func InjectGRPC(ctx context.Context) context.Context {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return ctx
+	}
+
+	md, ok := metadata.FromContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	md[TraceparentHeader] = []string{span.Context().Traceparent()}
+
+	return metadata.NewContext(ctx, md)
+}
+
+// newId returns n random bytes, hex encoded.
+func newId(n int) string {
+	id := make([]byte, n)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprint("error generating span id: ", err))
+	}
+	return hex.EncodeToString(id)
+}
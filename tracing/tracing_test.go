@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTracing(t *testing.T) {
+	span, ctx := StartSpan(context.Background(), "off")
+	assert.Nil(t, span)
+	span.SetTag("ignored", true)
+	span.Finish()
+
+	spans := NewMemoryExporter()
+	SetTracer(NewTracer(spans))
+	defer SetTracer(nil)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	ctx = ExtractHTTP(context.Background(), header)
+
+	root, ctx := StartSpan(ctx, "root")
+	child, childCtx := StartSpan(ctx, "child")
+	child.SetTag("backend", "cats")
+	child.SetError(errors.New("boom"))
+
+	md, ok := metadata.FromContext(InjectGRPC(metadata.NewContext(childCtx, metadata.Pairs("user", "1"))))
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"1"}, md["user"])
+		assert.Equal(t, []string{child.Context().Traceparent()}, md[TraceparentHeader])
+	}
+
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	exported := spans.Spans()
+	if assert.Len(t, exported, 2) {
+		assert.Equal(t, "child", exported[0].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exported[0].TraceId)
+		assert.Equal(t, root.Context().SpanId, exported[0].ParentId)
+		assert.Equal(t, "boom", exported[0].Error)
+		assert.Equal(t, map[string]interface{}{"backend": "cats"}, exported[0].Tags)
+		assert.Equal(t, "00f067aa0ba902b7", exported[1].ParentId)
+	}
+
+	for _, invalid := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}